@@ -0,0 +1,375 @@
+package class
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 方法访问标志
+const (
+	MethodAccPublic = 0x0001
+	MethodAccPrivate = 0x0002
+	MethodAccProtected = 0x0004
+	MethodAccStatic = 0x0008
+	MethodAccFinal = 0x0010
+	MethodAccSynchronized = 0x0020
+	MethodAccBridge = 0x0040
+	MethodAccVarargs = 0x0080
+	MethodAccNative = 0x0100
+	MethodAccAbstract = 0x0400
+	MethodAccStrict = 0x0800
+	MethodAccSynthetic = 0x1000
+)
+
+// 内部类访问标志
+const (
+	InnerClassAccPublic = 0x0001
+	InnerClassAccPrivate = 0x0002
+	InnerClassAccProtected = 0x0004
+	InnerClassAccStatic = 0x0008
+	InnerClassAccFinal = 0x0010
+	InnerClassAccInterface = 0x0200
+	InnerClassAccAbstract = 0x0400
+	InnerClassAccSynthetic = 0x1000
+	InnerClassAccAnnotation = 0x2000
+	InnerClassAccEnum = 0x4000
+)
+
+// MethodParameters 属性中的参数访问标志
+const (
+	ParameterAccFinal = 0x0010
+	ParameterAccSynthetic = 0x1000
+	ParameterAccMandated = 0x8000
+)
+
+const accVisibility = ACCPUBLIC | FieldAccPrivate | FieldAccProtected
+
+type flagName struct {
+	flag uint16
+	name string
+}
+
+// 类 (JVMS 4.1)
+type ClassAccessFlags uint16
+// 字段 (JVMS 4.5)
+type FieldAccessFlags uint16
+// 方法 (JVMS 4.6)
+type MethodAccessFlags uint16
+// InnerClasses 属性中的内部类 (JVMS 4.7.6)
+type InnerClassAccessFlags uint16
+// MethodParameters 属性中的参数 (JVMS 4.7.24)
+type ParameterAccessFlags uint16
+// Module 属性中的 module_flags、exports_flags 和 opens_flags (JVMS 4.7.25)
+type ModuleAccessFlags uint16
+// Module 属性中的 requires_flags
+type RequiresAccessFlags uint16
+
+var classFlagNames = []flagName{
+	{ACCPUBLIC, "ACC_PUBLIC"},
+	{ACCFINAL, "ACC_FINAL"},
+	{ACCSUPER, "ACC_SUPER"},
+	{ACCINTERFACE, "ACC_INTERFACE"},
+	{ACCABSTRACT, "ACC_ABSTRACT"},
+	{ACCSYNTHETIC, "ACC_SYNTHETIC"},
+	{ACCANNOTATION, "ACC_ANNOTATION"},
+	{ACCENUM, "ACC_ENUM"},
+	{ACCMODULE, "ACC_MODULE"},
+}
+
+var fieldFlagNames = []flagName{
+	{FieldAccPublic, "ACC_PUBLIC"},
+	{FieldAccPrivate, "ACC_PRIVATE"},
+	{FieldAccProtected, "ACC_PROTECTED"},
+	{FieldAccStatic, "ACC_STATIC"},
+	{FieldAccFinal, "ACC_FINAL"},
+	{FieldAccVolatile, "ACC_VOLATILE"},
+	{FieldAccTransient, "ACC_TRANSIENT"},
+	{FieldAccSynthetic, "ACC_SYNTHETIC"},
+	{FieldAccEnum, "ACC_ENUM"},
+}
+
+var methodFlagNames = []flagName{
+	{MethodAccPublic, "ACC_PUBLIC"},
+	{MethodAccPrivate, "ACC_PRIVATE"},
+	{MethodAccProtected, "ACC_PROTECTED"},
+	{MethodAccStatic, "ACC_STATIC"},
+	{MethodAccFinal, "ACC_FINAL"},
+	{MethodAccSynchronized, "ACC_SYNCHRONIZED"},
+	{MethodAccBridge, "ACC_BRIDGE"},
+	{MethodAccVarargs, "ACC_VARARGS"},
+	{MethodAccNative, "ACC_NATIVE"},
+	{MethodAccAbstract, "ACC_ABSTRACT"},
+	{MethodAccStrict, "ACC_STRICT"},
+	{MethodAccSynthetic, "ACC_SYNTHETIC"},
+}
+
+var innerClassFlagNames = []flagName{
+	{InnerClassAccPublic, "ACC_PUBLIC"},
+	{InnerClassAccPrivate, "ACC_PRIVATE"},
+	{InnerClassAccProtected, "ACC_PROTECTED"},
+	{InnerClassAccStatic, "ACC_STATIC"},
+	{InnerClassAccFinal, "ACC_FINAL"},
+	{InnerClassAccInterface, "ACC_INTERFACE"},
+	{InnerClassAccAbstract, "ACC_ABSTRACT"},
+	{InnerClassAccSynthetic, "ACC_SYNTHETIC"},
+	{InnerClassAccAnnotation, "ACC_ANNOTATION"},
+	{InnerClassAccEnum, "ACC_ENUM"},
+}
+
+var parameterFlagNames = []flagName{
+	{ParameterAccFinal, "ACC_FINAL"},
+	{ParameterAccSynthetic, "ACC_SYNTHETIC"},
+	{ParameterAccMandated, "ACC_MANDATED"},
+}
+
+var moduleFlagNames = []flagName{
+	{ModuleAccOpen, "ACC_OPEN"},
+	{ModuleAccSynthetic, "ACC_SYNTHETIC"},
+	{ModuleAccMandated, "ACC_MANDATED"},
+}
+
+var requiresFlagNames = []flagName{
+	{ModuleAccTransitive, "ACC_TRANSITIVE"},
+	{ModuleAccStaticPhase, "ACC_STATIC_PHASE"},
+	{ModuleAccSynthetic, "ACC_SYNTHETIC"},
+	{ModuleAccMandated, "ACC_MANDATED"},
+}
+
+// 按 Java 源码中的修饰符顺序排列 (java.lang.reflect.Modifier.toString)
+var classModifiers = []flagName{
+	{ACCPUBLIC, "public"},
+	{ACCABSTRACT, "abstract"},
+	{ACCFINAL, "final"},
+}
+
+var fieldModifiers = []flagName{
+	{FieldAccPublic, "public"},
+	{FieldAccProtected, "protected"},
+	{FieldAccPrivate, "private"},
+	{FieldAccStatic, "static"},
+	{FieldAccFinal, "final"},
+	{FieldAccTransient, "transient"},
+	{FieldAccVolatile, "volatile"},
+}
+
+var methodModifiers = []flagName{
+	{MethodAccPublic, "public"},
+	{MethodAccProtected, "protected"},
+	{MethodAccPrivate, "private"},
+	{MethodAccAbstract, "abstract"},
+	{MethodAccStatic, "static"},
+	{MethodAccFinal, "final"},
+	{MethodAccSynchronized, "synchronized"},
+	{MethodAccNative, "native"},
+	{MethodAccStrict, "strictfp"},
+}
+
+var innerClassModifiers = []flagName{
+	{InnerClassAccPublic, "public"},
+	{InnerClassAccProtected, "protected"},
+	{InnerClassAccPrivate, "private"},
+	{InnerClassAccAbstract, "abstract"},
+	{InnerClassAccStatic, "static"},
+	{InnerClassAccFinal, "final"},
+}
+
+var parameterModifiers = []flagName{
+	{ParameterAccFinal, "final"},
+}
+
+var moduleModifiers = []flagName{
+	{ModuleAccOpen, "open"},
+}
+
+var requiresModifiers = []flagName{
+	{ModuleAccTransitive, "transitive"},
+	{ModuleAccStaticPhase, "static"},
+}
+
+func selectFlags(flags uint16, names []flagName) []string {
+	var s []string
+	for _, n := range names {
+		if flags & n.flag != 0 {
+			s = append(s, n.name)
+		}
+	}
+	return s
+}
+
+// JVMS 中的标志名，例如 [ACC_PUBLIC ACC_SUPER]
+func (f ClassAccessFlags) Flags() []string {
+	return selectFlags(uint16(f), classFlagNames)
+}
+
+// Java 源码中的修饰符，例如 public final
+func (f ClassAccessFlags) String() string {
+	return strings.Join(selectFlags(uint16(f), classModifiers), " ")
+}
+
+func (f FieldAccessFlags) Flags() []string {
+	return selectFlags(uint16(f), fieldFlagNames)
+}
+
+func (f FieldAccessFlags) String() string {
+	return strings.Join(selectFlags(uint16(f), fieldModifiers), " ")
+}
+
+func (f MethodAccessFlags) Flags() []string {
+	return selectFlags(uint16(f), methodFlagNames)
+}
+
+func (f MethodAccessFlags) String() string {
+	return strings.Join(selectFlags(uint16(f), methodModifiers), " ")
+}
+
+func (f InnerClassAccessFlags) Flags() []string {
+	return selectFlags(uint16(f), innerClassFlagNames)
+}
+
+func (f InnerClassAccessFlags) String() string {
+	return strings.Join(selectFlags(uint16(f), innerClassModifiers), " ")
+}
+
+func (f ParameterAccessFlags) Flags() []string {
+	return selectFlags(uint16(f), parameterFlagNames)
+}
+
+func (f ParameterAccessFlags) String() string {
+	return strings.Join(selectFlags(uint16(f), parameterModifiers), " ")
+}
+
+func (f ModuleAccessFlags) Flags() []string {
+	return selectFlags(uint16(f), moduleFlagNames)
+}
+
+func (f ModuleAccessFlags) String() string {
+	return strings.Join(selectFlags(uint16(f), moduleModifiers), " ")
+}
+
+func (f RequiresAccessFlags) Flags() []string {
+	return selectFlags(uint16(f), requiresFlagNames)
+}
+
+func (f RequiresAccessFlags) String() string {
+	return strings.Join(selectFlags(uint16(f), requiresModifiers), " ")
+}
+
+func illegalFlags(kind string, flags uint16, reason string) error {
+	return &ClassFormatError{Offset: -1, Err: fmt.Errorf("illegal %s access flags 0x%04x: %s", kind, flags, reason)}
+}
+
+// public、private、protected 最多只能有一个
+func multipleVisibility(flags uint16) bool {
+	v := flags & accVisibility
+	return v & (v - 1) != 0
+}
+
+// 检查类的访问标志是否合法，major 为class文件主版本号
+func (f ClassAccessFlags) Validate(major uint16) error {
+	flags := uint16(f)
+	if major >= 53 && flags & ACCMODULE != 0 {
+		if flags != ACCMODULE {
+			return illegalFlags("class", flags, "module must not have other flags")
+		}
+		return nil
+	}
+	return validateClassFlags("class", flags, major)
+}
+
+func validateClassFlags(kind string, flags uint16, major uint16) error {
+	if flags & ACCINTERFACE != 0 {
+		if flags & ACCABSTRACT == 0 {
+			return illegalFlags(kind, flags, "interface must be abstract")
+		}
+		if flags & (ACCFINAL | ACCENUM) != 0 {
+			return illegalFlags(kind, flags, "interface must not be final or enum")
+		}
+		// JDK 1.5 之前的编译器会为接口设置 ACC_SUPER
+		if major >= 49 && flags & ACCSUPER != 0 {
+			return illegalFlags(kind, flags, "interface must not have ACC_SUPER")
+		}
+		return nil
+	}
+	if flags & ACCANNOTATION != 0 {
+		return illegalFlags(kind, flags, "annotation must be interface")
+	}
+	if flags & (ACCFINAL | ACCABSTRACT) == ACCFINAL | ACCABSTRACT {
+		return illegalFlags(kind, flags, "both final and abstract")
+	}
+	return nil
+}
+
+// 检查内部类的访问标志是否合法
+func (f InnerClassAccessFlags) Validate(major uint16) error {
+	flags := uint16(f)
+	if multipleVisibility(flags) {
+		return illegalFlags("inner class", flags, "more than one of public, private and protected")
+	}
+	return validateClassFlags("inner class", flags, major)
+}
+
+// 检查字段的访问标志是否合法，inInterface 表示字段属于接口
+func (f FieldAccessFlags) Validate(inInterface bool) error {
+	flags := uint16(f)
+	if inInterface {
+		const required = FieldAccPublic | FieldAccStatic | FieldAccFinal
+		if flags & required != required || flags &^ (required | FieldAccSynthetic) != 0 {
+			return illegalFlags("field", flags, "interface field must be public static final")
+		}
+		return nil
+	}
+	if multipleVisibility(flags) {
+		return illegalFlags("field", flags, "more than one of public, private and protected")
+	}
+	if flags & (FieldAccFinal | FieldAccVolatile) == FieldAccFinal | FieldAccVolatile {
+		return illegalFlags("field", flags, "both final and volatile")
+	}
+	return nil
+}
+
+// 检查方法的访问标志是否合法，name 为方法名，inInterface 表示方法属于接口
+func (f MethodAccessFlags) Validate(name string, inInterface bool, major uint16) error {
+	flags := uint16(f)
+	// 类初始化方法除 ACC_STATIC 以外的标志都被忽略
+	if name == "<clinit>" {
+		if major >= 51 && flags & MethodAccStatic == 0 {
+			return illegalFlags("method", flags, "<clinit> must be static")
+		}
+		return nil
+	}
+	if multipleVisibility(flags) {
+		return illegalFlags("method", flags, "more than one of public, private and protected")
+	}
+	if name == "<init>" {
+		if flags &^ (accVisibility | MethodAccVarargs | MethodAccStrict | MethodAccSynthetic) != 0 {
+			return illegalFlags("method", flags, "illegal flags for <init>")
+		}
+		return nil
+	}
+	if inInterface {
+		if major < 52 {
+			const required = MethodAccPublic | MethodAccAbstract
+			if flags & required != required || flags &^ (required | MethodAccBridge | MethodAccVarargs | MethodAccSynthetic) != 0 {
+				return illegalFlags("method", flags, "interface method must be public abstract")
+			}
+		} else {
+			if flags & (MethodAccProtected | MethodAccFinal | MethodAccSynchronized | MethodAccNative) != 0 {
+				return illegalFlags("method", flags, "interface method must not be protected, final, synchronized or native")
+			}
+			if flags & (MethodAccPublic | MethodAccPrivate) == 0 {
+				return illegalFlags("method", flags, "interface method must be public or private")
+			}
+		}
+	}
+	if flags & MethodAccAbstract != 0 {
+		illegal := uint16(MethodAccPrivate | MethodAccStatic | MethodAccFinal | MethodAccSynchronized | MethodAccNative)
+		// JDK 17 开始 strictfp 不再有意义
+		if major >= 46 && major <= 60 {
+			illegal |= MethodAccStrict
+		}
+		if flags & illegal != 0 {
+			return illegalFlags("method", flags, "abstract method must not be private, static, final, synchronized, native or strictfp")
+		}
+	}
+	return nil
+}
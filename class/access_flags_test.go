@@ -0,0 +1,148 @@
+package class
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAccessFlagsString(t *testing.T) {
+	for _, test := range []struct {
+		flags interface{
+			String() string
+			Flags() []string
+		}
+		modifiers, names string
+	}{
+		{ClassAccessFlags(ACCPUBLIC | ACCFINAL | ACCSUPER), "public final", "ACC_PUBLIC ACC_FINAL ACC_SUPER"},
+		{ClassAccessFlags(ACCPUBLIC | ACCINTERFACE | ACCABSTRACT), "public abstract", "ACC_PUBLIC ACC_INTERFACE ACC_ABSTRACT"},
+		{FieldAccessFlags(FieldAccPrivate | FieldAccStatic | FieldAccVolatile | FieldAccTransient), "private static transient volatile", "ACC_PRIVATE ACC_STATIC ACC_VOLATILE ACC_TRANSIENT"},
+		{MethodAccessFlags(MethodAccProtected | MethodAccAbstract | MethodAccVarargs), "protected abstract", "ACC_PROTECTED ACC_VARARGS ACC_ABSTRACT"},
+		{MethodAccessFlags(MethodAccPublic | MethodAccStatic | MethodAccFinal | MethodAccSynchronized | MethodAccNative), "public static final synchronized native", "ACC_PUBLIC ACC_STATIC ACC_FINAL ACC_SYNCHRONIZED ACC_NATIVE"},
+		{InnerClassAccessFlags(InnerClassAccPrivate | InnerClassAccStatic | InnerClassAccAbstract), "private abstract static", "ACC_PRIVATE ACC_STATIC ACC_ABSTRACT"},
+		{ParameterAccessFlags(ParameterAccFinal | ParameterAccMandated), "final", "ACC_FINAL ACC_MANDATED"},
+		{ModuleAccessFlags(ModuleAccOpen | ModuleAccSynthetic), "open", "ACC_OPEN ACC_SYNTHETIC"},
+		{RequiresAccessFlags(ModuleAccTransitive | ModuleAccStaticPhase), "transitive static", "ACC_TRANSITIVE ACC_STATIC_PHASE"},
+		{MethodAccessFlags(MethodAccSynthetic | MethodAccBridge), "", "ACC_BRIDGE ACC_SYNTHETIC"},
+	} {
+		if s := test.flags.String(); s != test.modifiers {
+			t.Errorf("%#x: modifiers %q, expected %q", test.flags, s, test.modifiers)
+		}
+		if names := strings.Join(test.flags.Flags(), " "); names != test.names {
+			t.Errorf("%#x: flags %q, expected %q", test.flags, names, test.names)
+		}
+	}
+}
+
+func TestAccessFlagsValidate(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		err error
+		valid bool
+	}{
+		{"class", ClassAccessFlags(ACCPUBLIC | ACCSUPER).Validate(52), true},
+		{"interface", ClassAccessFlags(ACCINTERFACE | ACCABSTRACT).Validate(52), true},
+		{"annotation", ClassAccessFlags(ACCINTERFACE | ACCABSTRACT | ACCANNOTATION).Validate(52), true},
+		{"old interface with super", ClassAccessFlags(ACCINTERFACE | ACCABSTRACT | ACCSUPER).Validate(48), true},
+		{"module", ClassAccessFlags(ACCMODULE).Validate(53), true},
+		{"interface not abstract", ClassAccessFlags(ACCINTERFACE).Validate(52), false},
+		{"final interface", ClassAccessFlags(ACCINTERFACE | ACCABSTRACT | ACCFINAL).Validate(52), false},
+		{"interface with super", ClassAccessFlags(ACCINTERFACE | ACCABSTRACT | ACCSUPER).Validate(52), false},
+		{"annotation class", ClassAccessFlags(ACCANNOTATION).Validate(52), false},
+		{"final abstract class", ClassAccessFlags(ACCFINAL | ACCABSTRACT).Validate(52), false},
+		{"module with flags", ClassAccessFlags(ACCMODULE | ACCPUBLIC).Validate(53), false},
+		{"field", FieldAccessFlags(FieldAccPrivate | FieldAccVolatile).Validate(false), true},
+		{"interface field", FieldAccessFlags(FieldAccPublic | FieldAccStatic | FieldAccFinal | FieldAccSynthetic).Validate(true), true},
+		{"final volatile field", FieldAccessFlags(FieldAccFinal | FieldAccVolatile).Validate(false), false},
+		{"public private field", FieldAccessFlags(FieldAccPublic | FieldAccPrivate).Validate(false), false},
+		{"interface field not static", FieldAccessFlags(FieldAccPublic | FieldAccFinal).Validate(true), false},
+		{"method", MethodAccessFlags(MethodAccPublic | MethodAccSynchronized).Validate("m", false, 52), true},
+		{"default method", MethodAccessFlags(MethodAccPublic).Validate("m", true, 52), true},
+		{"private interface method", MethodAccessFlags(MethodAccPrivate | MethodAccStatic).Validate("m", true, 53), true},
+		{"clinit", MethodAccessFlags(MethodAccStatic | MethodAccFinal).Validate("<clinit>", false, 52), true},
+		{"old clinit", MethodAccessFlags(0).Validate("<clinit>", false, 50), true},
+		{"abstract strictfp", MethodAccessFlags(MethodAccAbstract | MethodAccStrict).Validate("m", false, 61), true},
+		{"public private method", MethodAccessFlags(MethodAccPublic | MethodAccPrivate).Validate("m", false, 52), false},
+		{"abstract static method", MethodAccessFlags(MethodAccAbstract | MethodAccStatic).Validate("m", false, 52), false},
+		{"abstract strictfp before 17", MethodAccessFlags(MethodAccAbstract | MethodAccStrict).Validate("m", false, 52), false},
+		{"interface method before 8", MethodAccessFlags(MethodAccPublic).Validate("m", true, 51), false},
+		{"protected interface method", MethodAccessFlags(MethodAccProtected | MethodAccAbstract).Validate("m", true, 52), false},
+		{"static init", MethodAccessFlags(MethodAccPublic | MethodAccStatic).Validate("<init>", false, 52), false},
+		{"clinit not static", MethodAccessFlags(0).Validate("<clinit>", false, 51), false},
+		{"inner class", InnerClassAccessFlags(InnerClassAccPrivate | InnerClassAccStatic | InnerClassAccFinal).Validate(52), true},
+		{"public private inner class", InnerClassAccessFlags(InnerClassAccPublic | InnerClassAccPrivate).Validate(52), false},
+		{"final inner interface", InnerClassAccessFlags(InnerClassAccInterface | InnerClassAccAbstract | InnerClassAccFinal).Validate(52), false},
+	} {
+		if test.valid && test.err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, test.err)
+		}
+		if !test.valid && !errors.Is(test.err, ClassFileFormatError) {
+			t.Errorf("%s: expected format error, got %v", test.name, test.err)
+		}
+	}
+}
+
+func flagsTestClass(classFlags, fieldFlags, methodFlags uint16, methodName string) *testWriter {
+	buf := &testWriter{}
+	buf.write(uint32(ClassFileMagic))
+	buf.write(uint16(0))
+	buf.write(uint16(52))
+	buf.write(uint16(7))
+	buf.writeUTF8("Foo")                  // #1
+	buf.write(uint8(Class))               // #2
+	buf.write(uint16(1))
+	buf.writeUTF8(methodName)             // #3
+	buf.writeUTF8("()V")                  // #4
+	buf.writeUTF8("f")                    // #5
+	buf.writeUTF8("I")                    // #6
+	buf.write([]uint16{classFlags, 2, 0, 0})
+	buf.write([]uint16{1, fieldFlags, 5, 6, 0})
+	buf.write([]uint16{1, methodFlags, 3, 4, 0})
+	buf.write(uint16(0))
+	return buf
+}
+
+func TestAccessFlagsRejected(t *testing.T) {
+	if _, err := NewClassFile(flagsTestClass(ACCPUBLIC | ACCSUPER, FieldAccPrivate, MethodAccPublic, "m")); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		class, field, method uint16
+		name, path string
+	}{
+		{ACCINTERFACE | ACCFINAL, 0, 0, "m", "access_flags"},
+		{ACCSUPER, FieldAccFinal | FieldAccVolatile, 0, "m", "fields[0].access_flags"},
+		{ACCINTERFACE | ACCABSTRACT, FieldAccPublic, MethodAccPublic | MethodAccAbstract, "m", "fields[0].access_flags"},
+		{ACCSUPER, 0, MethodAccAbstract | MethodAccFinal, "m", "methods[0].access_flags"},
+		{ACCSUPER, 0, MethodAccStatic, "<init>", "methods[0].access_flags"},
+	} {
+		buf := flagsTestClass(test.class, test.field, test.method, test.name)
+		size := int64(buf.Len())
+		_, err := NewClassFile(buf)
+		var cfe *ClassFormatError
+		if !errors.As(err, &cfe) {
+			t.Errorf("%s: expected *ClassFormatError, got %v", test.path, err)
+			continue
+		}
+		if cfe.Path != test.path || !strings.Contains(err.Error(), "illegal") {
+			t.Errorf("%s: unexpected error %v", test.path, err)
+		}
+		// 方法标志错误指向方法开头
+		if test.path == "methods[0].access_flags" && cfe.Offset != size - 10 {
+			t.Errorf("%s: offset %d, expected %d", test.path, cfe.Offset, size - 10)
+		}
+	}
+}
+
+func TestMethodNameIndexZero(t *testing.T) {
+	buf := flagsTestClass(ACCPUBLIC | ACCSUPER, 0, MethodAccPublic, "m")
+	data := buf.Bytes()
+	// 最后4个u2依次为方法的 name_index、descriptor_index、attributes_count 和类的 attributes_count
+	data[len(data) - 8], data[len(data) - 7] = 0, 0
+	_, err := NewClassFile(bytes.NewReader(data))
+	var cfe *ClassFormatError
+	if !errors.As(err, &cfe) || cfe.Path != "methods[0].name_index" || cfe.Expected != "Utf8" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
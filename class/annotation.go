@@ -28,7 +28,7 @@ func NewAnnotation(io io.Reader, pool *ConstantPool) (*Annotation, error) {
 	for i := 0; i < int(numElementValuePairs); i++ {
 		evp, err := NewElementValPair(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "element_value_pairs[%d]", i)
 		}
 		annotation.ElementValPairs = append(annotation.ElementValPairs, evp)
 	}
@@ -421,7 +421,7 @@ func NewElementValArray(io io.Reader, pool *ConstantPool) (*ElementValArray, err
 	for i := 0; i < int(numValues); i++ {
 		ev, err := NewElementVal(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "values[%d]", i)
 		}
 		eva.Val = append(eva.Val, ev)
 	}
@@ -529,6 +529,9 @@ func NewTypeParameterBoundTarget(io io.Reader) (*TypeParameterBoundTarget, error
 	if err := binary.Read(io, binary.BigEndian, &tpbt.TypeParameterIndex); err != nil {
 		return nil, err
 	}
+	if err := binary.Read(io, binary.BigEndian, &tpbt.BoundIndex); err != nil {
+		return nil, err
+	}
 	return tpbt, nil
 }
 
@@ -551,7 +554,7 @@ func NewFormalParameterTarget(io io.Reader) (*FormalParameterTarget, error) {
 }
 
 type ThrowsTarget struct {
-	ThrowsTypeIndex uint8
+	ThrowsTypeIndex uint16
 }
 
 func NewThrowsTarget(io io.Reader) (*ThrowsTarget, error) {
@@ -597,7 +600,7 @@ func NewLocalvarTarget(io io.Reader) (*LocalvarTarget, error) {
 	for i := 0; i < int(tableLen); i++ {
 		table, err := NewTable(io)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "table[%d]", i)
 		}
 		lt.Table = append(lt.Table, table)
 	}
@@ -676,7 +679,7 @@ func NewTypePath(io io.Reader) (*TypePath, error) {
 	for i := 0; i < int(pathLength); i++ {
 		path, err := NewPath(io)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "path[%d]", i)
 		}
 		tp.Path = append(tp.Path, path)
 	}
@@ -0,0 +1,232 @@
+package class
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 解码后的注解，元素值转换为 Go 值:
+// byte int8, char uint16, short int16, int int32, long int64,
+// float float32, double float64, boolean bool, String string,
+// 枚举 EnumConst, 类 ClassDescriptor, 嵌套注解 *AnnotationValue, 数组 []interface{}
+type AnnotationValue struct {
+	// 注解类型的描述符，例如 Ljava/lang/Deprecated;
+	Type string
+	// 来自 RuntimeVisible* 属性时为 true
+	Visible bool
+	Values map[string]interface{}
+}
+
+// 枚举常量
+type EnumConst struct {
+	// 枚举类型的描述符
+	Type string
+	Name string
+}
+
+func (e EnumConst) String() string {
+	return e.Type + "." + e.Name
+}
+
+// 类字面量的返回描述符，例如 Ljava/lang/String; 或 V
+type ClassDescriptor string
+
+func NewAnnotationValue(annotation *Annotation, visible bool) *AnnotationValue {
+	return newAnnotationValue(annotation.Type, annotation.ElementValPairs, visible)
+}
+
+func newAnnotationValue(typ *ConstUTF8, pairs []*ElementValPair, visible bool) *AnnotationValue {
+	av := &AnnotationValue{Type: utf8String(typ), Visible: visible, Values: make(map[string]interface{}, len(pairs))}
+	for _, pair := range pairs {
+		av.Values[utf8String(pair.ElementName)] = DecodeElementVal(pair.Val, visible)
+	}
+	return av
+}
+
+// 将元素值解码为 Go 值，嵌套注解继承 visible
+func DecodeElementVal(val ElementVal, visible bool) interface{} {
+	switch v := val.(type) {
+	case *ElementValByte:
+		return int8(v.Val.Val)
+	case *ElementValChar:
+		return uint16(v.Val.Val)
+	case *ElementValShort:
+		return int16(v.Val.Val)
+	case *ElementValInt:
+		return v.Val.Val
+	case *ElementValBoolean:
+		return v.Val.Val != 0
+	case *ElementValLong:
+		return v.Val.Val
+	case *ElementValFloat:
+		return v.Val.Val
+	case *ElementValDouble:
+		return v.Val.Val
+	case *ElementValString:
+		return utf8String(v.Val)
+	case *ElementValEnum:
+		return EnumConst{Type: utf8String(v.TypeName), Name: utf8String(v.ConstName)}
+	case *ElementValClass:
+		return ClassDescriptor(utf8String(v.ClassInfo))
+	case *ElementValAnnotation:
+		return newAnnotationValue(v.AnnotationVal.Type, v.AnnotationVal.ElementValPairs, visible)
+	case *ElementValArray:
+		values := make([]interface{}, len(v.Val))
+		for i, elem := range v.Val {
+			values[i] = DecodeElementVal(elem, visible)
+		}
+		return values
+	}
+	return nil
+}
+
+// 元素值，不存在时 ok 为 false
+func (av *AnnotationValue) Get(name string) (interface{}, bool) {
+	v, ok := av.Values[name]
+	return v, ok
+}
+
+// 用注解类型中 AnnotationDefault 属性的默认值补全没有显式给出的元素
+// annotationType 必须是 av.Type 对应的注解类型
+func (av *AnnotationValue) MergeDefaults(annotationType *ClassFile) error {
+	if name := "L" + annotationType.Name() + ";"; name != av.Type {
+		return fmt.Errorf("annotation type %s does not match %s", name, av.Type)
+	}
+	if annotationType.AccessFlags & ACCANNOTATION == 0 {
+		return fmt.Errorf("%s is not an annotation type", annotationType.Name())
+	}
+	for _, method := range annotationType.Methods {
+		name := utf8String(method.Name)
+		if _, ok := av.Values[name]; ok {
+			continue
+		}
+		if def := method.AnnotationDefault(); def != nil {
+			av.Values[name] = DecodeElementVal(def, av.Visible)
+		}
+	}
+	return nil
+}
+
+func (av *AnnotationValue) String() string {
+	names := make([]string, 0, len(av.Values))
+	for name := range av.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = fmt.Sprintf("%s=%v", name, av.Values[name])
+	}
+	return fmt.Sprintf("@%s(%s)", av.Type, strings.Join(values, ", "))
+}
+
+// 合并 RuntimeVisibleAnnotations 和 RuntimeInvisibleAnnotations 属性
+func annotationsOf(attrs []Attr) []*AnnotationValue {
+	var avs []*AnnotationValue
+	for _, attr := range attrs {
+		switch a := attr.(type) {
+		case *AttrRuntimeVisibleAnnotations:
+			for _, annotation := range a.Annotations {
+				avs = append(avs, NewAnnotationValue(annotation, true))
+			}
+		case *AttrRuntimeInvisibleAnnotations:
+			for _, annotation := range a.Annotations {
+				avs = append(avs, NewAnnotationValue(annotation, false))
+			}
+		}
+	}
+	return avs
+}
+
+func findAnnotation(avs []*AnnotationValue, desc string) *AnnotationValue {
+	for _, av := range avs {
+		if av.Type == desc {
+			return av
+		}
+	}
+	return nil
+}
+
+// 类上的注解
+func (classfile *ClassFile) Annotations() []*AnnotationValue {
+	return annotationsOf(classfile.Attrs)
+}
+
+// 按描述符查找注解，没有时返回nil
+func (classfile *ClassFile) Annotation(desc string) *AnnotationValue {
+	return findAnnotation(classfile.Annotations(), desc)
+}
+
+func (classfile *ClassFile) HasAnnotation(desc string) bool {
+	return classfile.Annotation(desc) != nil
+}
+
+// 字段上的注解
+func (field *Field) Annotations() []*AnnotationValue {
+	return annotationsOf(field.Attrs)
+}
+
+func (field *Field) Annotation(desc string) *AnnotationValue {
+	return findAnnotation(field.Annotations(), desc)
+}
+
+func (field *Field) HasAnnotation(desc string) bool {
+	return field.Annotation(desc) != nil
+}
+
+// 方法上的注解
+func (method *Method) Annotations() []*AnnotationValue {
+	return annotationsOf(method.Attrs)
+}
+
+func (method *Method) Annotation(desc string) *AnnotationValue {
+	return findAnnotation(method.Annotations(), desc)
+}
+
+func (method *Method) HasAnnotation(desc string) bool {
+	return method.Annotation(desc) != nil
+}
+
+// 第 i 个参数上的注解
+// 注意 javac 生成的参数注解表可能不包含合成参数，例如内部类构造方法的外部实例
+func (method *Method) ParameterAnnotations(i int) []*AnnotationValue {
+	var avs []*AnnotationValue
+	for _, attr := range method.Attrs {
+		var params []*ParameterAnnotation
+		var visible bool
+		switch a := attr.(type) {
+		case *AttrRuntimeVisibleParameterAnnotations:
+			params, visible = a.ParameterAnnotations, true
+		case *AttrRuntimeInvisibleParameterAnnotations:
+			params = a.ParameterAnnotations
+		default:
+			continue
+		}
+		if i < 0 || i >= len(params) {
+			continue
+		}
+		for _, annotation := range params[i].Annotations {
+			avs = append(avs, NewAnnotationValue(annotation, visible))
+		}
+	}
+	return avs
+}
+
+func (method *Method) ParameterAnnotation(i int, desc string) *AnnotationValue {
+	return findAnnotation(method.ParameterAnnotations(i), desc)
+}
+
+func (method *Method) HasParameterAnnotation(i int, desc string) bool {
+	return method.ParameterAnnotation(i, desc) != nil
+}
+
+// 注解类型元素的默认值，没有时返回nil
+func (method *Method) AnnotationDefault() ElementVal {
+	for _, attr := range method.Attrs {
+		if def, ok := attr.(*AttrAnnotationDefault); ok {
+			return def.DefaultVal
+		}
+	}
+	return nil
+}
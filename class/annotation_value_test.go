@@ -0,0 +1,91 @@
+package class
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnnotationValue(t *testing.T) {
+	pool := NewEmptyConstantPool()
+	utf8 := func(s string) *ConstUTF8 {
+		i, _ := pool.AddUTF8(s)
+		u, _ := pool.GetUTF8String(i)
+		return u
+	}
+	integer := func(v int32) *ConstInteger {
+		i, _ := pool.AddInteger(v)
+		c, _ := pool.GetInteger(i)
+		return c
+	}
+	class := func(name string) *ConstClass {
+		i, _ := pool.AddClass(name)
+		c, _ := pool.GetClass(i)
+		return c
+	}
+	anno := &Annotation{Type: utf8("LAnno;"), ElementValPairs: []*ElementValPair{
+		{utf8("value"), &ElementValInt{integer(3)}},
+		{utf8("names"), &ElementValArray{[]ElementVal{&ElementValString{utf8("a")}, &ElementValString{utf8("b")}}}},
+		{utf8("mode"), &ElementValEnum{utf8("LMode;"), utf8("FAST")}},
+		{utf8("nested"), &ElementValAnnotation{&Annotation{Type: utf8("LInner;"), ElementValPairs: []*ElementValPair{
+			{utf8("type"), &ElementValClass{utf8("Ljava/lang/String;")}},
+		}}}},
+	}}
+	method := &Method{Name: utf8("m"), Descriptor: utf8("(II)V"), Attrs: []Attr{
+		&AttrRuntimeInvisibleAnnotations{[]*Annotation{{Type: utf8("LMarker;")}}},
+		&AttrRuntimeVisibleParameterAnnotations{[]*ParameterAnnotation{{}, {[]*Annotation{anno}}}},
+	}}
+	cf := &ClassFile{
+		ConstantPool: pool,
+		ThisClass: class("Foo"),
+		Methods: []*Method{method},
+		Attrs: []Attr{&AttrRuntimeVisibleAnnotations{[]*Annotation{anno}}},
+	}
+
+	av := cf.Annotation("LAnno;")
+	if av == nil || !av.Visible || !cf.HasAnnotation("LAnno;") || cf.HasAnnotation("LMarker;") {
+		t.Fatalf("unexpected annotations %v", cf.Annotations())
+	}
+	expected := map[string]interface{}{
+		"value": int32(3),
+		"names": []interface{}{"a", "b"},
+		"mode": EnumConst{"LMode;", "FAST"},
+		"nested": &AnnotationValue{Type: "LInner;", Visible: true, Values: map[string]interface{}{
+			"type": ClassDescriptor("Ljava/lang/String;"),
+		}},
+	}
+	if !reflect.DeepEqual(av.Values, expected) {
+		t.Errorf("unexpected values %v", av)
+	}
+	if marker := method.Annotation("LMarker;"); marker == nil || marker.Visible {
+		t.Errorf("unexpected method annotation %v", marker)
+	}
+	if method.HasParameterAnnotation(0, "LAnno;") || !method.HasParameterAnnotation(1, "LAnno;") || method.HasParameterAnnotation(2, "LAnno;") {
+		t.Error("unexpected parameter annotations")
+	}
+
+	annoType := &ClassFile{
+		ConstantPool: pool,
+		AccessFlags: ACCINTERFACE | ACCABSTRACT | ACCANNOTATION,
+		ThisClass: class("Anno"),
+		Methods: []*Method{
+			{MethodAccPublic | MethodAccAbstract, utf8("value"), utf8("()I"), []Attr{&AttrAnnotationDefault{&ElementValInt{integer(1)}}}},
+			{MethodAccPublic | MethodAccAbstract, utf8("flag"), utf8("()Z"), []Attr{&AttrAnnotationDefault{&ElementValBoolean{integer(1)}}}},
+			{MethodAccPublic | MethodAccAbstract, utf8("required"), utf8("()J"), nil},
+		},
+	}
+	if err := av.MergeDefaults(annoType); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := av.Get("value"); v != int32(3) {
+		t.Errorf("explicit value overridden by default: %v", v)
+	}
+	if v, ok := av.Get("flag"); !ok || v != true {
+		t.Errorf("missing default flag: %v", v)
+	}
+	if _, ok := av.Get("required"); ok {
+		t.Error("unexpected value for element without default")
+	}
+	if err := av.MergeDefaults(cf); err == nil {
+		t.Error("expected error merging defaults from Foo")
+	}
+}
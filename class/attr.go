@@ -2,7 +2,9 @@ package class
 
 import (
 	"io"
+	"io/ioutil"
 	"encoding/binary"
+	"errors"
 	"fmt"
 )
 
@@ -30,6 +32,14 @@ const (
 	RuntimeVisibleTypeAnnotations = "RuntimeVisibleTypeAnnotations"
 	RuntimeInvisibleTypeAnnotations = "RuntimeInvisibleTypeAnnotations"
 	MethodParameters = "MethodParameters"
+	// 常量 Module 已被 CONSTANT_Module 占用
+	ModuleAttr = "Module"
+	ModulePackages = "ModulePackages"
+	ModuleMainClass = "ModuleMainClass"
+	NestHost = "NestHost"
+	NestMembers = "NestMembers"
+	Record = "Record"
+	PermittedSubclasses = "PermittedSubclasses"
 )
 
 type Attr interface {
@@ -37,20 +47,71 @@ type Attr interface {
 	String() string
 }
 
+// 错误中的偏移量相对于 reader 的起始位置
 func ReadAttr(reader io.Reader, pool *ConstantPool) (Attr, error) {
+	return readAttr(reader, pool, nil)
+}
+
+// skip 返回true的属性按 attribute_length 跳过，返回nil
+func readAttr(reader io.Reader, pool *ConstantPool, skip func(name string) bool) (Attr, error) {
+	if _, ok := reader.(*offsetReader); !ok {
+		reader = &offsetReader{reader: reader}
+	}
 	var attrNameIndex uint16
 	if err := binary.Read(reader, binary.BigEndian, &attrNameIndex); err != nil {
-		return nil, err
+		return nil, formatError(reader, err, "attribute_name_index")
 	}
 	attrName, err := pool.GetUTF8String(attrNameIndex)
 	if err != nil {
-		return nil, err
+		return nil, formatError(reader, err, "attribute_name_index")
+	}
+	if attrName == nil {
+		return nil, formatError(reader, errors.New("attribute name index is 0"), "attribute_name_index")
 	}
 	var attrLength uint32
 	if err := binary.Read(reader, binary.BigEndian, &attrLength); err != nil {
+		return nil, formatError(reader, err, "(%s).attribute_length", attrName)
+	}
+	// 属性内容只能从 attribute_length 范围内读取
+	limitReader := &io.LimitedReader{R: reader, N: int64(attrLength)}
+	info := &offsetReader{reader: limitReader, offset: readerOffset(reader)}
+	if skip != nil && skip(attrName.String()) {
+		if _, err := io.Copy(ioutil.Discard, info); err != nil {
+			return nil, formatError(info, err, "(%s)", attrName)
+		}
+		if limitReader.N > 0 {
+			return nil, formatError(info, io.ErrUnexpectedEOF, "(%s)", attrName)
+		}
+		return nil, nil
+	}
+	attr, err := readAttrInfo(attrName.String(), attrLength, info, pool)
+	if err != nil {
+		return nil, formatError(info, err, "(%s)", attrName)
+	}
+	if limitReader.N > 0 {
+		err = fmt.Errorf("attribute_length is %d but only %d bytes were decoded", attrLength, int64(attrLength) - limitReader.N)
+		return nil, formatError(info, err, "(%s)", attrName)
+	}
+	return attr, nil
+}
+
+// 读取n个字节，不按n预先分配内存，避免畸形的长度导致巨大的内存分配
+func readBytes(reader io.Reader, n uint32) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(reader, int64(n)))
+	if err != nil {
 		return nil, err
 	}
-	switch attrName.String() {
+	if len(b) != int(n) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+func readAttrInfo(attrName string, attrLength uint32, reader io.Reader, pool *ConstantPool) (Attr, error) {
+	if decoder := lookupAttrDecoder(attrName); decoder != nil {
+		return decodeAttr(decoder, attrName, reader, pool)
+	}
+	switch attrName {
 	case ConstantValue:
 		return NewAttrConstantValue(reader, pool)
 	case Code:
@@ -97,8 +158,22 @@ func ReadAttr(reader io.Reader, pool *ConstantPool) (Attr, error) {
 		return NewAttrSourceFile(reader, pool)
 	case Synthetic:
 		return NewAttrSynthetic()
-	}
-	return nil, fmt.Errorf("%s unknow name", attrName.String())
+	case ModuleAttr:
+		return NewAttrModule(reader, pool)
+	case ModulePackages:
+		return NewAttrModulePackages(reader, pool)
+	case ModuleMainClass:
+		return NewAttrModuleMainClass(reader, pool)
+	case NestHost:
+		return NewAttrNestHost(reader, pool)
+	case NestMembers:
+		return NewAttrNestMembers(reader, pool)
+	case Record:
+		return NewAttrRecord(reader, pool)
+	case PermittedSubclasses:
+		return NewAttrPermittedSubclasses(reader, pool)
+	}
+	return NewAttrUnknown(attrName, attrLength, reader)
 }
 
 type AttrConstantValue struct {
@@ -175,8 +250,8 @@ func NewAttrCode(io io.Reader, pool *ConstantPool) (*AttrCode, error) {
 	if err := binary.Read(io, binary.BigEndian, &codeLength); err != nil {
 		return nil, err
 	}
-	code.Code = make([]byte, codeLength)
-	if err := binary.Read(io, binary.BigEndian, &code.Code); err != nil {
+	var err error
+	if code.Code, err = readBytes(io, codeLength); err != nil {
 		return nil, err
 	}
 	var exceptionLen uint16
@@ -186,7 +261,7 @@ func NewAttrCode(io io.Reader, pool *ConstantPool) (*AttrCode, error) {
 	for i := 0; i < int(exceptionLen); i++ {
 		ex, err := NewException(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "exception_table[%d]", i)
 		}
 		code.ExceptionTable = append(code.ExceptionTable, ex)
 	}
@@ -197,7 +272,7 @@ func NewAttrCode(io io.Reader, pool *ConstantPool) (*AttrCode, error) {
 	for i := 0; i < int(attrCount); i++ {
 		attr, err := ReadAttr(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "attributes[%d]", i)
 		}
 		code.Attrs = append(code.Attrs, attr)
 	}
@@ -225,7 +300,7 @@ func NewAttrStackMapTable(io io.Reader, pool *ConstantPool) (*AttrStackMapTable,
 	for i := 0; i < int(numberOfEntries); i++ {
 		s, err := NewStackMapFrame(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "entries[%d]", i)
 		}
 		stackMapTable.Entries = append(stackMapTable.Entries, s)
 	}
@@ -253,11 +328,11 @@ func NewAttrExceptions(io io.Reader, pool *ConstantPool) (*AttrExceptions, error
 	var exIndex uint16
 	for i := 0; i < int(numberOfExceptions); i++ {
 		if err := binary.Read(io, binary.BigEndian, &exIndex); err != nil {
-			return nil, err
+			return nil, formatError(io, err, "exception_index_table[%d]", i)
 		}
 		exEntry, err := pool.GetClass(exIndex)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "exception_index_table[%d]", i)
 		}
 		ex.ExceptionTable = append(ex.ExceptionTable, exEntry)
 	}
@@ -276,7 +351,7 @@ type Classes struct {
 	InnerClass *ConstClass
 	OuterClass *ConstClass
 	InnerName *ConstUTF8
-	InnerClassAccessFlags uint16
+	InnerClassAccessFlags InnerClassAccessFlags
 }
 
 func NewClasses(io io.Reader, pool *ConstantPool) (*Classes, error) {
@@ -320,7 +395,7 @@ func NewAttrInnerClasses(io io.Reader, pool *ConstantPool) (*AttrInnerClasses, e
 	for i := 0; i < int(numberOfClasses); i++ {
 		classes, err := NewClasses(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "classes[%d]", i)
 		}
 		a.Classes = append(a.Classes, classes)
 	}
@@ -402,6 +477,21 @@ func (c *AttrSignature) Name() string {
 	return Signature
 }
 
+// 解析为类签名，用于 ClassFile 的 Signature 属性
+func (c *AttrSignature) ClassSignature() (*ClassSignature, error) {
+	return ParseClassSignature(utf8String(c.Signature))
+}
+
+// 解析为方法签名，用于 Method 的 Signature 属性
+func (c *AttrSignature) MethodSignature() (*MethodSignature, error) {
+	return ParseMethodSignature(utf8String(c.Signature))
+}
+
+// 解析为字段签名，用于 Field 和 RecordComponent 的 Signature 属性
+func (c *AttrSignature) FieldSignature() (TypeSignature, error) {
+	return ParseFieldSignature(utf8String(c.Signature))
+}
+
 func (c *AttrSignature) String() string {
 	return fmt.Sprintf("AttrSignature ->  %v", *c)
 }
@@ -436,11 +526,11 @@ type AttrSourceDebugExtension struct {
 }
 
 func NewAttrSourceDebugExtension(len uint32, io io.Reader) (*AttrSourceDebugExtension, error) {
-	sde := &AttrSourceDebugExtension{DebugExtension:make([]byte, len)}
-	if err := binary.Read(io, binary.BigEndian, sde.DebugExtension); err != nil {
+	debugExtension, err := readBytes(io, len)
+	if err != nil {
 		return nil, err
 	}
-	return sde, nil
+	return &AttrSourceDebugExtension{DebugExtension: debugExtension}, nil
 }
 
 func (c *AttrSourceDebugExtension) Name() string {
@@ -483,7 +573,7 @@ func NewAttrLineNumberTable(io io.Reader) (*AttrLineNumberTable, error) {
 	for i := 0; i < int(lineNumberTableLen); i++ {
 		entry, err := NewLineNumberTableEntry(io)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "line_number_table[%d]", i)
 		}
 		lnt.LineNumberTable = append(lnt.LineNumberTable, entry)
 	}
@@ -547,7 +637,7 @@ func NewAttrLocalVariableTable(io io.Reader, pool *ConstantPool) (*AttrLocalVari
 	for i := 0; i < int(localVarTableEntryLen); i++ {
 		entry, err := NewLocalVarTableEntry(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "local_variable_table[%d]", i)
 		}
 		lvt.LocalVarTable = append(lvt.LocalVarTable, entry)
 	}
@@ -611,7 +701,7 @@ func NewAttrLocalVariableTypeTable(io io.Reader, pool *ConstantPool) (*AttrLocal
 	for i := 0; i < int(len); i++ {
 		lvtte, err := NewLocalVarTypeTableEntry(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "local_variable_type_table[%d]", i)
 		}
 		lvtt.LocalVarTypeTable = append(lvtt.LocalVarTypeTable, lvtte)
 	}
@@ -653,7 +743,7 @@ func NewAttrRuntimeVisibleAnnotations(io io.Reader, pool *ConstantPool) (*AttrRu
 	for i := 0; i < int(n); i++ {
 		annotation, err := NewAnnotation(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "annotations[%d]", i)
 		}
 		rva.Annotations = append(rva.Annotations, annotation)
 	}
@@ -681,7 +771,7 @@ func NewAttrRuntimeInvisibleAnnotations(io io.Reader, pool *ConstantPool) (*Attr
 	for i := 0; i < int(n); i++ {
 		annotation, err := NewAnnotation(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "annotations[%d]", i)
 		}
 		rva.Annotations = append(rva.Annotations, annotation)
 	}
@@ -709,7 +799,7 @@ func NewParameterAnnotation(io io.Reader, pool *ConstantPool) (*ParameterAnnotat
 	for i := 0; i < int(numAnnotations); i++ {
 		annotation, err := NewAnnotation(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "annotations[%d]", i)
 		}
 		pa.Annotations = append(pa.Annotations, annotation)
 	}
@@ -733,7 +823,7 @@ func NewAttrRuntimeVisibleParameterAnnotations(io io.Reader, pool *ConstantPool)
 	for i := 0; i < int(numParameters); i++ {
 		pa, err := NewParameterAnnotation(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "parameter_annotations[%d]", i)
 		}
 		rvpa.ParameterAnnotations = append(rvpa.ParameterAnnotations, pa)
 	}
@@ -761,7 +851,7 @@ func NewAttrRuntimeInvisibleParameterAnnotations(io io.Reader, pool *ConstantPoo
 	for i := 0; i < int(numParameters); i++ {
 		pa, err := NewParameterAnnotation(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "parameter_annotations[%d]", i)
 		}
 		rvpa.ParameterAnnotations = append(rvpa.ParameterAnnotations, pa)
 	}
@@ -789,7 +879,7 @@ func NewAttrRuntimeVisibleTypeAnnotations(io io.Reader, pool *ConstantPool) (*At
 	for i := 0; i < int(numAnnotations); i++ {
 		typeAnnotation, err := NewTypeAnnotation(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "annotations[%d]", i)
 		}
 		rvta.Annotations = append(rvta.Annotations, typeAnnotation)
 	}
@@ -817,7 +907,7 @@ func NewAttrRuntimeInvisibleTypeAnnotations(io io.Reader, pool *ConstantPool) (*
 	for i := 0; i < int(numAnnotations); i++ {
 		typeAnnotation, err := NewTypeAnnotation(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "annotations[%d]", i)
 		}
 		rvta.Annotations = append(rvta.Annotations, typeAnnotation)
 	}
@@ -874,11 +964,11 @@ func NewBootstrapMethod(io io.Reader, pool *ConstantPool) (*BootstrapMethod, err
 	}
 	for i := 0; i < int(numBootstrapArguments); i++ {
 		if err := binary.Read(io, binary.BigEndian, &bootstrapArgumentsIndex); err != nil {
-			return nil, err
+			return nil, formatError(io, err, "bootstrap_arguments[%d]", i)
 		}
 		constant, err := pool.Get(bootstrapArgumentsIndex)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "bootstrap_arguments[%d]", i)
 		}
 		bsm.BootstrapArguments = append(bsm.BootstrapArguments, constant)
 	}
@@ -898,7 +988,7 @@ func NewAttrBootstrapMethods(io io.Reader, pool *ConstantPool) (*AttrBootstrapMe
 	for i := 0; i < int(numBootstrapMethods); i++ {
 		bsm, err := NewBootstrapMethod(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "bootstrap_methods[%d]", i)
 		}
 		bm.BootstrapMethods = append(bm.BootstrapMethods, bsm)
 	}
@@ -915,7 +1005,7 @@ func (c *AttrBootstrapMethods) String() string {
 
 type Parameter struct {
 	Name *ConstUTF8
-	AccessFlags uint16
+	AccessFlags ParameterAccessFlags
 }
 
 func NewParameter(io io.Reader, pool *ConstantPool) (*Parameter, error) {
@@ -947,7 +1037,7 @@ func NewAttrMethodParameters(io io.Reader, pool *ConstantPool) (*AttrMethodParam
 	for i := 0; i < int(parametersCount); i++ {
 		p, err := NewParameter(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "parameters[%d]", i)
 		}
 		mp.Parameters = append(mp.Parameters, p)
 	}
@@ -960,4 +1050,155 @@ func (c *AttrMethodParameters) Name() string {
 
 func (c *AttrMethodParameters) String() string {
 	return fmt.Sprintf("AttrMethodParameters ->  %v", *c)
-}
\ No newline at end of file
+}
+
+type AttrNestHost struct {
+	HostClass *ConstClass
+}
+
+func NewAttrNestHost(io io.Reader, pool *ConstantPool) (*AttrNestHost, error) {
+	var hostClassIndex uint16
+	if err := binary.Read(io, binary.BigEndian, &hostClassIndex); err != nil {
+		return nil, err
+	}
+	nh := &AttrNestHost{}
+	var err error
+	if nh.HostClass, err = pool.GetClass(hostClassIndex); err != nil {
+		return nil, err
+	}
+	return nh, nil
+}
+
+func (c *AttrNestHost) Name() string {
+	return NestHost
+}
+
+func (c *AttrNestHost) String() string {
+	return fmt.Sprintf("AttrNestHost ->  %v", *c)
+}
+
+func readClasses(io io.Reader, pool *ConstantPool) ([]*ConstClass, error) {
+	var numberOfClasses, classIndex uint16
+	if err := binary.Read(io, binary.BigEndian, &numberOfClasses); err != nil {
+		return nil, err
+	}
+	var classes []*ConstClass
+	for i := 0; i < int(numberOfClasses); i++ {
+		if err := binary.Read(io, binary.BigEndian, &classIndex); err != nil {
+			return nil, formatError(io, err, "classes[%d]", i)
+		}
+		class, err := pool.GetClass(classIndex)
+		if err != nil {
+			return nil, formatError(io, err, "classes[%d]", i)
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+type AttrNestMembers struct {
+	Classes []*ConstClass
+}
+
+func NewAttrNestMembers(io io.Reader, pool *ConstantPool) (*AttrNestMembers, error) {
+	classes, err := readClasses(io, pool)
+	if err != nil {
+		return nil, err
+	}
+	return &AttrNestMembers{Classes: classes}, nil
+}
+
+func (c *AttrNestMembers) Name() string {
+	return NestMembers
+}
+
+func (c *AttrNestMembers) String() string {
+	return fmt.Sprintf("AttrNestMembers ->  %v", *c)
+}
+
+type RecordComponent struct {
+	Name *ConstUTF8
+	Descriptor *ConstUTF8
+	Attrs []Attr
+}
+
+func NewRecordComponent(io io.Reader, pool *ConstantPool) (*RecordComponent, error) {
+	var nameIndex, descriptorIndex, attrCount uint16
+	if err := binary.Read(io, binary.BigEndian, &nameIndex); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(io, binary.BigEndian, &descriptorIndex); err != nil {
+		return nil, err
+	}
+	rc := &RecordComponent{}
+	var err error
+	if rc.Name, err = pool.GetUTF8String(nameIndex); err != nil {
+		return nil, err
+	}
+	if rc.Descriptor, err = pool.GetUTF8String(descriptorIndex); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(io, binary.BigEndian, &attrCount); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(attrCount); i++ {
+		attr, err := ReadAttr(io, pool)
+		if err != nil {
+			return nil, formatError(io, err, "attributes[%d]", i)
+		}
+		rc.Attrs = append(rc.Attrs, attr)
+	}
+	return rc, nil
+}
+
+func (rc *RecordComponent) String() string {
+	return fmt.Sprintf("RecordComponent -> %v", *rc)
+}
+
+type AttrRecord struct {
+	Components []*RecordComponent
+}
+
+func NewAttrRecord(io io.Reader, pool *ConstantPool) (*AttrRecord, error) {
+	var componentsCount uint16
+	if err := binary.Read(io, binary.BigEndian, &componentsCount); err != nil {
+		return nil, err
+	}
+	r := &AttrRecord{}
+	for i := 0; i < int(componentsCount); i++ {
+		rc, err := NewRecordComponent(io, pool)
+		if err != nil {
+			return nil, formatError(io, err, "components[%d]", i)
+		}
+		r.Components = append(r.Components, rc)
+	}
+	return r, nil
+}
+
+func (c *AttrRecord) Name() string {
+	return Record
+}
+
+func (c *AttrRecord) String() string {
+	return fmt.Sprintf("AttrRecord ->  %v", *c)
+}
+
+type AttrPermittedSubclasses struct {
+	Classes []*ConstClass
+}
+
+func NewAttrPermittedSubclasses(io io.Reader, pool *ConstantPool) (*AttrPermittedSubclasses, error) {
+	classes, err := readClasses(io, pool)
+	if err != nil {
+		return nil, err
+	}
+	return &AttrPermittedSubclasses{Classes: classes}, nil
+}
+
+func (c *AttrPermittedSubclasses) Name() string {
+	return PermittedSubclasses
+}
+
+func (c *AttrPermittedSubclasses) String() string {
+	return fmt.Sprintf("AttrPermittedSubclasses ->  %v", *c)
+}
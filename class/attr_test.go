@@ -0,0 +1,82 @@
+package class
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReadAttrLengthNotConsumed(t *testing.T) {
+	pool := newTestAttrPool(t, ConstantValue, "s", Code)
+	buf := &testWriter{}
+	buf.write(uint16(1))
+	buf.write(uint32(4))
+	buf.write([]uint16{2, 0})
+
+	_, err := ReadAttr(buf, pool)
+	var cfe *ClassFormatError
+	if !errors.As(err, &cfe) || cfe.Path != "(ConstantValue)" || cfe.Offset != 8 ||
+		!strings.Contains(err.Error(), "attribute_length is 4 but only 2 bytes were decoded") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// 属性内容超出 attribute_length
+	buf = &testWriter{}
+	buf.write(uint16(1))
+	buf.write(uint32(1))
+	buf.write([]uint16{2, 0})
+	if _, err := ReadAttr(buf, pool); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestReadAttrHugeCodeLength(t *testing.T) {
+	pool := newTestAttrPool(t, Code)
+	buf := &testWriter{}
+	buf.write(uint16(1))
+	buf.write(uint32(0xffffffff))
+	buf.write([]uint16{1, 1})
+	buf.write(uint32(0xffffffff))
+	buf.write(uint8(0xb1))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := ReadAttr(buf, pool)
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1 << 20 {
+		t.Errorf("allocated %d bytes", n)
+	}
+}
+
+func TestReadAttrTypeAnnotationTargets(t *testing.T) {
+	pool := newTestAttrPool(t, RuntimeVisibleTypeAnnotations, "LA;")
+	buf := &testWriter{}
+	buf.write(uint16(1))
+	buf.write(uint32(18))
+	buf.write(uint16(2))
+	// type_parameter_bound_target
+	buf.write([]uint8{0x11, 1, 2, 0})
+	buf.write([]uint16{2, 0})
+	// throws_target
+	buf.write(uint8(0x17))
+	buf.write(uint16(0x102))
+	buf.write(uint8(0))
+	buf.write([]uint16{2, 0})
+
+	attr, err := ReadAttr(buf, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotations := attr.(*AttrRuntimeVisibleTypeAnnotations).Annotations
+	if bound, ok := annotations[0].Target.(*TypeParameterBoundTarget); !ok || bound.TypeParameterIndex != 1 || bound.BoundIndex != 2 {
+		t.Errorf("unexpected target %v", annotations[0].Target)
+	}
+	if throws, ok := annotations[1].Target.(*ThrowsTarget); !ok || throws.ThrowsTypeIndex != 0x102 {
+		t.Errorf("unexpected target %v", annotations[1].Target)
+	}
+}
@@ -0,0 +1,70 @@
+package class
+
+import (
+	"io"
+	"io/ioutil"
+	"fmt"
+	"sync"
+)
+
+// 自定义属性解码器
+// reader 被限制在 attribute_length 范围内，解码器没有读完的字节会被丢弃，
+// 因此不会影响后续内容的解析
+type AttrDecoder func(name string, reader io.Reader, pool *ConstantPool) (Attr, error)
+
+var (
+	attrDecodersLock sync.RWMutex
+	attrDecoders = map[string]AttrDecoder{}
+)
+
+// 按属性名注册解码器，注册的解码器优先于内置解码器
+// decoder 为nil时取消注册
+func RegisterAttrDecoder(name string, decoder AttrDecoder) {
+	attrDecodersLock.Lock()
+	defer attrDecodersLock.Unlock()
+	if decoder == nil {
+		delete(attrDecoders, name)
+		return
+	}
+	attrDecoders[name] = decoder
+}
+
+func lookupAttrDecoder(name string) AttrDecoder {
+	attrDecodersLock.RLock()
+	defer attrDecodersLock.RUnlock()
+	return attrDecoders[name]
+}
+
+// reader 已被 ReadAttr 限制在 attribute_length 范围内
+func decodeAttr(decoder AttrDecoder, name string, reader io.Reader, pool *ConstantPool) (Attr, error) {
+	attr, err := decoder(name, reader, pool)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		return nil, err
+	}
+	return attr, nil
+}
+
+// 无法识别的属性，保留属性名和原始字节
+type AttrUnknown struct {
+	name string
+	Info []byte
+}
+
+func NewAttrUnknown(name string, attrLength uint32, reader io.Reader) (*AttrUnknown, error) {
+	info, err := readBytes(reader, attrLength)
+	if err != nil {
+		return nil, err
+	}
+	return &AttrUnknown{name: name, Info: info}, nil
+}
+
+func (c *AttrUnknown) Name() string {
+	return c.name
+}
+
+func (c *AttrUnknown) String() string {
+	return fmt.Sprintf("AttrUnknown -> {%s %d bytes}", c.name, len(c.Info))
+}
@@ -0,0 +1,98 @@
+package class
+
+import (
+	"testing"
+	"io"
+	"encoding/binary"
+	"bytes"
+)
+
+type testVendorAttr struct {
+	Version uint16
+}
+
+func (*testVendorAttr) Name() string {
+	return "VendorVersion"
+}
+
+func (c *testVendorAttr) String() string {
+	return "testVendorAttr"
+}
+
+func newTestAttrPool(t *testing.T, names ...string) *ConstantPool {
+	buf := &testWriter{}
+	buf.write(uint16(len(names) + 1))
+	for _, name := range names {
+		buf.writeUTF8(name)
+	}
+	pool, err := NewConstantPool(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func TestReadAttrUnknown(t *testing.T) {
+	pool := newTestAttrPool(t, "kotlin.Metadata", SourceFile, "A.kt")
+	buf := &testWriter{}
+	buf.write(uint16(1))
+	buf.write(uint32(3))
+	buf.Write([]byte{1, 2, 3})
+	buf.write([]uint16{2, 0, 2, 3})
+
+	attr, err := ReadAttr(buf, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, ok := attr.(*AttrUnknown)
+	if !ok || unknown.Name() != "kotlin.Metadata" || !bytes.Equal(unknown.Info, []byte{1, 2, 3}) {
+		t.Errorf("unexpected attr %v", attr)
+	}
+	if attr, err = ReadAttr(buf, pool); err != nil {
+		t.Fatal(err)
+	}
+	if sf, ok := attr.(*AttrSourceFile); !ok || sf.SourceFile.String() != "A.kt" {
+		t.Errorf("unexpected attr %v", attr)
+	}
+
+	buf = &testWriter{}
+	buf.write(uint16(1))
+	buf.write(uint32(100))
+	buf.Write([]byte{1, 2, 3})
+	if _, err := ReadAttr(buf, pool); err == nil {
+		t.Error("expected truncated attribute error")
+	}
+}
+
+func TestRegisterAttrDecoder(t *testing.T) {
+	RegisterAttrDecoder("VendorVersion", func(name string, reader io.Reader, pool *ConstantPool) (Attr, error) {
+		attr := &testVendorAttr{}
+		// 只读取了一部分，剩余的字节应当被跳过
+		if err := binary.Read(reader, binary.BigEndian, &attr.Version); err != nil {
+			return nil, err
+		}
+		return attr, nil
+	})
+	defer RegisterAttrDecoder("VendorVersion", nil)
+
+	pool := newTestAttrPool(t, "VendorVersion", SourceFile, "A.java")
+	buf := &testWriter{}
+	buf.write(uint16(1))
+	buf.write(uint32(6))
+	buf.write([]uint16{7, 0xffff, 0xffff})
+	buf.write([]uint16{2, 0, 2, 3})
+
+	attr, err := ReadAttr(buf, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vendor, ok := attr.(*testVendorAttr); !ok || vendor.Version != 7 {
+		t.Errorf("unexpected attr %v", attr)
+	}
+	if attr, err = ReadAttr(buf, pool); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := attr.(*AttrSourceFile); !ok {
+		t.Errorf("unexpected attr %v", attr)
+	}
+}
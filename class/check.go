@@ -0,0 +1,537 @@
+package class
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 格式检查发现的全部问题
+type CheckErrors []*ClassFormatError
+
+func (errs CheckErrors) Error() string {
+	s := make([]string, len(errs))
+	for i, err := range errs {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+func (errs CheckErrors) Is(target error) bool {
+	return target == ClassFileFormatError
+}
+
+// 属性可以出现的位置 (JVMS 4.7 Table 4.7-C)
+const (
+	inClass = 1 << iota
+	inField
+	inMethod
+	inCode
+	inRecordComponent
+)
+
+const inAnnotated = inClass | inField | inMethod | inRecordComponent
+
+var attrLocations = map[string]int{
+	ConstantValue: inField,
+	Code: inMethod,
+	StackMapTable: inCode,
+	Exceptions: inMethod,
+	InnerClasses: inClass,
+	EnclosingMethod: inClass,
+	Synthetic: inClass | inField | inMethod,
+	Signature: inAnnotated,
+	SourceFile: inClass,
+	SourceDebugExtension: inClass,
+	LineNumberTable: inCode,
+	LocalVariableTable: inCode,
+	LocalVariableTypeTable: inCode,
+	Deprecated: inClass | inField | inMethod,
+	RuntimeVisibleAnnotations: inAnnotated,
+	RuntimeInvisibleAnnotations: inAnnotated,
+	RuntimeVisibleParameterAnnotations: inMethod,
+	RuntimeInvisibleParameterAnnotations: inMethod,
+	RuntimeVisibleTypeAnnotations: inAnnotated | inCode,
+	RuntimeInvisibleTypeAnnotations: inAnnotated | inCode,
+	AnnotationDefault: inMethod,
+	BootstrapMethods: inClass,
+	MethodParameters: inMethod,
+	ModuleAttr: inClass,
+	ModulePackages: inClass,
+	ModuleMainClass: inClass,
+	NestHost: inClass,
+	NestMembers: inClass,
+	Record: inClass,
+	PermittedSubclasses: inClass,
+}
+
+// 同一个属性表中可以出现多次的预定义属性
+var repeatableAttrs = map[string]bool{
+	LineNumberTable: true,
+	LocalVariableTable: true,
+	LocalVariableTypeTable: true,
+	Synthetic: true,
+	Deprecated: true,
+}
+
+// 各种常量最早出现的class文件主版本号
+var constantMinMajor = map[int]uint16{
+	MethodHandle: 51,
+	MethodType: 51,
+	InvokeDynamic: 51,
+	Module: 53,
+	Package: 53,
+	Dynamic: 55,
+}
+
+type checker struct {
+	classfile *ClassFile
+	className string
+	errs CheckErrors
+}
+
+// 按 JVMS 4.8 检查已解析的class文件，不在第一个错误处停止，
+// 返回 CheckErrors 包含发现的全部问题，没有问题时返回nil
+func Check(classfile *ClassFile) error {
+	c := &checker{classfile: classfile, className: classfile.Name()}
+	c.checkConstantPool()
+	c.checkClass()
+	c.checkFields()
+	c.checkMethods()
+	c.checkAttrs("", classfile.Attrs, inClass)
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return c.errs
+}
+
+func (c *checker) report(path string, format string, args ...interface{}) {
+	c.errs = append(c.errs, &ClassFormatError{
+		ClassName: c.className,
+		Offset: -1,
+		Path: path,
+		Err: fmt.Errorf(format, args...),
+	})
+}
+
+func (c *checker) isInterface() bool {
+	return c.classfile.AccessFlags & ACCINTERFACE != 0
+}
+
+func (c *checker) isModule() bool {
+	return c.classfile.AccessFlags & ACCMODULE != 0
+}
+
+func joinPath(path, segment string) string {
+	if path == "" || strings.HasPrefix(segment, "(") {
+		return path + segment
+	}
+	return path + "." + segment
+}
+
+// 检查非限定名 (JVMS 4.2.2)，返回错误原因
+func checkUnqualifiedName(name string, method bool) string {
+	if name == "" {
+		return "empty name"
+	}
+	if method && (name == "<init>" || name == "<clinit>") {
+		return ""
+	}
+	illegal := ".;[/"
+	if method {
+		illegal += "<>"
+	}
+	if strings.ContainsAny(name, illegal) {
+		return fmt.Sprintf("illegal character in name %q", name)
+	}
+	return ""
+}
+
+func (c *checker) checkFieldName(path string, name string) {
+	if reason := checkUnqualifiedName(name, false); reason != "" {
+		c.report(path, "%s", reason)
+	}
+}
+
+func (c *checker) checkMethodName(path string, name string) {
+	if reason := checkUnqualifiedName(name, true); reason != "" {
+		c.report(path, "%s", reason)
+	}
+}
+
+func (c *checker) checkFieldDescriptor(path string, desc string) *FieldType {
+	t, err := ParseFieldDescriptor(desc)
+	if err != nil {
+		c.report(path, "%v", err)
+	}
+	return t
+}
+
+func (c *checker) checkMethodDescriptor(path string, desc string) *MethodDescriptor {
+	m, err := ParseMethodDescriptor(desc)
+	if err != nil {
+		c.report(path, "%v", err)
+	}
+	return m
+}
+
+// CONSTANT_Class 中的类名可以是数组描述符
+func (c *checker) checkClassName(path string, name string) {
+	if strings.HasPrefix(name, "[") {
+		c.checkFieldDescriptor(path, name)
+		return
+	}
+	if reason := checkClassName(name); reason != "" {
+		c.report(path, "%s", reason)
+	}
+}
+
+func (c *checker) checkConstantPool() {
+	pool := c.classfile.ConstantPool
+	if pool == nil {
+		c.report("constant_pool", "missing constant pool")
+		return
+	}
+	for i := 1; i < pool.Length(); i++ {
+		constant := pool.pool[i]
+		if constant == nil {
+			continue
+		}
+		path := fmt.Sprintf("constant_pool[%d](%s)", i, ConstantKindName(constant.Tag()))
+		if major, ok := constantMinMajor[constant.Tag()]; ok && c.classfile.Major < major {
+			c.report(path, "constant requires class file version %d, got %d", major, c.classfile.Major)
+		}
+		switch constant := constant.(type) {
+		case *ConstClass:
+			c.checkClassName(path, utf8String(constant.Name))
+		case *ConstFieldRef:
+			c.checkRef(path, FieldRef, constant)
+		case *ConstMethodRef:
+			c.checkRef(path, MethodRef, constant.ConstFieldRef)
+		case *ConstInterfaceMethodRef:
+			c.checkRef(path, InterfaceMethodRef, constant.ConstFieldRef)
+		case *ConstMethodHandle:
+			c.checkMethodHandle(path, constant)
+		case *ConstMethodType:
+			c.checkMethodDescriptor(path, utf8String(constant.Descriptor))
+		case *ConstDynamic:
+			if c.checkBootstrap(path, constant.BootstrapMethodAttrIndex, constant.NameAndType) {
+				c.checkFieldName(path, utf8String(constant.NameAndType.Name))
+				c.checkFieldDescriptor(path, utf8String(constant.NameAndType.Descriptor))
+			}
+		case *ConstInvokeDynamic:
+			if c.checkBootstrap(path, constant.BootstrapMethodAttrIndex, constant.NameAndType) {
+				name := utf8String(constant.NameAndType.Name)
+				if reason := checkUnqualifiedName(name, true); reason != "" || strings.HasPrefix(name, "<") {
+					c.report(path, "illegal invokedynamic name %q", name)
+				}
+				c.checkMethodDescriptor(path, utf8String(constant.NameAndType.Descriptor))
+			}
+		case *ConstModule, *ConstPackage:
+			if !c.isModule() {
+				c.report(path, "constant is only allowed in module-info")
+			}
+		}
+	}
+}
+
+func (c *checker) checkBootstrap(path string, index uint16, nat *ConstNameAndType) bool {
+	if int(index) >= len(c.classfile.BootstrapMethods()) {
+		c.report(path, "bootstrap_method_attr_index %d out of range", index)
+	}
+	if nat == nil {
+		c.report(path, "name_and_type_index is 0")
+		return false
+	}
+	return true
+}
+
+// 检查 Fieldref、Methodref 和 InterfaceMethodref
+func (c *checker) checkRef(path string, tag int, ref *ConstFieldRef) {
+	if ref.Class == nil {
+		c.report(path, "class_index is 0")
+	}
+	if ref.NameAndType == nil {
+		c.report(path, "name_and_type_index is 0")
+		return
+	}
+	name := utf8String(ref.NameAndType.Name)
+	desc := utf8String(ref.NameAndType.Descriptor)
+	if tag == FieldRef {
+		c.checkFieldName(path, name)
+		c.checkFieldDescriptor(path, desc)
+		return
+	}
+	c.checkMethodName(path, name)
+	m := c.checkMethodDescriptor(path, desc)
+	switch {
+	case name == "<clinit>":
+		c.report(path, "<clinit> can not be referenced")
+	case name == "<init>" && tag == InterfaceMethodRef:
+		c.report(path, "interface method can not be <init>")
+	case name == "<init>" && m != nil && m.Return.Kind != TypeVoid:
+		c.report(path, "<init> must return void")
+	}
+}
+
+// reference_kind 决定了 reference_index 指向的常量类型 (JVMS 4.4.8)
+func (c *checker) checkMethodHandle(path string, mh *ConstMethodHandle) {
+	ref, ok := mh.Ref.(Constant)
+	if !ok {
+		c.report(path, "reference_index is 0")
+		return
+	}
+	tag := ref.Tag()
+	var expected string
+	switch mh.RefKind {
+	case RefGetField, RefGetStatic, RefPutField, RefPutStatic:
+		if tag != FieldRef {
+			expected = "Fieldref"
+		}
+	case RefInvokeVirtual, RefNewInvokeSpecial:
+		if tag != MethodRef {
+			expected = "Methodref"
+		}
+	case RefInvokeStatic, RefInvokeSpecial:
+		if c.classfile.Major < 52 && tag != MethodRef {
+			expected = "Methodref"
+		} else if tag != MethodRef && tag != InterfaceMethodRef {
+			expected = "Methodref or InterfaceMethodref"
+		}
+	case RefInvokeInterface:
+		if tag != InterfaceMethodRef {
+			expected = "InterfaceMethodref"
+		}
+	}
+	if expected != "" {
+		c.errs = append(c.errs, &ClassFormatError{
+			ClassName: c.className,
+			Offset: -1,
+			Path: path,
+			Expected: expected,
+			Actual: ConstantKindName(tag),
+			Err: fmt.Errorf("reference_kind %d", mh.RefKind),
+		})
+		return
+	}
+	if tag == FieldRef || mh.Ref.GetNameAndType() == nil {
+		return
+	}
+	name := utf8String(mh.Ref.GetNameAndType().Name)
+	if mh.RefKind == RefNewInvokeSpecial {
+		if name != "<init>" {
+			c.report(path, "reference_kind %d must refer to <init>, got %q", mh.RefKind, name)
+		}
+	} else if name == "<init>" || name == "<clinit>" {
+		c.report(path, "reference_kind %d can not refer to %s", mh.RefKind, name)
+	}
+}
+
+func (c *checker) checkClass() {
+	cf := c.classfile
+	if err := cf.AccessFlags.Validate(cf.Major); err != nil {
+		c.addError("access_flags", err)
+	}
+	if cf.ThisClass == nil {
+		c.report("this_class", "this_class is 0")
+		return
+	}
+	if strings.HasPrefix(c.className, "[") {
+		c.report("this_class", "this_class can not be an array type")
+	}
+	if c.isModule() {
+		if c.className != "module-info" {
+			c.report("this_class", "module must be named module-info, got %q", c.className)
+		}
+		if cf.SuperClass != nil || len(cf.Interfaces) > 0 || len(cf.Fields) > 0 || len(cf.Methods) > 0 {
+			c.report("", "module-info must not have super class, interfaces, fields or methods")
+		}
+		return
+	}
+	if cf.SuperClass == nil {
+		if c.className != "java/lang/Object" {
+			c.report("super_class", "super_class is 0")
+		}
+	} else if superName := utf8String(cf.SuperClass.Name); c.isInterface() && superName != "java/lang/Object" {
+		c.report("super_class", "super class of interface must be java/lang/Object, got %q", superName)
+	} else if strings.HasPrefix(superName, "[") {
+		c.report("super_class", "super class can not be an array type")
+	}
+	seen := make(map[string]bool)
+	for i, inter := range cf.Interfaces {
+		path := fmt.Sprintf("interfaces[%d]", i)
+		if inter == nil {
+			c.report(path, "invalid constant pool index 0")
+			continue
+		}
+		name := utf8String(inter.Name)
+		if seen[name] {
+			c.report(path, "duplicate interface %s", name)
+		}
+		seen[name] = true
+	}
+}
+
+// 将 Validate 等返回的格式错误加上路径
+func (c *checker) addError(path string, err error) {
+	cfe, ok := err.(*ClassFormatError)
+	if !ok {
+		cfe = &ClassFormatError{Offset: -1, Err: err}
+	}
+	cfe.ClassName = c.className
+	cfe.Path = path
+	c.errs = append(c.errs, cfe)
+}
+
+// ConstantValue 的常量类型必须和字段类型一致 (JVMS 4.7.2)
+func constantValueTag(t *FieldType) int {
+	switch t.Kind {
+	case TypeLong:
+		return Long
+	case TypeFloat:
+		return Float
+	case TypeDouble:
+		return Double
+	case TypeInt, TypeShort, TypeChar, TypeByte, TypeBoolean:
+		return Integer
+	}
+	if t.Kind == TypeObject && t.ClassName == "java/lang/String" {
+		return String
+	}
+	return 0
+}
+
+func (c *checker) checkFields() {
+	seen := make(map[string]bool)
+	for i, field := range c.classfile.Fields {
+		path := fmt.Sprintf("fields[%d]", i)
+		name := utf8String(field.Name)
+		desc := utf8String(field.Descriptor)
+		c.checkFieldName(path, name)
+		t := c.checkFieldDescriptor(path, desc)
+		if err := field.AccessFlags.Validate(c.isInterface()); err != nil {
+			c.addError(path + ".access_flags", err)
+		}
+		key := name + " " + desc
+		if seen[key] {
+			c.report(path, "duplicate field %s %s", name, desc)
+		}
+		seen[key] = true
+		c.checkAttrs(path, field.Attrs, inField)
+		if t == nil {
+			continue
+		}
+		for j, attr := range field.Attrs {
+			cv, ok := attr.(*AttrConstantValue)
+			if !ok || cv.Val == nil {
+				continue
+			}
+			if tag := constantValueTag(t); tag != cv.Val.Tag() {
+				c.report(fmt.Sprintf("%s.attributes[%d](%s)", path, j, ConstantValue),
+					"%s constant can not initialize field of type %s", ConstantKindName(cv.Val.Tag()), desc)
+			}
+		}
+	}
+}
+
+func (c *checker) checkMethods() {
+	cf := c.classfile
+	seen := make(map[string]bool)
+	for i, method := range cf.Methods {
+		path := fmt.Sprintf("methods[%d]", i)
+		name := utf8String(method.Name)
+		desc := utf8String(method.Descriptor)
+		c.checkMethodName(path, name)
+		m := c.checkMethodDescriptor(path, desc)
+		if err := method.AccessFlags.Validate(name, c.isInterface(), cf.Major); err != nil {
+			c.addError(path + ".access_flags", err)
+		}
+		key := name + desc
+		if seen[key] {
+			c.report(path, "duplicate method %s%s", name, desc)
+		}
+		seen[key] = true
+		switch name {
+		case "<init>":
+			if c.isInterface() {
+				c.report(path, "interface can not declare <init>")
+			}
+			if m != nil && m.Return.Kind != TypeVoid {
+				c.report(path, "<init> must return void")
+			}
+		case "<clinit>":
+			if cf.Major >= 51 && desc != "()V" {
+				c.report(path, "<clinit> must have descriptor ()V, got %s", desc)
+			}
+		}
+		if m != nil {
+			// 参数最多占用255个槽位，实例方法包括 this
+			slots := m.ArgSlots()
+			if method.AccessFlags & MethodAccStatic == 0 {
+				slots++
+			}
+			if slots > 255 {
+				c.report(path, "too many parameters: %d slots", slots)
+			}
+		}
+		c.checkAttrs(path, method.Attrs, inMethod)
+		c.checkCode(path, method)
+	}
+}
+
+// 抽象方法和本地方法不能有 Code 属性，其他方法必须有
+func (c *checker) checkCode(path string, method *Method) {
+	var codes int
+	for j, attr := range method.Attrs {
+		if code, ok := attr.(*AttrCode); ok {
+			codes++
+			c.checkAttrs(fmt.Sprintf("%s.attributes[%d](%s)", path, j, Code), code.Attrs, inCode)
+		}
+	}
+	noCode := method.AccessFlags & (MethodAccAbstract | MethodAccNative) != 0
+	switch {
+	case noCode && codes > 0:
+		c.report(path, "abstract or native method must not have Code attribute")
+	case !noCode && codes == 0 && !c.classfile.options.SkipCode:
+		c.report(path, "missing Code attribute")
+	}
+}
+
+func (c *checker) checkAttrs(path string, attrs []Attr, location int) {
+	seen := make(map[string]bool)
+	hasNestHost, hasNestMembers := false, false
+	for i, attr := range attrs {
+		if attr == nil {
+			continue
+		}
+		name := attr.Name()
+		attrPath := joinPath(path, fmt.Sprintf("attributes[%d](%s)", i, name))
+		// 未知的属性可以出现在任何位置
+		locations, ok := attrLocations[name]
+		if !ok {
+			continue
+		}
+		if locations & location == 0 {
+			c.report(attrPath, "attribute %s is not allowed here", name)
+		}
+		if seen[name] && !repeatableAttrs[name] {
+			c.report(attrPath, "duplicate attribute %s", name)
+		}
+		seen[name] = true
+		switch a := attr.(type) {
+		case *AttrNestHost:
+			hasNestHost = true
+		case *AttrNestMembers:
+			hasNestMembers = true
+		case *AttrRecord:
+			for j, rc := range a.Components {
+				rcPath := fmt.Sprintf("%s.components[%d]", attrPath, j)
+				c.checkFieldName(rcPath, utf8String(rc.Name))
+				c.checkFieldDescriptor(rcPath, utf8String(rc.Descriptor))
+				c.checkAttrs(rcPath, rc.Attrs, inRecordComponent)
+			}
+		}
+	}
+	if hasNestHost && hasNestMembers {
+		c.report(path, "NestHost and NestMembers can not both be present")
+	}
+}
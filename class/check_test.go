@@ -0,0 +1,194 @@
+package class
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckArrayList(t *testing.T) {
+	cf, err := NewClassFile(bytes.NewReader(testByteCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(cf); err != nil {
+		t.Error(err)
+	}
+	cf, err = NewClassFileWithOptions(bytes.NewReader(testByteCode), ParseOptions{SkipCode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(cf); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckReportsAllViolations(t *testing.T) {
+	pool := NewEmptyConstantPool()
+	utf8 := func(s string) *ConstUTF8 {
+		i, err := pool.AddUTF8(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := pool.GetUTF8String(i)
+		return u
+	}
+	add := func(i uint16, err error) uint16 {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	class := func(name string) *ConstClass {
+		c, _ := pool.GetClass(add(pool.AddClass(name)))
+		return c
+	}
+	cf := &ClassFile{
+		Magic: ClassFileMagic,
+		Major: 52,
+		ConstantPool: pool,
+		AccessFlags: ACCSUPER,
+		ThisClass: class("Bad"),
+		SuperClass: class("java/lang/Object"),
+	}
+	initRef := add(pool.AddMethodRef("Bad", "<init>", "()I"))
+	fieldRef := add(pool.AddFieldRef("Bad", "a.b", "I"))
+	handle := add(pool.AddMethodHandle(RefInvokeVirtual, fieldRef))
+	methodType := add(pool.AddMethodType("(V)V"))
+	indy := add(pool.AddInvokeDynamic(0, "run", "()V"))
+	pkg := add(pool.AddPackage("p"))
+	str, _ := pool.Get(add(pool.AddString("s")))
+	code := &AttrCode{MaxStack: 1, MaxLocals: 1, Code: []byte{0xb1}}
+	cf.Fields = []*Field{
+		{FieldAccStatic | FieldAccFinal, utf8("f"), utf8("I"), []Attr{&AttrConstantValue{str}}},
+		{FieldAccFinal | FieldAccVolatile, utf8("f"), utf8("I"), nil},
+		{0, utf8("g"), utf8("Q"), nil},
+	}
+	cf.Methods = []*Method{
+		{MethodAccPublic | MethodAccAbstract, utf8("m"), utf8("()V"), []Attr{code}},
+		{MethodAccPublic, utf8("m"), utf8("()V"), []Attr{code}},
+		{MethodAccPublic, utf8("n"), utf8("()V"), nil},
+		{MethodAccPublic, utf8("<init>"), utf8("()I"), []Attr{code}},
+		{MethodAccPublic, utf8("<x>"), utf8("()V"), []Attr{code}},
+	}
+	cf.Attrs = []Attr{
+		&AttrSourceFile{utf8("Bad.java")},
+		&AttrSourceFile{utf8("Bad.java")},
+		code,
+	}
+
+	err := Check(cf)
+	errs, ok := err.(CheckErrors)
+	if !ok {
+		t.Fatalf("expected CheckErrors, got %v", err)
+	}
+	if !errors.Is(err, ClassFileFormatError) {
+		t.Error("expected errors.Is(err, ClassFileFormatError)")
+	}
+	expected := []struct {
+		path, msg string
+	}{
+		{fmt.Sprintf("constant_pool[%d](Methodref)", initRef), "<init> must return void"},
+		{fmt.Sprintf("constant_pool[%d](Fieldref)", fieldRef), "illegal character"},
+		{fmt.Sprintf("constant_pool[%d](MethodHandle)", handle), "expected Methodref constant, got Fieldref"},
+		{fmt.Sprintf("constant_pool[%d](MethodType)", methodType), "void"},
+		{fmt.Sprintf("constant_pool[%d](InvokeDynamic)", indy), "bootstrap_method_attr_index 0 out of range"},
+		{fmt.Sprintf("constant_pool[%d](Package)", pkg), "module-info"},
+		{fmt.Sprintf("constant_pool[%d](Package)", pkg), "requires class file version 53"},
+		{"fields[0].attributes[0](ConstantValue)", "String constant can not initialize field of type I"},
+		{"fields[1].access_flags", "both final and volatile"},
+		{"fields[1]", "duplicate field f I"},
+		{"fields[2]", "unknow type"},
+		{"methods[0]", "abstract or native method must not have Code"},
+		{"methods[1]", "duplicate method m()V"},
+		{"methods[2]", "missing Code attribute"},
+		{"methods[3]", "<init> must return void"},
+		{"methods[4]", "illegal character in name \"<x>\""},
+		{"attributes[1](SourceFile)", "duplicate attribute SourceFile"},
+		{"attributes[2](Code)", "attribute Code is not allowed here"},
+	}
+	for _, e := range expected {
+		found := false
+		for _, cfe := range errs {
+			if cfe.Path == e.path && strings.Contains(cfe.Error(), e.msg) && cfe.ClassName == "Bad" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("missing %s: %s", e.path, e.msg)
+		}
+	}
+	if len(errs) != len(expected) {
+		t.Errorf("got %d errors, expected %d:\n%v", len(errs), len(expected), err)
+	}
+}
+
+func TestCheckClassStructure(t *testing.T) {
+	pool := NewEmptyConstantPool()
+	class := func(name string) *ConstClass {
+		i, err := pool.AddClass(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, _ := pool.GetClass(i)
+		return c
+	}
+	for _, test := range []struct {
+		cf *ClassFile
+		path, msg string
+	}{
+		{&ClassFile{Major: 52, AccessFlags: ACCSUPER, ThisClass: class("A")}, "super_class", "super_class is 0"},
+		{&ClassFile{Major: 52, AccessFlags: ACCINTERFACE | ACCABSTRACT, ThisClass: class("I"), SuperClass: class("A")}, "super_class", "must be java/lang/Object"},
+		{&ClassFile{Major: 52, AccessFlags: ACCSUPER, ThisClass: class("[I"), SuperClass: class("java/lang/Object")}, "this_class", "array"},
+		{&ClassFile{Major: 52, AccessFlags: ACCSUPER, ThisClass: class("A"), SuperClass: class("java/lang/Object"),
+			Interfaces: []*ConstClass{class("I"), class("I")}}, "interfaces[1]", "duplicate interface I"},
+		{&ClassFile{Major: 53, AccessFlags: ACCMODULE, ThisClass: class("A")}, "this_class", "module-info"},
+	} {
+		test.cf.ConstantPool = pool
+		err := Check(test.cf)
+		errs, ok := err.(CheckErrors)
+		if !ok || len(errs) != 1 || errs[0].Path != test.path || !strings.Contains(errs[0].Error(), test.msg) {
+			t.Errorf("%s: unexpected error %v", test.path, err)
+		}
+	}
+}
+
+// 索引为0的常量在 ClassFile 中为nil，Check 应报告错误而不是空指针
+func TestCheckZeroIndexes(t *testing.T) {
+	pool := NewEmptyConstantPool()
+	i, _ := pool.AddClass("A")
+	this, _ := pool.GetClass(i)
+	i, _ = pool.AddClass("java/lang/Object")
+	object, _ := pool.GetClass(i)
+	cf := &ClassFile{
+		Major: 52,
+		ConstantPool: pool,
+		AccessFlags: ACCSUPER,
+		ThisClass: this,
+		SuperClass: object,
+		Interfaces: []*ConstClass{nil},
+		Fields: []*Field{{}},
+		Methods: []*Method{{AccessFlags: MethodAccAbstract}},
+	}
+	err := Check(cf)
+	errs, ok := err.(CheckErrors)
+	if !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+	paths := map[string]bool{}
+	for _, e := range errs {
+		paths[e.Path] = true
+	}
+	for _, path := range []string{"interfaces[0]", "fields[0]", "methods[0]"} {
+		if !paths[path] {
+			t.Errorf("%s not reported:\n%v", path, err)
+		}
+	}
+	cf.ThisClass, cf.SuperClass = nil, nil
+	if err := Check(cf); err == nil {
+		t.Error("expected errors for this_class 0")
+	}
+}
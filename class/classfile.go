@@ -3,6 +3,7 @@ package class
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -16,7 +17,7 @@ type ClassFile struct {
 	// 常量池
 	ConstantPool *ConstantPool
 	// 类访问标志
-	AccessFlags uint16
+	AccessFlags ClassAccessFlags
 	// 当前类索引
 	ThisClass *ConstClass
 	// 超类索引
@@ -30,12 +31,39 @@ type ClassFile struct {
 	// 属性表
 	Attrs []Attr
 	reader io.Reader
+	options ParseOptions
+	// 按名称和描述符索引的字段和方法，解析成员引用时使用
+	fieldIndex map[memberKey]*Field
+	methodIndex map[memberKey]*Method
+}
+
+type memberKey struct {
+	name, descriptor string
+}
+
+// 解析选项，用于只需要部分信息的场景，例如扫描classpath
+type ParseOptions struct {
+	// 读取接口表后停止，不解析字段表、方法表和属性表
+	HeaderOnly bool
+	// 按 attribute_length 跳过方法的 Code 属性，不解析字节码
+	SkipCode bool
 }
 
 const (
 	ClassFileMagic = 0xcafebabe
 )
 
+const (
+	// JDK 1.1
+	MinMajorVersion = 45
+	// JDK 25
+	MaxMajorVersion = 69
+	// JDK 12 开始支持预览特性
+	MinPreviewMajorVersion = 56
+	// 预览特性的次版本号
+	PreviewMinorVersion = 0xffff
+)
+
 const (
 	ACCPUBLIC = 0x0001
 	ACCFINAL  = 0x0010
@@ -45,79 +73,115 @@ const (
 	ACCSYNTHETIC = 0x1000
 	ACCANNOTATION = 0x2000
 	ACCENUM = 0x4000
+	ACCMODULE = 0x8000
 )
 
 var (
 	ClassFileFormatError = errors.New("class file format invalid")
+	PartialClassFileError = errors.New("class file is parsed partially")
 )
 
 func NewClassFile(reader io.Reader) (*ClassFile, error) {
+	return NewClassFileWithOptions(reader, ParseOptions{})
+}
+
+// 只解析到接口表，返回的 ClassFile 中 Fields、Methods 和 Attrs 为空
+func ScanClassHeader(reader io.Reader) (*ClassFile, error) {
+	return NewClassFileWithOptions(reader, ParseOptions{HeaderOnly: true})
+}
+
+func NewClassFileWithOptions(reader io.Reader, options ParseOptions) (*ClassFile, error) {
 	var err error
 	classFile := &ClassFile{
-		reader: reader,
+		reader: &offsetReader{reader: reader},
+		options: options,
 	}
 	if err = classFile.readAndCheckMagic(); err != nil {
-		return nil, err
+		return nil, classFile.formatError(err)
 	}
 	if err = classFile.readAndCheckClassFileVersion(); err != nil {
-		return nil, err
+		return nil, classFile.formatError(err)
 	}
-	if classFile.ConstantPool, err = NewConstantPool(reader); err != nil {
-		return nil, err
+	if classFile.ConstantPool, err = NewConstantPool(classFile.reader); err != nil {
+		return nil, classFile.formatError(err)
 	}
 	if err = classFile.readAccessFlags(); err != nil {
-		return nil, err
+		return nil, classFile.formatError(err)
 	}
 	if err = classFile.readThisClass(); err != nil {
-		return nil, err
+		return nil, classFile.formatError(err)
 	}
 	if err = classFile.readSuperClass(); err != nil {
-		return nil, err
+		return nil, classFile.formatError(err)
 	}
 	if err = classFile.readInterfaces(); err != nil {
-		return nil, err
+		return nil, classFile.formatError(err)
+	}
+	if options.HeaderOnly {
+		return classFile, nil
 	}
 	if err = classFile.readFields(); err != nil {
-		return nil, err
+		return nil, classFile.formatError(err)
 	}
 	if err = classFile.readMethods(); err != nil {
-		return nil, err
+		return nil, classFile.formatError(err)
 	}
 	if err = classFile.readAttrs(); err != nil {
-		return nil, err
+		return nil, classFile.formatError(err)
 	}
+	classFile.buildMemberIndex()
 	return classFile, nil
 }
 
+// 所有解析错误都以 *ClassFormatError 返回，已知类名时一并记录
+func (classfile *ClassFile) formatError(err error) error {
+	cfe := formatError(classfile.reader, err, "").(*ClassFormatError)
+	if classfile.ThisClass != nil && classfile.ThisClass.Name != nil {
+		cfe.ClassName = classfile.ThisClass.Name.String()
+	}
+	return cfe
+}
+
 func (classfile *ClassFile) readAndCheckMagic() error {
 	if err := binary.Read(classfile.reader, binary.BigEndian, &classfile.Magic); err != nil {
-		return err
+		return formatError(classfile.reader, err, "magic")
 	}
 	if classfile.Magic != ClassFileMagic {
-		return errors.New("class file 'magic' invalid")
+		return formatError(classfile.reader, errors.New("class file 'magic' invalid"), "magic")
 	}
 	return nil
 }
 
 func (classfile *ClassFile) readAndCheckClassFileVersion() error {
 	if err := binary.Read(classfile.reader, binary.BigEndian, &classfile.Minor); err != nil {
-		return err
+		return formatError(classfile.reader, err, "minor_version")
 	}
 	if err := binary.Read(classfile.reader, binary.BigEndian, &classfile.Major); err != nil {
-		return err
+		return formatError(classfile.reader, err, "major_version")
+	}
+	if classfile.Major < MinMajorVersion || classfile.Major > MaxMajorVersion {
+		return formatError(classfile.reader, fmt.Errorf("unknow class version %d.%d", classfile.Major, classfile.Minor), "major_version")
 	}
-	switch classfile.Major {
-	case 45:
-	case 46, 47, 48, 49, 50, 51, 52:
-	default:
-		return errors.New("unknow class version")
+	if classfile.Minor == PreviewMinorVersion && classfile.Major < MinPreviewMajorVersion {
+		return formatError(classfile.reader, errors.New("preview class version requires major version 56 or above"), "minor_version")
+	}
+	if classfile.Major >= MinPreviewMajorVersion && classfile.Minor != 0 && classfile.Minor != PreviewMinorVersion {
+		return formatError(classfile.reader, fmt.Errorf("unknow class minor version %d", classfile.Minor), "minor_version")
 	}
 	return nil
 }
 
+// 是否启用了预览特性
+func (classfile *ClassFile) IsPreview() bool {
+	return classfile.Minor == PreviewMinorVersion
+}
+
 func (classfile *ClassFile) readAccessFlags() error {
 	if err := binary.Read(classfile.reader, binary.BigEndian, &classfile.AccessFlags); err != nil {
-		return err
+		return formatError(classfile.reader, err, "access_flags")
+	}
+	if err := classfile.AccessFlags.Validate(classfile.Major); err != nil {
+		return formatError(classfile.reader, err, "access_flags")
 	}
 	return nil
 }
@@ -125,11 +189,11 @@ func (classfile *ClassFile) readAccessFlags() error {
 func (classfile *ClassFile) readThisClass() error {
 	var thisClassIndex uint16
 	if err := binary.Read(classfile.reader, binary.BigEndian, &thisClassIndex); err != nil {
-		return err
+		return formatError(classfile.reader, err, "this_class")
 	}
 	var err error
-	if classfile.ThisClass, err = classfile.ConstantPool.GetClass(thisClassIndex); err != nil {
-		return err
+	if classfile.ThisClass, err = classfile.ConstantPool.mustGetClass(thisClassIndex); err != nil {
+		return formatError(classfile.reader, err, "this_class")
 	}
 	return nil
 }
@@ -138,10 +202,10 @@ func (classfile *ClassFile) readSuperClass() error {
 	var superClassIndex uint16
 	var err error
 	if err := binary.Read(classfile.reader, binary.BigEndian, &superClassIndex); err != nil {
-		return err
+		return formatError(classfile.reader, err, "super_class")
 	}
 	if classfile.SuperClass, err = classfile.ConstantPool.GetClass(superClassIndex); err != nil {
-		return err
+		return formatError(classfile.reader, err, "super_class")
 	}
 	return nil
 }
@@ -149,16 +213,16 @@ func (classfile *ClassFile) readSuperClass() error {
 func (classfile *ClassFile) readInterfaces() error {
 	var interfaceCount uint16
 	if err := binary.Read(classfile.reader, binary.BigEndian, &interfaceCount); err != nil {
-		return err
+		return formatError(classfile.reader, err, "interfaces_count")
 	}
 	var index uint16
 	for i := 0; i < int(interfaceCount); i++ {
 		if err := binary.Read(classfile.reader, binary.BigEndian, &index); err != nil {
-			return err
+			return formatError(classfile.reader, err, "interfaces[%d]", i)
 		}
-		class, err := classfile.ConstantPool.GetClass(index)
+		class, err := classfile.ConstantPool.mustGetClass(index)
 		if err != nil {
-			return err
+			return formatError(classfile.reader, err, "interfaces[%d]", i)
 		}
 		classfile.Interfaces = append(classfile.Interfaces, class)
 	}
@@ -168,12 +232,12 @@ func (classfile *ClassFile) readInterfaces() error {
 func (classfile *ClassFile) readFields() error {
 	var fieldsCount uint16
 	if err := binary.Read(classfile.reader, binary.BigEndian, &fieldsCount); err != nil {
-		return err
+		return formatError(classfile.reader, err, "fields_count")
 	}
 	for i := 0; i < int(fieldsCount); i++ {
 		field, err := classfile.readField()
 		if err != nil {
-			return err
+			return formatError(classfile.reader, err, "fields[%d]", i)
 		}
 		classfile.Fields = append(classfile.Fields, field)
 	}
@@ -183,31 +247,34 @@ func (classfile *ClassFile) readFields() error {
 func (classfile *ClassFile) readField() (*Field, error) {
 	field := &Field{}
 	if err := binary.Read(classfile.reader, binary.BigEndian, &field.AccessFlags); err != nil {
-		return nil, err
+		return nil, formatError(classfile.reader, err, "access_flags")
+	}
+	if err := field.AccessFlags.Validate(classfile.AccessFlags & ACCINTERFACE != 0); err != nil {
+		return nil, formatError(classfile.reader, err, "access_flags")
 	}
 	var nameIndex, descriptorIndex uint16
 	if err := binary.Read(classfile.reader, binary.BigEndian, &nameIndex); err != nil {
-		return nil, err
+		return nil, formatError(classfile.reader, err, "name_index")
 	}
 	if err := binary.Read(classfile.reader, binary.BigEndian, &descriptorIndex); err != nil {
-		return nil, err
+		return nil, formatError(classfile.reader, err, "descriptor_index")
 	}
 	var err error
-	if field.Name, err = classfile.ConstantPool.GetUTF8String(nameIndex); err != nil {
-		return nil, err
+	if field.Name, err = classfile.ConstantPool.mustGetUTF8String(nameIndex); err != nil {
+		return nil, formatError(classfile.reader, err, "name_index")
 	}
-	if field.Descriptor, err = classfile.ConstantPool.GetUTF8String(descriptorIndex); err != nil {
-		return nil, err
+	if field.Descriptor, err = classfile.ConstantPool.mustGetUTF8String(descriptorIndex); err != nil {
+		return nil, formatError(classfile.reader, err, "descriptor_index")
 	}
 	var attrCount uint16
 	if err := binary.Read(classfile.reader, binary.BigEndian, &attrCount); err != nil {
-		return nil, err
+		return nil, formatError(classfile.reader, err, "attributes_count")
 	}
 	field.Attrs = make([]Attr, attrCount)
 	for i := 0; i < int(attrCount); i++ {
 		attr, err := ReadAttr(classfile.reader, classfile.ConstantPool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(classfile.reader, err, "attributes[%d]", i)
 		}
 		field.Attrs[i] = attr
 	}
@@ -217,29 +284,182 @@ func (classfile *ClassFile) readField() (*Field, error) {
 func (classfile *ClassFile) readMethods() error {
 	var methodCount uint16
 	if err := binary.Read(classfile.reader, binary.BigEndian, &methodCount); err != nil {
-		return err
+		return formatError(classfile.reader, err, "methods_count")
 	}
 	for i := 0; i < int(methodCount); i++ {
-		method, err := NewMethod(classfile.reader, classfile.ConstantPool)
+		start := readerOffset(classfile.reader)
+		method, err := newMethod(classfile.reader, classfile.ConstantPool, classfile.skipMethodAttr)
 		if err != nil {
-			return err
+			return formatError(classfile.reader, err, "methods[%d]", i)
+		}
+		// 方法名在访问标志之后才读到，所以读完整个方法再检查，偏移指回方法开头
+		if err := method.AccessFlags.Validate(method.Name.String(), classfile.AccessFlags & ACCINTERFACE != 0, classfile.Major); err != nil {
+			if e, ok := err.(*ClassFormatError); ok {
+				e.Offset = start
+			}
+			return formatError(classfile.reader, err, "methods[%d].access_flags", i)
 		}
 		classfile.Methods = append(classfile.Methods, method)
 	}
 	return nil
 }
 
+func (classfile *ClassFile) skipMethodAttr(name string) bool {
+	return classfile.options.SkipCode && name == Code
+}
+
+// 是否按 ParseOptions 只解析了部分内容
+func (classfile *ClassFile) IsPartial() bool {
+	return classfile.options.HeaderOnly || classfile.options.SkipCode
+}
+
 func (classfile *ClassFile) readAttrs() error {
 	var attrCount uint16
 	if err := binary.Read(classfile.reader, binary.BigEndian, &attrCount); err != nil {
-		return err
+		return formatError(classfile.reader, err, "attributes_count")
 	}
 	for i := 0; i < int(attrCount); i++ {
 		attr, err := ReadAttr(classfile.reader, classfile.ConstantPool)
 		if err != nil {
-			return err
+			return formatError(classfile.reader, err, "attributes[%d]", i)
 		}
 		classfile.Attrs = append(classfile.Attrs, attr)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// 是否是 record 类 (Java 16+)
+func (classfile *ClassFile) IsRecord() bool {
+	for _, attr := range classfile.Attrs {
+		if _, ok := attr.(*AttrRecord); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// record 类的组件列表，非 record 类返回nil
+func (classfile *ClassFile) RecordComponents() []*RecordComponent {
+	for _, attr := range classfile.Attrs {
+		if record, ok := attr.(*AttrRecord); ok {
+			return record.Components
+		}
+	}
+	return nil
+}
+
+// 是否是 sealed 类或接口 (Java 17+)
+func (classfile *ClassFile) IsSealed() bool {
+	for _, attr := range classfile.Attrs {
+		if _, ok := attr.(*AttrPermittedSubclasses); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sealed 类允许的直接子类，非 sealed 类返回nil
+func (classfile *ClassFile) PermittedSubclasses() []*ConstClass {
+	for _, attr := range classfile.Attrs {
+		if ps, ok := attr.(*AttrPermittedSubclasses); ok {
+			return ps.Classes
+		}
+	}
+	return nil
+}
+
+// 嵌套宿主类，没有 NestHost 属性时返回nil
+func (classfile *ClassFile) NestHost() *ConstClass {
+	for _, attr := range classfile.Attrs {
+		if nh, ok := attr.(*AttrNestHost); ok {
+			return nh.HostClass
+		}
+	}
+	return nil
+}
+
+// 嵌套成员类列表
+func (classfile *ClassFile) NestMembers() []*ConstClass {
+	for _, attr := range classfile.Attrs {
+		if nm, ok := attr.(*AttrNestMembers); ok {
+			return nm.Classes
+		}
+	}
+	return nil
+}
+
+// 当前类的内部形式类名，例如 java/util/ArrayList
+func (classfile *ClassFile) Name() string {
+	if classfile.ThisClass == nil || classfile.ThisClass.Name == nil {
+		return ""
+	}
+	return classfile.ThisClass.Name.String()
+}
+
+// 超类的内部形式类名，java/lang/Object 和 module-info 返回空字符串
+func (classfile *ClassFile) SuperName() string {
+	if classfile.SuperClass == nil || classfile.SuperClass.Name == nil {
+		return ""
+	}
+	return classfile.SuperClass.Name.String()
+}
+
+// 源文件名，没有 SourceFile 属性时返回空字符串
+func (classfile *ClassFile) SourceFile() string {
+	for _, attr := range classfile.Attrs {
+		if sf, ok := attr.(*AttrSourceFile); ok && sf.SourceFile != nil {
+			return sf.SourceFile.String()
+		}
+	}
+	return ""
+}
+
+// BootstrapMethods 属性中的引导方法，下标即 bootstrap_method_attr_index
+func (classfile *ClassFile) BootstrapMethods() []*BootstrapMethod {
+	for _, attr := range classfile.Attrs {
+		if bm, ok := attr.(*AttrBootstrapMethods); ok {
+			return bm.BootstrapMethods
+		}
+	}
+	return nil
+}
+
+func (classfile *ClassFile) buildMemberIndex() {
+	classfile.fieldIndex = make(map[memberKey]*Field, len(classfile.Fields))
+	for _, field := range classfile.Fields {
+		key := memberKey{utf8String(field.Name), utf8String(field.Descriptor)}
+		if _, ok := classfile.fieldIndex[key]; !ok {
+			classfile.fieldIndex[key] = field
+		}
+	}
+	classfile.methodIndex = make(map[memberKey]*Method, len(classfile.Methods))
+	for _, method := range classfile.Methods {
+		key := memberKey{utf8String(method.Name), utf8String(method.Descriptor)}
+		if _, ok := classfile.methodIndex[key]; !ok {
+			classfile.methodIndex[key] = method
+		}
+	}
+}
+
+// 按名称和描述符查找字段，不存在时返回nil
+// 解析得到的 ClassFile 已建立索引，手工构造的在第一次查找时建立，
+// 之后再修改 Fields 或 Methods 需要调用 ReindexMembers
+func (classfile *ClassFile) Field(name, descriptor string) *Field {
+	if classfile.fieldIndex == nil {
+		classfile.buildMemberIndex()
+	}
+	return classfile.fieldIndex[memberKey{name, descriptor}]
+}
+
+// 按名称和描述符查找方法，不存在时返回nil
+func (classfile *ClassFile) Method(name, descriptor string) *Method {
+	if classfile.methodIndex == nil {
+		classfile.buildMemberIndex()
+	}
+	return classfile.methodIndex[memberKey{name, descriptor}]
+}
+
+// 修改 Fields 或 Methods 后重建查找索引
+func (classfile *ClassFile) ReindexMembers() {
+	classfile.buildMemberIndex()
+}
@@ -8,6 +8,8 @@ import (
 	"path"
 	"bytes"
 	"fmt"
+	"errors"
+	"runtime"
 )
 
 var (
@@ -58,7 +60,7 @@ func showClassFile(cf *ClassFile) {
 			fmt.Printf("ConstantPool[%d] %s\n", i, constant)
 		}
 	}
-	fmt.Printf("AccessFlags:%s\n", showAccessFlags(cf.AccessFlags))
+	fmt.Printf("AccessFlags:%v\n", cf.AccessFlags.Flags())
 	fmt.Printf("ThisClass:%s\n", cf.ThisClass)
 	fmt.Printf("SuperClass:%s\n", cf.SuperClass)
 
@@ -67,7 +69,7 @@ func showClassFile(cf *ClassFile) {
 	}
 	for i, field := range cf.Fields {
 		fmt.Printf(`Fields[%d]:{
-   AccessFlags: %s
+   AccessFlags: %v
    Name: %s
    Descriptor: %s
    Attrs: %v
@@ -75,7 +77,7 @@ func showClassFile(cf *ClassFile) {
 
 
 
-`, i, showFieldAccessFlags(field.AccessFlags), field.Name, field.Descriptor, field.Attrs)
+`, i, field.AccessFlags.Flags(), field.Name, field.Descriptor, field.Attrs)
 	}
 	for i, m := range cf.Methods {
 		fmt.Printf("method[%d] %s\n\n\n\n", i, m)
@@ -85,63 +87,258 @@ func showClassFile(cf *ClassFile) {
 	}
 }
 
-func showAccessFlags(accessFlags uint16) string {
-	var s string
-	if accessFlags & ACCPUBLIC > 0 {
-		s += " ACC_PUBLIC "
+func TestNewClassFileRecordAndSealed(t *testing.T) {
+	buf := &testWriter{}
+	buf.write(uint32(ClassFileMagic))
+	buf.write(uint16(0))
+	buf.write(uint16(61))
+	buf.write(uint16(13))
+	buf.writeUTF8("Point")                // #1
+	buf.write(uint8(Class))               // #2
+	buf.write(uint16(1))
+	buf.writeUTF8("java/lang/Record")     // #3
+	buf.write(uint8(Class))               // #4
+	buf.write(uint16(3))
+	buf.writeUTF8("Point$Inner")          // #5
+	buf.write(uint8(Class))               // #6
+	buf.write(uint16(5))
+	buf.writeUTF8(Record)                 // #7
+	buf.writeUTF8(PermittedSubclasses)    // #8
+	buf.writeUTF8(NestMembers)            // #9
+	buf.writeUTF8("x")                    // #10
+	buf.writeUTF8("I")                    // #11
+	buf.writeUTF8(Signature)              // #12
+	buf.write([]uint16{ACCFINAL | ACCSUPER, 2, 4, 0, 0, 0})
+	buf.write(uint16(3))
+	buf.write(uint16(7))
+	buf.write(uint32(16))
+	buf.write([]uint16{1, 10, 11, 1, 12})
+	buf.write(uint32(2))
+	buf.write(uint16(11))
+	buf.write(uint16(8))
+	buf.write(uint32(4))
+	buf.write([]uint16{1, 6})
+	buf.write(uint16(9))
+	buf.write(uint32(4))
+	buf.write([]uint16{1, 6})
+
+	classFile, err := NewClassFile(buf)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if accessFlags & ACCFINAL > 0 {
-		s += " ACC_FINAL "
+	if !classFile.IsRecord() || !classFile.IsSealed() {
+		t.Error("expected record and sealed class")
 	}
-	if accessFlags & ACCSUPER > 0 {
-		s += " ACC_SUPER "
+	components := classFile.RecordComponents()
+	if len(components) != 1 || components[0].Name.String() != "x" || components[0].Descriptor.String() != "I" ||
+		len(components[0].Attrs) != 1 {
+		t.Errorf("record components %v", components)
 	}
-	if accessFlags & ACCINTERFACE > 0 {
-		s += " ACC_INTERFACE "
+	if subclasses := classFile.PermittedSubclasses(); len(subclasses) != 1 || subclasses[0].Name.String() != "Point$Inner" {
+		t.Errorf("permitted subclasses %v", subclasses)
 	}
-	if accessFlags & ACCABSTRACT > 0 {
-		s += " ACC_ABSTRACT "
+	if members := classFile.NestMembers(); len(members) != 1 {
+		t.Errorf("nest members %v", members)
 	}
-	if accessFlags & ACCSYNTHETIC > 0 {
-		s += " ACC_SYNTHETIC "
+	if classFile.NestHost() != nil {
+		t.Error("unexpected nest host")
 	}
-	if accessFlags & ACCANNOTATION > 0 {
-		s += " ACC_ANNOTATION "
+}
+
+// 常量池有 #1 Utf8 Foo、#2 Class Foo、#3 Utf8 m、#4 Utf8 ()V、#5 Utf8 I，
+// 各个索引可以设置为0或越界的值
+func indexTestClass(thisClass, inter, fieldName, fieldDescriptor, methodName, methodDescriptor uint16) []byte {
+	buf := &testWriter{}
+	buf.write(uint32(ClassFileMagic))
+	buf.write([]uint16{0, 52, 6})
+	buf.writeUTF8("Foo")                  // #1
+	buf.write(uint8(Class))               // #2
+	buf.write(uint16(1))
+	buf.writeUTF8("m")                    // #3
+	buf.writeUTF8("()V")                  // #4
+	buf.writeUTF8("I")                    // #5
+	buf.write([]uint16{ACCPUBLIC | ACCSUPER, thisClass, 0, 1, inter})
+	buf.write([]uint16{1, FieldAccPrivate, fieldName, fieldDescriptor, 0})
+	buf.write([]uint16{1, MethodAccPublic | MethodAccNative, methodName, methodDescriptor, 0})
+	buf.write(uint16(0))
+	return buf.Bytes()
+}
+
+// 每一项依次替换 indexTestValid 中的一个索引为0、越界或类型错误的值
+var indexTestValid = [6]uint16{2, 2, 3, 5, 3, 4}
+
+var indexTestSeeds = [][6]uint16{
+	{0, 2, 3, 5, 3, 4}, {99, 2, 3, 5, 3, 4}, {1, 2, 3, 5, 3, 4},
+	{2, 0, 3, 5, 3, 4}, {2, 99, 3, 5, 3, 4}, {2, 1, 3, 5, 3, 4},
+	{2, 2, 0, 5, 3, 4}, {2, 2, 99, 5, 3, 4}, {2, 2, 2, 5, 3, 4},
+	{2, 2, 3, 0, 3, 4}, {2, 2, 3, 99, 3, 4}, {2, 2, 3, 2, 3, 4},
+	{2, 2, 3, 5, 0, 4}, {2, 2, 3, 5, 99, 4}, {2, 2, 3, 5, 2, 4},
+	{2, 2, 3, 5, 3, 0}, {2, 2, 3, 5, 3, 99}, {2, 2, 3, 5, 3, 2},
+}
+
+func indexTestBytes(seed [6]uint16) []byte {
+	return indexTestClass(seed[0], seed[1], seed[2], seed[3], seed[4], seed[5])
+}
+
+// go test -fuzz=FuzzNewClassFile ./class
+func FuzzNewClassFile(f *testing.F) {
+	f.Add(testByteCode)
+	f.Add(testByteCode[:len(testByteCode) / 2])
+	f.Add([]byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 52, 0, 1})
+	f.Add(indexTestBytes(indexTestValid))
+	for _, seed := range indexTestSeeds {
+		f.Add(indexTestBytes(seed))
 	}
-	if accessFlags & ACCENUM > 0 {
-		s += " ACC_ENUM "
+	f.Fuzz(func(t *testing.T, data []byte) {
+		classFile, err := NewClassFile(bytes.NewReader(data))
+		if err != nil {
+			if !errors.Is(err, ClassFileFormatError) {
+				t.Errorf("unexpected error type %T: %v", err, err)
+			}
+			return
+		}
+		if _, err := classFile.WriteTo(ioutil.Discard); err != nil {
+			t.Errorf("write parsed class file: %v", err)
+		}
+	})
+}
+
+func TestNewClassFileInvalidIndexes(t *testing.T) {
+	if _, err := NewClassFile(bytes.NewReader(indexTestBytes(indexTestValid))); err != nil {
+		t.Fatal(err)
+	}
+	for _, seed := range indexTestSeeds {
+		_, err := NewClassFile(bytes.NewReader(indexTestBytes(seed)))
+		if !errors.Is(err, ClassFileFormatError) {
+			t.Errorf("%v: expected format error, got %v", seed, err)
+		}
+	}
+	// 索引为0时在对应的位置报错，不留下nil常量
+	paths := []string{"this_class", "interfaces[0]", "fields[0].name_index", "fields[0].descriptor_index", "methods[0].name_index", "methods[0].descriptor_index"}
+	for i, path := range paths {
+		seed := indexTestValid
+		seed[i] = 0
+		var cfe *ClassFormatError
+		if _, err := NewClassFile(bytes.NewReader(indexTestBytes(seed))); !errors.As(err, &cfe) || cfe.Path != path {
+			t.Errorf("%v: expected error at %s, got %v", seed, path, err)
+		}
 	}
-	return s
 }
 
-func showFieldAccessFlags(accessFlags uint16) string {
-	var s string
-	if accessFlags & FieldAccPublic > 0 {
-		s += " FieldAccPublic "
+// 计数字段声明了大量的项但输入很短时，分配的内存不应随计数增长
+func TestNewClassFileHugeCounts(t *testing.T) {
+	valid := indexTestBytes(indexTestValid)
+	// constant_pool_count、interfaces_count、fields_count、methods_count 的偏移
+	for _, offset := range []int{8, 39, 43, 53} {
+		data := append([]byte(nil), valid[:offset + 2]...)
+		data[offset], data[offset + 1] = 0xff, 0xff
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := NewClassFile(bytes.NewReader(data))
+		runtime.ReadMemStats(&after)
+		if !errors.Is(err, ClassFileFormatError) {
+			t.Errorf("offset %d: expected format error, got %v", offset, err)
+		}
+		// 常量池最多 65535 项
+		if n := after.TotalAlloc - before.TotalAlloc; n > 4 << 20 {
+			t.Errorf("offset %d: allocated %d bytes for %d bytes of input", offset, n, len(data))
+		}
 	}
-	if accessFlags & FieldAccPrivate > 0 {
-		s += " FieldAccPrivate "
+}
+
+func TestScanClassHeader(t *testing.T) {
+	full, err := NewClassFile(bytes.NewReader(testByteCode))
+	if err != nil {
+		t.Fatal(err)
 	}
-	if accessFlags & FieldAccProtected > 0 {
-		s += " FieldAccProtected "
+	header, err := ScanClassHeader(bytes.NewReader(testByteCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.ThisClass.Name.String() != "java/util/ArrayList" || header.SuperClass.Name.String() != "java/util/AbstractList" ||
+		header.AccessFlags != full.AccessFlags || len(header.Interfaces) != len(full.Interfaces) {
+		t.Errorf("unexpected header %s extends %s", header.ThisClass.Name, header.SuperClass.Name)
+	}
+	if header.Fields != nil || header.Methods != nil || header.Attrs != nil {
+		t.Error("header scan should not parse fields, methods or attributes")
+	}
+	if _, err := header.WriteTo(ioutil.Discard); err != PartialClassFileError {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	classFile, err := NewClassFileWithOptions(bytes.NewReader(testByteCode), ParseOptions{SkipCode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(classFile.Methods) != len(full.Methods) || len(classFile.Attrs) != len(full.Attrs) {
+		t.Errorf("methods %d, attrs %d", len(classFile.Methods), len(classFile.Attrs))
+	}
+	for i, method := range classFile.Methods {
+		var expected []string
+		for _, attr := range full.Methods[i].Attrs {
+			if attr.Name() != Code {
+				expected = append(expected, attr.Name())
+			}
+		}
+		var names []string
+		for _, attr := range method.Attrs {
+			names = append(names, attr.Name())
+		}
+		if fmt.Sprint(names) != fmt.Sprint(expected) {
+			t.Errorf("%s attrs %v, expected %v", method.Name, names, expected)
+		}
 	}
-	if accessFlags & FieldAccStatic > 0 {
-		s += " FieldAccStatic "
+}
+
+func TestClassFileQuery(t *testing.T) {
+	classFile, err := NewClassFile(bytes.NewReader(testByteCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if classFile.Name() != "java/util/ArrayList" || classFile.SuperName() != "java/util/AbstractList" ||
+		classFile.SourceFile() != "ArrayList.java" || classFile.BootstrapMethods() != nil {
+		t.Errorf("unexpected class info %s %s %s", classFile.Name(), classFile.SuperName(), classFile.SourceFile())
+	}
+	add := classFile.Method("add", "(Ljava/lang/Object;)Z")
+	if add == nil || add.Code() == nil || add.Exceptions() != nil {
+		t.Errorf("unexpected method add %v", add)
 	}
-	if accessFlags & FieldAccFinal > 0 {
-		s += " FieldAccFinal "
+	writeObject := classFile.Method("writeObject", "(Ljava/io/ObjectOutputStream;)V")
+	if exceptions := writeObject.Exceptions(); len(exceptions) != 1 || exceptions[0].Name.String() != "java/io/IOException" {
+		t.Errorf("unexpected exceptions %v", exceptions)
 	}
-	if accessFlags & FieldAccVolatile > 0 {
-		s += " FieldAccVolatile "
+	if classFile.Method("add", "(Ljava/lang/Integer;)Z") != nil || classFile.Field("add", "(Ljava/lang/Object;)Z") != nil {
+		t.Error("lookup must match name and descriptor")
 	}
-	if accessFlags & FieldAccTransient > 0 {
-		s += " FieldAccTransient "
+	if cv, ok := classFile.Field("serialVersionUID", "J").ConstantValue().(*ConstLong); !ok || cv.Val != 8683452581122892189 {
+		t.Errorf("unexpected constant value %v", cv)
 	}
-	if accessFlags & FieldAccSynthetic > 0 {
-		s += " FieldAccSynthetic "
+	if cv := classFile.Field("elementData", "[Ljava/lang/Object;").ConstantValue(); cv != nil {
+		t.Errorf("unexpected constant value %v", cv)
 	}
-	if accessFlags & FieldAccEnum > 0 {
-		s += " FieldAccEnum "
+
+	// 手工构造的 ClassFile 在查找时建立索引
+	pool := NewEmptyConstantPool()
+	utf8 := func(s string) *ConstUTF8 {
+		i, _ := pool.AddUTF8(s)
+		u, _ := pool.GetUTF8String(i)
+		return u
+	}
+	bm := &BootstrapMethod{}
+	cf := &ClassFile{
+		ConstantPool: pool,
+		Methods: []*Method{{Name: utf8("run"), Descriptor: utf8("()V")}},
+		Attrs: []Attr{&AttrBootstrapMethods{[]*BootstrapMethod{bm}}},
+	}
+	if cf.Method("run", "()V") != cf.Methods[0] || cf.Name() != "" || cf.SuperName() != "" {
+		t.Error("unexpected lookup on constructed class file")
 	}
-	return s
-}
\ No newline at end of file
+	if bms := cf.BootstrapMethods(); len(bms) != 1 || bms[0] != bm {
+		t.Errorf("unexpected bootstrap methods %v", bms)
+	}
+	cf.Fields = append(cf.Fields, &Field{Name: utf8("x"), Descriptor: utf8("I")})
+	cf.ReindexMembers()
+	if cf.Field("x", "I") != cf.Fields[0] {
+		t.Error("field not found after ReindexMembers")
+	}
+}
@@ -23,7 +23,10 @@ const (
 	NameAndType        = 12
 	MethodHandle       = 15
 	MethodType         = 16
+	Dynamic            = 17
 	InvokeDynamic      = 18
+	Module             = 19
+	Package            = 20
 )
 
 const (
@@ -43,6 +46,54 @@ var (
 	ResolvingError = errors.New("resolving fail")
 )
 
+// JVMS 中使用的常量类型名
+var constantKindNames = map[int]string{
+	UTF8: "Utf8",
+	Integer: "Integer",
+	Float: "Float",
+	Long: "Long",
+	Double: "Double",
+	Class: "Class",
+	String: "String",
+	FieldRef: "Fieldref",
+	MethodRef: "Methodref",
+	InterfaceMethodRef: "InterfaceMethodref",
+	NameAndType: "NameAndType",
+	MethodHandle: "MethodHandle",
+	MethodType: "MethodType",
+	Dynamic: "Dynamic",
+	InvokeDynamic: "InvokeDynamic",
+	Module: "Module",
+	Package: "Package",
+}
+
+func ConstantKindName(tag int) string {
+	if name, ok := constantKindNames[tag]; ok {
+		return name
+	}
+	return fmt.Sprintf("tag(%d)", tag)
+}
+
+var refKindNames = []string{
+	RefGetField: "REF_getField",
+	RefGetStatic: "REF_getStatic",
+	RefPutField: "REF_putField",
+	RefPutStatic: "REF_putStatic",
+	RefInvokeVirtual: "REF_invokeVirtual",
+	RefInvokeStatic: "REF_invokeStatic",
+	RefInvokeSpecial: "REF_invokeSpecial",
+	RefNewInvokeSpecial: "REF_newInvokeSpecial",
+	RefInvokeInterface: "REF_invokeInterface",
+}
+
+// 方法句柄的 reference_kind 名，例如 REF_invokeStatic
+func RefKindName(kind uint8) string {
+	if kind >= RefGetField && int(kind) < len(refKindNames) {
+		return refKindNames[kind]
+	}
+	return fmt.Sprintf("REF_%d", kind)
+}
+
 type Constant interface {
 	Tag() int
 	Resolving(*ConstantPool) error
@@ -77,14 +128,23 @@ func NewConstant(tag uint8, reader io.Reader) (Constant, error) {
 		return NewConstMethodHandle(reader)
 	case MethodType:
 		return NewConstMethodType(reader)
+	case Dynamic:
+		return NewConstDynamic(reader)
 	case InvokeDynamic:
 		return NewConstInvokeDynamic(reader)
+	case Module:
+		return NewConstModule(reader)
+	case Package:
+		return NewConstPackage(reader)
 	}
-	return nil, errors.New("unknow constant tag")
+	return nil, fmt.Errorf("unknow constant tag %d", tag)
 }
 
 type ConstUTF8 struct {
 	s string
+	// class文件中的原始字节，写出时原样输出，保留孤立的代理项和非标准编码
+	// 通过 ConstantPool 添加的常量为nil，写出时重新编码
+	raw []byte
 }
 
 func NewConstUTF8(io io.Reader) (*ConstUTF8, error) {
@@ -93,9 +153,6 @@ func NewConstUTF8(io io.Reader) (*ConstUTF8, error) {
 	if err := binary.Read(io, binary.BigEndian, &length); err != nil {
 		return nil, err
 	}
-	if length <= 0 {
-		return nil, ClassFileFormatError
-	}
 	buf := make([]byte, length)
 	if err := binary.Read(io, binary.BigEndian, buf); err != nil {
 		return nil, err
@@ -104,6 +161,7 @@ func NewConstUTF8(io io.Reader) (*ConstUTF8, error) {
 	if cu.s, err = decodeMUTF8(buf); err != nil {
 		return nil, err
 	}
+	cu.raw = buf
 	return cu, nil
 }
 
@@ -115,6 +173,14 @@ func (c *ConstUTF8) Resolving(pool *ConstantPool) error {
 	return nil
 }
 
+// s 为nil时返回空字符串，用于可能为0的索引
+func utf8String(s *ConstUTF8) string {
+	if s == nil {
+		return ""
+	}
+	return s.String()
+}
+
 func (c *ConstUTF8) String() string {
 	return c.s
 }
@@ -433,7 +499,7 @@ func (cd *ConstMethodHandle) Resolving(pool *ConstantPool) error {
 
 	constRef, ok := constant.(ConstRef)
 	if !ok {
-		return ResolvingError
+		return constantKindError(cd.RefIndex, "Fieldref, Methodref or InterfaceMethodref", constant)
 	}
 	cd.Ref = constRef
 	return nil
@@ -472,9 +538,43 @@ func (cmt *ConstMethodType) Resolving(pool *ConstantPool) error {
 	return nil
 }
 
+type ConstDynamic struct {
+	BootstrapMethodAttrIndex uint16
+	NameAndTypeIndex uint16
+	NameAndType *ConstNameAndType
+}
+
+func NewConstDynamic(io io.Reader) (*ConstDynamic, error) {
+	cd := &ConstDynamic{}
+	if err := binary.Read(io, binary.BigEndian, &cd.BootstrapMethodAttrIndex); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(io, binary.BigEndian, &cd.NameAndTypeIndex); err != nil {
+		return nil, err
+	}
+	return cd, nil
+}
+
+func (*ConstDynamic) Tag() int {
+	return Dynamic
+}
+
+func (cd *ConstDynamic) String() string {
+	return fmt.Sprintf("ConstDynamic -> %v", *cd)
+}
+
+func (cd *ConstDynamic) Resolving(pool *ConstantPool) error {
+	var err error
+	if cd.NameAndType, err = pool.GetNameAndType(cd.NameAndTypeIndex); err != nil {
+		return err
+	}
+	return nil
+}
+
 type ConstInvokeDynamic struct {
 	BootstrapMethodAttrIndex uint16
 	NameAndTypeIndex uint16
+	NameAndType *ConstNameAndType
 }
 
 func NewConstInvokeDynamic(io io.Reader) (*ConstInvokeDynamic, error) {
@@ -497,6 +597,68 @@ func (cid *ConstInvokeDynamic) String() string {
 }
 
 func (cid *ConstInvokeDynamic) Resolving(pool *ConstantPool) error {
+	var err error
+	if cid.NameAndType, err = pool.GetNameAndType(cid.NameAndTypeIndex); err != nil {
+		return err
+	}
+	return nil
+}
+
+type ConstModule struct {
+	NameIndex uint16
+	Name *ConstUTF8
+}
+
+func NewConstModule(io io.Reader) (*ConstModule, error) {
+	cm := &ConstModule{}
+	if err := binary.Read(io, binary.BigEndian, &cm.NameIndex); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+func (*ConstModule) Tag() int {
+	return Module
+}
+
+func (cm *ConstModule) String() string {
+	return fmt.Sprintf("ConstModule -> %v", *cm)
+}
+
+func (cm *ConstModule) Resolving(pool *ConstantPool) error {
+	var err error
+	if cm.Name, err = pool.GetUTF8String(cm.NameIndex); err != nil {
+		return err
+	}
+	return nil
+}
+
+type ConstPackage struct {
+	NameIndex uint16
+	Name *ConstUTF8
+}
+
+func NewConstPackage(io io.Reader) (*ConstPackage, error) {
+	cp := &ConstPackage{}
+	if err := binary.Read(io, binary.BigEndian, &cp.NameIndex); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func (*ConstPackage) Tag() int {
+	return Package
+}
+
+func (cp *ConstPackage) String() string {
+	return fmt.Sprintf("ConstPackage -> %v", *cp)
+}
+
+func (cp *ConstPackage) Resolving(pool *ConstantPool) error {
+	var err error
+	if cp.Name, err = pool.GetUTF8String(cp.NameIndex); err != nil {
+		return err
+	}
 	return nil
 }
 
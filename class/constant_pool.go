@@ -1,29 +1,37 @@
 package class
 
 import (
-	"errors"
+	"fmt"
 	"io"
 	"encoding/binary"
 )
 
 type ConstantPool struct {
 	pool []Constant
+	// 常量到索引的反向映射，写class文件时按需构建
+	index map[Constant]uint16
+	utf8Index map[string]uint16
+	// 用于添加常量时去重
+	keys map[constKey]uint16
 }
 
 func NewConstantPool(reader io.Reader) (*ConstantPool, error) {
 	var constantCount uint16
 	if err := binary.Read(reader, binary.BigEndian, &constantCount); err != nil {
-		return nil, err
+		return nil, formatError(reader, err, "constant_pool_count")
 	}
 	constPool := &ConstantPool{pool: make([]Constant, constantCount)}
+	// 每个常量在class文件中的偏移，用于报告解析错误
+	offsets := make([]int64, constantCount)
 	var tag uint8
 	for i := 1; i < int(constantCount); i++ {
+		offsets[i] = readerOffset(reader)
 		if err := binary.Read(reader, binary.BigEndian, &tag); err != nil {
-			return nil, err
+			return nil, formatError(reader, err, "constant_pool[%d]", i)
 		}
 		constant, err := NewConstant(tag, reader)
 		if err != nil {
-			return nil, err
+			return nil, formatError(reader, err, "constant_pool[%d]", i)
 		}
 		constPool.pool[i] = constant
 		if tag == Long || tag == Double {
@@ -34,7 +42,11 @@ func NewConstantPool(reader io.Reader) (*ConstantPool, error) {
 	for i := 1; i < int(constantCount); i++ {
 		if c := constPool.pool[i]; c != nil {
 			if err := c.Resolving(constPool); err != nil {
-				return nil, err
+				cfe := formatError(nil, err, "constant_pool[%d](%s)", i, ConstantKindName(c.Tag())).(*ClassFormatError)
+				if cfe.Offset < 0 {
+					cfe.Offset = offsets[i]
+				}
+				return nil, cfe
 			}
 		}
 	}
@@ -43,7 +55,7 @@ func NewConstantPool(reader io.Reader) (*ConstantPool, error) {
 
 func (cp *ConstantPool) Get(i uint16) (Constant, error) {
 	if int(i) >= cp.Length() {
-		return nil, errors.New("out of ConstantPool index")
+		return nil, &ClassFormatError{Offset: -1, Err: fmt.Errorf("constant pool index %d out of range", i)}
 	}
 	return cp.pool[i], nil
 }
@@ -60,7 +72,16 @@ func (cp *ConstantPool) GetUTF8String(i uint16) (*ConstUTF8, error) {
 	if val, ok := constant.(*ConstUTF8); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstUTF8) failed")
+	return nil, constantKindError(i, "Utf8", constant)
+}
+
+// 与 GetUTF8String 相同，但索引不能为0
+func (cp *ConstantPool) mustGetUTF8String(i uint16) (*ConstUTF8, error) {
+	val, err := cp.GetUTF8String(i)
+	if err == nil && val == nil {
+		return nil, constantKindError(i, "Utf8", nil)
+	}
+	return val, err
 }
 
 func (cp *ConstantPool) GetFloat(i uint16) (*ConstFloat, error) {
@@ -75,7 +96,7 @@ func (cp *ConstantPool) GetFloat(i uint16) (*ConstFloat, error) {
 	if val, ok := constant.(*ConstFloat); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstFloat) failed")
+	return nil, constantKindError(i, "Float", constant)
 }
 
 func (cp *ConstantPool) GetInteger(i uint16) (*ConstInteger, error) {
@@ -90,7 +111,7 @@ func (cp *ConstantPool) GetInteger(i uint16) (*ConstInteger, error) {
 	if val, ok := constant.(*ConstInteger); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstInteger) failed")
+	return nil, constantKindError(i, "Integer", constant)
 }
 
 func (cp *ConstantPool) GetLong(i uint16) (*ConstLong, error) {
@@ -105,7 +126,7 @@ func (cp *ConstantPool) GetLong(i uint16) (*ConstLong, error) {
 	if val, ok := constant.(*ConstLong); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstLong) failed")
+	return nil, constantKindError(i, "Long", constant)
 }
 
 func (cp *ConstantPool) GetDouble(i uint16) (*ConstDouble, error) {
@@ -120,7 +141,7 @@ func (cp *ConstantPool) GetDouble(i uint16) (*ConstDouble, error) {
 	if val, ok := constant.(*ConstDouble); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstDouble) failed")
+	return nil, constantKindError(i, "Double", constant)
 }
 
 func (cp *ConstantPool) GetFieldRef(i uint16) (*ConstFieldRef, error) {
@@ -135,7 +156,7 @@ func (cp *ConstantPool) GetFieldRef(i uint16) (*ConstFieldRef, error) {
 	if val, ok := constant.(*ConstFieldRef); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstFieldRef) failed")
+	return nil, constantKindError(i, "Fieldref", constant)
 }
 
 func (cp *ConstantPool) GetString(i uint16) (*ConstString, error) {
@@ -150,7 +171,7 @@ func (cp *ConstantPool) GetString(i uint16) (*ConstString, error) {
 	if val, ok := constant.(*ConstString); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstString) failed")
+	return nil, constantKindError(i, "String", constant)
 }
 
 func (cp *ConstantPool) GetClass(i uint16) (*ConstClass, error) {
@@ -165,7 +186,16 @@ func (cp *ConstantPool) GetClass(i uint16) (*ConstClass, error) {
 	if val, ok := constant.(*ConstClass); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstClass) failed")
+	return nil, constantKindError(i, "Class", constant)
+}
+
+// 与 GetClass 相同，但索引不能为0
+func (cp *ConstantPool) mustGetClass(i uint16) (*ConstClass, error) {
+	val, err := cp.GetClass(i)
+	if err == nil && val == nil {
+		return nil, constantKindError(i, "Class", nil)
+	}
+	return val, err
 }
 
 func (cp *ConstantPool) GetMethodRef(i uint16) (*ConstMethodRef, error) {
@@ -180,7 +210,7 @@ func (cp *ConstantPool) GetMethodRef(i uint16) (*ConstMethodRef, error) {
 	if val, ok := constant.(*ConstMethodRef); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstMethodRef) failed")
+	return nil, constantKindError(i, "Methodref", constant)
 }
 
 func (cp *ConstantPool) GetInterfaceMethodRef(i uint16) (*ConstInterfaceMethodRef, error) {
@@ -195,7 +225,7 @@ func (cp *ConstantPool) GetInterfaceMethodRef(i uint16) (*ConstInterfaceMethodRe
 	if val, ok := constant.(*ConstInterfaceMethodRef); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstInterfaceMethodRef) failed")
+	return nil, constantKindError(i, "InterfaceMethodref", constant)
 }
 
 func (cp *ConstantPool) GetNameAndType(i uint16) (*ConstNameAndType, error) {
@@ -210,7 +240,7 @@ func (cp *ConstantPool) GetNameAndType(i uint16) (*ConstNameAndType, error) {
 	if val, ok := constant.(*ConstNameAndType); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstNameAndType) failed")
+	return nil, constantKindError(i, "NameAndType", constant)
 }
 
 func (cp *ConstantPool) GetMethodHandle(i uint16) (*ConstMethodHandle, error) {
@@ -225,7 +255,7 @@ func (cp *ConstantPool) GetMethodHandle(i uint16) (*ConstMethodHandle, error) {
 	if val, ok := constant.(*ConstMethodHandle); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstMethodHandle) failed")
+	return nil, constantKindError(i, "MethodHandle", constant)
 }
 
 func (cp *ConstantPool) GetMethodType(i uint16) (*ConstMethodType, error) {
@@ -240,7 +270,22 @@ func (cp *ConstantPool) GetMethodType(i uint16) (*ConstMethodType, error) {
 	if val, ok := constant.(*ConstMethodType); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstMethodType) failed")
+	return nil, constantKindError(i, "MethodType", constant)
+}
+
+func (cp *ConstantPool) GetDynamic(i uint16) (*ConstDynamic, error) {
+	constant, err := cp.Get(i)
+	if err == nil {
+		if constant == nil {
+			return nil, nil
+		}
+	} else {
+		return nil, err
+	}
+	if val, ok := constant.(*ConstDynamic); ok {
+		return val, nil
+	}
+	return nil, constantKindError(i, "Dynamic", constant)
 }
 
 func (cp *ConstantPool) GetInvokeDynamic(i uint16) (*ConstInvokeDynamic, error) {
@@ -255,9 +300,78 @@ func (cp *ConstantPool) GetInvokeDynamic(i uint16) (*ConstInvokeDynamic, error)
 	if val, ok := constant.(*ConstInvokeDynamic); ok {
 		return val, nil
 	}
-	return nil, errors.New("covert to constant.(*ConstInvokeDynamic) failed")
+	return nil, constantKindError(i, "InvokeDynamic", constant)
+}
+
+func (cp *ConstantPool) GetModule(i uint16) (*ConstModule, error) {
+	constant, err := cp.Get(i)
+	if err == nil {
+		if constant == nil {
+			return nil, nil
+		}
+	} else {
+		return nil, err
+	}
+	if val, ok := constant.(*ConstModule); ok {
+		return val, nil
+	}
+	return nil, constantKindError(i, "Module", constant)
+}
+
+func (cp *ConstantPool) GetPackage(i uint16) (*ConstPackage, error) {
+	constant, err := cp.Get(i)
+	if err == nil {
+		if constant == nil {
+			return nil, nil
+		}
+	} else {
+		return nil, err
+	}
+	if val, ok := constant.(*ConstPackage); ok {
+		return val, nil
+	}
+	return nil, constantKindError(i, "Package", constant)
 }
 
 func (cp *ConstantPool) Length() int {
 	return len(cp.pool)
 }
+
+func (cp *ConstantPool) buildIndex() {
+	cp.index = make(map[Constant]uint16, len(cp.pool))
+	cp.utf8Index = make(map[string]uint16)
+	for i := 1; i < len(cp.pool); i++ {
+		c := cp.pool[i]
+		if c == nil {
+			continue
+		}
+		cp.index[c] = uint16(i)
+		if utf8, ok := c.(*ConstUTF8); ok {
+			if _, ok := cp.utf8Index[utf8.s]; !ok {
+				cp.utf8Index[utf8.s] = uint16(i)
+			}
+		}
+	}
+}
+
+// 查找常量在常量池中的索引，常量必须是该常量池中的对象
+func (cp *ConstantPool) IndexOf(c Constant) (uint16, error) {
+	if cp.index == nil {
+		cp.buildIndex()
+	}
+	if i, ok := cp.index[c]; ok {
+		return i, nil
+	}
+	return 0, fmt.Errorf("%s not in ConstantPool", c)
+}
+
+// 按字符串内容查找 CONSTANT_Utf8 的索引，存在多个时返回第一个
+func (cp *ConstantPool) IndexOfUTF8(s string) (uint16, error) {
+	if cp.utf8Index == nil {
+		cp.buildIndex()
+	}
+	if i, ok := cp.utf8Index[s]; ok {
+		return i, nil
+	}
+	return 0, fmt.Errorf("utf8 %q not in ConstantPool", s)
+}
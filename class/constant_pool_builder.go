@@ -0,0 +1,257 @@
+package class
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// 常量池最多 65535 个槽位 (constant_pool_count 为 u2)
+const MaxConstantPoolCount = math.MaxUint16
+
+var (
+	ConstantPoolOverflowError = errors.New("constant pool overflow")
+)
+
+// 用于去重的常量键，引用类常量使用被引用常量的索引
+type constKey struct {
+	tag int
+	s string
+	a, b uint64
+}
+
+func keyOf(c Constant) constKey {
+	switch c := c.(type) {
+	case *ConstUTF8:
+		return constKey{tag: UTF8, s: c.s}
+	case *ConstInteger:
+		return constKey{tag: Integer, a: uint64(uint32(c.Val))}
+	case *ConstFloat:
+		return constKey{tag: Float, a: uint64(math.Float32bits(c.Val))}
+	case *ConstLong:
+		return constKey{tag: Long, a: uint64(c.Val)}
+	case *ConstDouble:
+		return constKey{tag: Double, a: math.Float64bits(c.Val)}
+	case *ConstClass:
+		return constKey{tag: Class, a: uint64(c.NameIndex)}
+	case *ConstString:
+		return constKey{tag: String, a: uint64(c.UTF8StringIndex)}
+	case *ConstFieldRef:
+		return constKey{tag: FieldRef, a: uint64(c.ClassIndex), b: uint64(c.NameAndTypeIndex)}
+	case *ConstMethodRef:
+		return constKey{tag: MethodRef, a: uint64(c.ClassIndex), b: uint64(c.NameAndTypeIndex)}
+	case *ConstInterfaceMethodRef:
+		return constKey{tag: InterfaceMethodRef, a: uint64(c.ClassIndex), b: uint64(c.NameAndTypeIndex)}
+	case *ConstNameAndType:
+		return constKey{tag: NameAndType, a: uint64(c.NameIndex), b: uint64(c.DescriptorIndex)}
+	case *ConstMethodHandle:
+		return constKey{tag: MethodHandle, a: uint64(c.RefKind), b: uint64(c.RefIndex)}
+	case *ConstMethodType:
+		return constKey{tag: MethodType, a: uint64(c.DescriptorIndex)}
+	case *ConstDynamic:
+		return constKey{tag: Dynamic, a: uint64(c.BootstrapMethodAttrIndex), b: uint64(c.NameAndTypeIndex)}
+	case *ConstInvokeDynamic:
+		return constKey{tag: InvokeDynamic, a: uint64(c.BootstrapMethodAttrIndex), b: uint64(c.NameAndTypeIndex)}
+	case *ConstModule:
+		return constKey{tag: Module, a: uint64(c.NameIndex)}
+	case *ConstPackage:
+		return constKey{tag: Package, a: uint64(c.NameIndex)}
+	}
+	return constKey{tag: c.Tag(), s: fmt.Sprintf("%p", c)}
+}
+
+// 创建一个只包含0号空槽位的常量池，用于生成新的class文件
+func NewEmptyConstantPool() *ConstantPool {
+	return &ConstantPool{pool: make([]Constant, 1)}
+}
+
+func (cp *ConstantPool) buildKeys() {
+	cp.keys = make(map[constKey]uint16, len(cp.pool))
+	for i := 1; i < len(cp.pool); i++ {
+		if c := cp.pool[i]; c != nil {
+			key := keyOf(c)
+			if _, ok := cp.keys[key]; !ok {
+				cp.keys[key] = uint16(i)
+			}
+		}
+	}
+}
+
+// 添加常量，已存在相同的常量时返回已有的索引
+func (cp *ConstantPool) add(c Constant) (uint16, error) {
+	if cp.keys == nil {
+		cp.buildKeys()
+	}
+	key := keyOf(c)
+	if i, ok := cp.keys[key]; ok {
+		return i, nil
+	}
+	if len(cp.pool) == 0 {
+		cp.pool = make([]Constant, 1)
+	}
+	slots := 1
+	if tag := c.Tag(); tag == Long || tag == Double {
+		slots = 2
+	}
+	if len(cp.pool) + slots > MaxConstantPoolCount {
+		return 0, ConstantPoolOverflowError
+	}
+	i := uint16(len(cp.pool))
+	cp.pool = append(cp.pool, c)
+	if slots == 2 {
+		cp.pool = append(cp.pool, nil)
+	}
+	if err := c.Resolving(cp); err != nil {
+		cp.pool = cp.pool[:i]
+		return 0, err
+	}
+	cp.keys[key] = i
+	if cp.index != nil {
+		cp.index[c] = i
+		if utf8, ok := c.(*ConstUTF8); ok {
+			cp.utf8Index[utf8.s] = i
+		}
+	}
+	return i, nil
+}
+
+func (cp *ConstantPool) AddUTF8(s string) (uint16, error) {
+	if len(encodeMUTF8(s)) > math.MaxUint16 {
+		return 0, fmt.Errorf("utf8 constant too long: %d", len(s))
+	}
+	return cp.add(&ConstUTF8{s: s})
+}
+
+func (cp *ConstantPool) AddInteger(val int32) (uint16, error) {
+	return cp.add(&ConstInteger{Val: val})
+}
+
+func (cp *ConstantPool) AddFloat(val float32) (uint16, error) {
+	return cp.add(&ConstFloat{Val: val})
+}
+
+// long 占用两个槽位
+func (cp *ConstantPool) AddLong(val int64) (uint16, error) {
+	return cp.add(&ConstLong{Val: val})
+}
+
+// double 占用两个槽位
+func (cp *ConstantPool) AddDouble(val float64) (uint16, error) {
+	return cp.add(&ConstDouble{Val: val})
+}
+
+// name 为内部形式的类名，例如 java/lang/Object
+func (cp *ConstantPool) AddClass(name string) (uint16, error) {
+	nameIndex, err := cp.AddUTF8(name)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(&ConstClass{NameIndex: nameIndex})
+}
+
+func (cp *ConstantPool) AddString(s string) (uint16, error) {
+	utf8Index, err := cp.AddUTF8(s)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(&ConstString{UTF8StringIndex: utf8Index})
+}
+
+func (cp *ConstantPool) AddNameAndType(name, descriptor string) (uint16, error) {
+	nameIndex, err := cp.AddUTF8(name)
+	if err != nil {
+		return 0, err
+	}
+	descriptorIndex, err := cp.AddUTF8(descriptor)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(&ConstNameAndType{NameIndex: nameIndex, DescriptorIndex: descriptorIndex})
+}
+
+func (cp *ConstantPool) addRef(class, name, descriptor string) (*ConstFieldRef, error) {
+	classIndex, err := cp.AddClass(class)
+	if err != nil {
+		return nil, err
+	}
+	nameAndTypeIndex, err := cp.AddNameAndType(name, descriptor)
+	if err != nil {
+		return nil, err
+	}
+	return &ConstFieldRef{ClassIndex: classIndex, NameAndTypeIndex: nameAndTypeIndex}, nil
+}
+
+func (cp *ConstantPool) AddFieldRef(class, name, descriptor string) (uint16, error) {
+	ref, err := cp.addRef(class, name, descriptor)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(ref)
+}
+
+func (cp *ConstantPool) AddMethodRef(class, name, descriptor string) (uint16, error) {
+	ref, err := cp.addRef(class, name, descriptor)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(&ConstMethodRef{ConstFieldRef: ref})
+}
+
+func (cp *ConstantPool) AddInterfaceMethodRef(class, name, descriptor string) (uint16, error) {
+	ref, err := cp.addRef(class, name, descriptor)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(&ConstInterfaceMethodRef{ConstFieldRef: ref})
+}
+
+// refIndex 必须指向 CONSTANT_Fieldref、CONSTANT_Methodref 或 CONSTANT_InterfaceMethodref
+func (cp *ConstantPool) AddMethodHandle(refKind uint8, refIndex uint16) (uint16, error) {
+	if refKind < RefGetField || refKind > RefInvokeInterface {
+		return 0, errors.New("invaild method handle kind")
+	}
+	return cp.add(&ConstMethodHandle{RefKind: refKind, RefIndex: refIndex})
+}
+
+func (cp *ConstantPool) AddMethodType(descriptor string) (uint16, error) {
+	descriptorIndex, err := cp.AddUTF8(descriptor)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(&ConstMethodType{DescriptorIndex: descriptorIndex})
+}
+
+// bootstrapMethodAttrIndex 为 BootstrapMethods 属性中的下标
+func (cp *ConstantPool) AddDynamic(bootstrapMethodAttrIndex uint16, name, descriptor string) (uint16, error) {
+	nameAndTypeIndex, err := cp.AddNameAndType(name, descriptor)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(&ConstDynamic{BootstrapMethodAttrIndex: bootstrapMethodAttrIndex, NameAndTypeIndex: nameAndTypeIndex})
+}
+
+// bootstrapMethodAttrIndex 为 BootstrapMethods 属性中的下标
+func (cp *ConstantPool) AddInvokeDynamic(bootstrapMethodAttrIndex uint16, name, descriptor string) (uint16, error) {
+	nameAndTypeIndex, err := cp.AddNameAndType(name, descriptor)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(&ConstInvokeDynamic{BootstrapMethodAttrIndex: bootstrapMethodAttrIndex, NameAndTypeIndex: nameAndTypeIndex})
+}
+
+func (cp *ConstantPool) AddModule(name string) (uint16, error) {
+	nameIndex, err := cp.AddUTF8(name)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(&ConstModule{NameIndex: nameIndex})
+}
+
+// name 为内部形式的包名，例如 java/lang
+func (cp *ConstantPool) AddPackage(name string) (uint16, error) {
+	nameIndex, err := cp.AddUTF8(name)
+	if err != nil {
+		return 0, err
+	}
+	return cp.add(&ConstPackage{NameIndex: nameIndex})
+}
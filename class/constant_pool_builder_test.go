@@ -0,0 +1,144 @@
+package class
+
+import (
+	"testing"
+	"bytes"
+)
+
+func TestConstantPoolAdd(t *testing.T) {
+	pool := NewEmptyConstantPool()
+	objectIndex, err := pool.AddClass("java/lang/Object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objectIndex != 2 || pool.Length() != 3 {
+		t.Errorf("unexpected index %d, length %d", objectIndex, pool.Length())
+	}
+	if i, _ := pool.AddClass("java/lang/Object"); i != objectIndex {
+		t.Errorf("class not deduplicated: %d", i)
+	}
+	if i, _ := pool.AddUTF8("java/lang/Object"); i != 1 {
+		t.Errorf("utf8 not deduplicated: %d", i)
+	}
+
+	longIndex, err := pool.AddLong(1 << 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intIndex, err := pool.AddInteger(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intIndex != longIndex + 2 {
+		t.Errorf("long should take two slots: %d %d", longIndex, intIndex)
+	}
+	if i, _ := pool.AddLong(1 << 40); i != longIndex {
+		t.Errorf("long not deduplicated: %d", i)
+	}
+
+	methodRefIndex, err := pool.AddMethodRef("java/lang/Object", "<init>", "()V")
+	if err != nil {
+		t.Fatal(err)
+	}
+	methodRef, err := pool.GetMethodRef(methodRefIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if methodRef.Class.Name.String() != "java/lang/Object" || methodRef.NameAndType.Name.String() != "<init>" {
+		t.Errorf("method ref not resolved: %v", methodRef)
+	}
+	fieldRefIndex, err := pool.AddFieldRef("java/lang/Object", "<init>", "()V")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fieldRefIndex == methodRefIndex {
+		t.Error("field ref and method ref should not be merged")
+	}
+
+	handleIndex, err := pool.AddMethodHandle(RefNewInvokeSpecial, methodRefIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, _ := pool.AddMethodHandle(RefNewInvokeSpecial, methodRefIndex); i != handleIndex {
+		t.Errorf("method handle not deduplicated: %d", i)
+	}
+	length := pool.Length()
+	if _, err := pool.AddMethodHandle(RefInvokeStatic, objectIndex); err == nil {
+		t.Error("expected method handle resolving error")
+	}
+	if pool.Length() != length {
+		t.Error("failed constant should not be kept")
+	}
+
+	indyIndex, err := pool.AddInvokeDynamic(0, "run", "()Ljava/lang/Runnable;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indy, err := pool.GetInvokeDynamic(indyIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indy.NameAndType.Descriptor.String() != "()Ljava/lang/Runnable;" {
+		t.Errorf("invoke dynamic not resolved: %v", indy)
+	}
+}
+
+func TestConstantPoolAddToParsedPool(t *testing.T) {
+	classFile, err := NewClassFile(bytes.NewReader(testByteCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := classFile.ConstantPool
+	length := pool.Length()
+	i, err := pool.AddClass(classFile.ThisClass.Name.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index, _ := pool.IndexOf(classFile.ThisClass); i != index || pool.Length() != length {
+		t.Errorf("existing class not reused: %d", i)
+	}
+	i, err = pool.AddString("jvm4go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(i) != length + 1 {
+		t.Errorf("unexpected index %d", i)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := classFile.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	classFile, err = NewClassFile(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := classFile.ConstantPool.GetString(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.UTF8String.String() != "jvm4go" {
+		t.Errorf("unexpected string %s", s)
+	}
+}
+
+func TestConstantPoolOverflow(t *testing.T) {
+	pool := NewEmptyConstantPool()
+	for i := 0; i < MaxConstantPoolCount - 2; i++ {
+		if _, err := pool.AddInteger(int32(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := pool.AddLong(0); err != ConstantPoolOverflowError {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if _, err := pool.AddInteger(-1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.AddInteger(-2); err != ConstantPoolOverflowError {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if _, err := pool.AddInteger(0); err != nil {
+		t.Errorf("existing constant should be reused: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package class
+
+import (
+	"testing"
+	"bytes"
+	"encoding/binary"
+)
+
+// 用于在测试中手工拼装class文件片段
+type testWriter struct {
+	bytes.Buffer
+}
+
+func (w *testWriter) write(v interface{}) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *testWriter) writeUTF8(s string) {
+	w.write(uint8(UTF8))
+	w.write(uint16(len(s)))
+	w.WriteString(s)
+}
+
+func TestNewConstantPoolModernTags(t *testing.T) {
+	buf := &testWriter{}
+	write := buf.write
+	writeUTF8 := buf.writeUTF8
+	write(uint16(8))
+	writeUTF8("value")              // #1
+	writeUTF8("I")                  // #2
+	write(uint8(NameAndType))       // #3
+	write(uint16(1))
+	write(uint16(2))
+	write(uint8(Dynamic))           // #4
+	write(uint16(0))
+	write(uint16(3))
+	writeUTF8("java.base")          // #5
+	write(uint8(Module))            // #6
+	write(uint16(5))
+	write(uint8(Package))           // #7
+	write(uint16(1))
+
+	pool, err := NewConstantPool(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dynamic, err := pool.GetDynamic(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dynamic.NameAndType == nil || dynamic.NameAndType.Name.String() != "value" ||
+		dynamic.NameAndType.Descriptor.String() != "I" {
+		t.Errorf("dynamic constant not resolved: %v", dynamic)
+	}
+	module, err := pool.GetModule(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if module.Name.String() != "java.base" {
+		t.Errorf("module name %s", module.Name)
+	}
+	pkg, err := pool.GetPackage(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Name.String() != "value" {
+		t.Errorf("package name %s", pkg.Name)
+	}
+	if _, err := pool.GetModule(7); err == nil {
+		t.Error("expected type mismatch error")
+	}
+}
+
+func TestReadClassFileVersion(t *testing.T) {
+	versions := []struct {
+		major, minor uint16
+		ok bool
+	}{
+		{45, 3, true},
+		{52, 0, true},
+		{55, 0, true},
+		{61, 0, true},
+		{65, 0xffff, true},
+		{MaxMajorVersion, 0, true},
+		{44, 0, false},
+		{MaxMajorVersion + 1, 0, false},
+		{55, 0xffff, false},
+		{61, 1, false},
+	}
+	for _, v := range versions {
+		buf := &bytes.Buffer{}
+		binary.Write(buf, binary.BigEndian, v.minor)
+		binary.Write(buf, binary.BigEndian, v.major)
+		classFile := &ClassFile{reader: buf}
+		err := classFile.readAndCheckClassFileVersion()
+		if v.ok && err != nil {
+			t.Errorf("%d.%d: %s", v.major, v.minor, err)
+		}
+		if !v.ok && err == nil {
+			t.Errorf("%d.%d: expected error", v.major, v.minor)
+		}
+	}
+}
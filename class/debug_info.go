@@ -0,0 +1,104 @@
+package class
+
+import "sort"
+
+// LocalVariableTable 中的局部变量，合并了 LocalVariableTypeTable 中的泛型签名
+type LocalVariable struct {
+	Name string
+	Descriptor string
+	// 没有对应的 LocalVariableTypeTable 项时为空
+	Signature string
+	Slot int
+	// 作用域为 [StartPC, StartPC + Length)
+	StartPC, Length int
+}
+
+func (lv *LocalVariable) covers(pc int) bool {
+	return lv.StartPC <= pc && pc < lv.StartPC + lv.Length
+}
+
+// pc 处的源码行号，没有 LineNumberTable 或 pc 在所有项之前时返回 -1
+// 可能有多个 LineNumberTable 属性，项也不保证有序，取 start_pc 不超过 pc 的最大者
+func (method *Method) LineAt(pc int) int {
+	code := method.Code()
+	if code == nil {
+		return -1
+	}
+	line, best := -1, -1
+	for _, attr := range code.Attrs {
+		table, ok := attr.(*AttrLineNumberTable)
+		if !ok {
+			continue
+		}
+		for _, entry := range table.LineNumberTable {
+			// start_pc 相同时取第一个
+			if start := int(entry.StartPC); start <= pc && start > best {
+				line, best = int(entry.LineNumber), start
+			}
+		}
+	}
+	return line
+}
+
+// 方法的所有局部变量，按 LocalVariableTable 中出现的顺序
+func (method *Method) LocalVariables() []*LocalVariable {
+	code := method.Code()
+	if code == nil {
+		return nil
+	}
+	type varKey struct {
+		name string
+		slot, start, length int
+	}
+	var vars []*LocalVariable
+	index := map[varKey]*LocalVariable{}
+	for _, attr := range code.Attrs {
+		if table, ok := attr.(*AttrLocalVariableTable); ok {
+			for _, entry := range table.LocalVarTable {
+				lv := &LocalVariable{
+					Name: utf8String(entry.Name),
+					Descriptor: utf8String(entry.Descriptor),
+					Slot: int(entry.Index),
+					StartPC: int(entry.StartPC),
+					Length: int(entry.Length),
+				}
+				vars = append(vars, lv)
+				index[varKey{lv.Name, lv.Slot, lv.StartPC, lv.Length}] = lv
+			}
+		}
+	}
+	// 类型表的项按 name、index、start_pc 和 length 与变量表对应 (JVMS 4.7.14)
+	for _, attr := range code.Attrs {
+		if table, ok := attr.(*AttrLocalVariableTypeTable); ok {
+			for _, entry := range table.LocalVarTypeTable {
+				key := varKey{utf8String(entry.Name), int(entry.Index), int(entry.StartPC), int(entry.Length)}
+				if lv, ok := index[key]; ok {
+					lv.Signature = utf8String(entry.Signature)
+				}
+			}
+		}
+	}
+	return vars
+}
+
+// pc 处有效的局部变量，按槽位排序
+// 同一槽位有多个重叠的项时取 start_pc 最大的，即最内层的作用域
+func (method *Method) LocalsAt(pc int) []*LocalVariable {
+	slots := map[int]*LocalVariable{}
+	for _, lv := range method.LocalVariables() {
+		if !lv.covers(pc) {
+			continue
+		}
+		if prev, ok := slots[lv.Slot]; !ok || lv.StartPC > prev.StartPC {
+			slots[lv.Slot] = lv
+		}
+	}
+	locals := make([]*LocalVariable, 0, len(slots))
+	for _, lv := range slots {
+		locals = append(locals, lv)
+	}
+	sort.Slice(locals, func(i, j int) bool {
+		return locals[i].Slot < locals[j].Slot
+	})
+	return locals
+}
@@ -0,0 +1,85 @@
+package class
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLineAt(t *testing.T) {
+	cf, err := NewClassFile(bytes.NewReader(testByteCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := cf.Method("addAll", "(Ljava/util/Collection;)Z")
+	for pc, line := range map[int]int{0: 577, 6: 577, 7: 578, 9: 578, 10: 579, 44: 582, 1000: 582} {
+		if l := method.LineAt(pc); l != line {
+			t.Errorf("pc %d: line %d, expected %d", pc, l, line)
+		}
+	}
+	// 多个无序的表
+	method = &Method{Attrs: []Attr{&AttrCode{Attrs: []Attr{
+		&AttrLineNumberTable{[]*LineNumberTableEntry{{10, 3}, {0, 1}}},
+		&AttrLineNumberTable{[]*LineNumberTableEntry{{5, 2}, {10, 4}}},
+	}}}}
+	for pc, line := range map[int]int{0: 1, 5: 2, 9: 2, 10: 3, 20: 3} {
+		if l := method.LineAt(pc); l != line {
+			t.Errorf("pc %d: line %d, expected %d", pc, l, line)
+		}
+	}
+	if l := (&Method{}).LineAt(0); l != -1 {
+		t.Errorf("line %d for method without code", l)
+	}
+}
+
+func TestLocalsAt(t *testing.T) {
+	pool := NewEmptyConstantPool()
+	utf8 := func(s string) *ConstUTF8 {
+		i, _ := pool.AddUTF8(s)
+		u, _ := pool.GetUTF8String(i)
+		return u
+	}
+	method := &Method{Attrs: []Attr{&AttrCode{Attrs: []Attr{
+		&AttrLocalVariableTable{[]*LocalVarTableEntry{
+			{0, 30, utf8("this"), utf8("LFoo;"), 0},
+			{5, 10, utf8("i"), utf8("I"), 2},
+		}},
+		&AttrLocalVariableTable{[]*LocalVarTableEntry{
+			{0, 30, utf8("list"), utf8("Ljava/util/List;"), 1},
+			{15, 15, utf8("s"), utf8("Ljava/lang/String;"), 2},
+			{20, 5, utf8("t"), utf8("Ljava/lang/String;"), 2},
+		}},
+		&AttrLocalVariableTypeTable{[]*LocalVarTypeTableEntry{
+			{0, 30, utf8("list"), utf8("Ljava/util/List<Ljava/lang/String;>;"), 1},
+		}},
+	}}}}
+	for _, test := range []struct {
+		pc int
+		names string
+	}{
+		{0, "this list"},
+		{5, "this list i"},
+		{14, "this list i"},
+		{15, "this list s"},
+		{22, "this list t"},
+		{25, "this list s"},
+		{30, ""},
+	} {
+		names := ""
+		for _, lv := range method.LocalsAt(test.pc) {
+			if names != "" {
+				names += " "
+			}
+			names += lv.Name
+		}
+		if names != test.names {
+			t.Errorf("pc %d: locals %q, expected %q", test.pc, names, test.names)
+		}
+	}
+	list := method.LocalsAt(0)[1]
+	if list.Slot != 1 || list.Descriptor != "Ljava/util/List;" || list.Signature != "Ljava/util/List<Ljava/lang/String;>;" {
+		t.Errorf("unexpected local %+v", list)
+	}
+	if this := method.LocalsAt(0)[0]; this.Signature != "" {
+		t.Errorf("unexpected signature %q", this.Signature)
+	}
+}
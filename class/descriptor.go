@@ -0,0 +1,262 @@
+package class
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 描述符中的类型标记 (JVMS 4.3.2)
+const (
+	TypeByte = 'B'
+	TypeChar = 'C'
+	TypeDouble = 'D'
+	TypeFloat = 'F'
+	TypeInt = 'I'
+	TypeLong = 'J'
+	TypeShort = 'S'
+	TypeBoolean = 'Z'
+	TypeVoid = 'V'
+	TypeObject = 'L'
+	TypeArray = '['
+)
+
+// 数组最多 255 维
+const MaxArrayDimensions = 255
+
+var baseTypeNames = map[byte]string{
+	TypeByte: "byte",
+	TypeChar: "char",
+	TypeDouble: "double",
+	TypeFloat: "float",
+	TypeInt: "int",
+	TypeLong: "long",
+	TypeShort: "short",
+	TypeBoolean: "boolean",
+	TypeVoid: "void",
+}
+
+type DescriptorError struct {
+	Descriptor string
+	// 出错的位置
+	Pos int
+	Reason string
+}
+
+func (e *DescriptorError) Error() string {
+	return fmt.Sprintf("invalid descriptor %q at %d: %s", e.Descriptor, e.Pos, e.Reason)
+}
+
+// 字段类型，也用于方法的参数和返回值
+type FieldType struct {
+	// TypeXxx 之一
+	Kind byte
+	// 对象类型的内部类名，例如 java/lang/String
+	ClassName string
+	// 数组的元素类型
+	Elem *FieldType
+}
+
+func (t *FieldType) IsPrimitive() bool {
+	return t.Kind != TypeObject && t.Kind != TypeArray && t.Kind != TypeVoid
+}
+
+func (t *FieldType) IsReference() bool {
+	return t.Kind == TypeObject || t.Kind == TypeArray
+}
+
+// 数组维数，非数组为0
+func (t *FieldType) Dimensions() int {
+	n := 0
+	for ; t.Kind == TypeArray; t = t.Elem {
+		n++
+	}
+	return n
+}
+
+// 去掉所有数组维度后的类型
+func (t *FieldType) ElementType() *FieldType {
+	for t.Kind == TypeArray {
+		t = t.Elem
+	}
+	return t
+}
+
+// 在局部变量表和操作数栈中占用的槽位数，long 和 double 占两个，void 为0
+func (t *FieldType) Slots() int {
+	switch t.Kind {
+	case TypeLong, TypeDouble:
+		return 2
+	case TypeVoid:
+		return 0
+	}
+	return 1
+}
+
+// 描述符形式，例如 [Ljava/lang/String;
+func (t *FieldType) String() string {
+	switch t.Kind {
+	case TypeObject:
+		return "L" + t.ClassName + ";"
+	case TypeArray:
+		return "[" + t.Elem.String()
+	}
+	return string(t.Kind)
+}
+
+// Java 源码形式，例如 java.lang.String[]
+func (t *FieldType) JavaName() string {
+	switch t.Kind {
+	case TypeObject:
+		return strings.Replace(t.ClassName, "/", ".", -1)
+	case TypeArray:
+		return t.Elem.JavaName() + "[]"
+	}
+	return baseTypeNames[t.Kind]
+}
+
+type MethodDescriptor struct {
+	Params []*FieldType
+	Return *FieldType
+}
+
+// 参数占用的槽位数，不包括 this
+func (m *MethodDescriptor) ArgSlots() int {
+	n := 0
+	for _, param := range m.Params {
+		n += param.Slots()
+	}
+	return n
+}
+
+func (m *MethodDescriptor) String() string {
+	s := "("
+	for _, param := range m.Params {
+		s += param.String()
+	}
+	return s + ")" + m.Return.String()
+}
+
+// Java 源码形式，例如 void (int, java.lang.String[])
+func (m *MethodDescriptor) JavaName() string {
+	params := make([]string, len(m.Params))
+	for i, param := range m.Params {
+		params[i] = param.JavaName()
+	}
+	return m.Return.JavaName() + " (" + strings.Join(params, ", ") + ")"
+}
+
+type descriptorParser struct {
+	desc string
+	pos int
+}
+
+func (p *descriptorParser) fail(reason string) error {
+	return &DescriptorError{Descriptor: p.desc, Pos: p.pos, Reason: reason}
+}
+
+func (p *descriptorParser) fieldType(void bool) (*FieldType, error) {
+	if p.pos >= len(p.desc) {
+		return nil, p.fail("unexpected end of descriptor")
+	}
+	c := p.desc[p.pos]
+	switch c {
+	case TypeObject:
+		end := strings.IndexByte(p.desc[p.pos:], ';')
+		if end < 0 {
+			return nil, p.fail("unterminated class name")
+		}
+		name := p.desc[p.pos + 1:p.pos + end]
+		if err := checkClassName(name); err != "" {
+			p.pos++
+			return nil, p.fail(err)
+		}
+		p.pos += end + 1
+		return &FieldType{Kind: TypeObject, ClassName: name}, nil
+	case TypeArray:
+		start := p.pos
+		for p.pos < len(p.desc) && p.desc[p.pos] == TypeArray {
+			p.pos++
+		}
+		dims := p.pos - start
+		if dims > MaxArrayDimensions {
+			p.pos = start
+			return nil, p.fail("too many array dimensions")
+		}
+		t, err := p.fieldType(false)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < dims; i++ {
+			t = &FieldType{Kind: TypeArray, Elem: t}
+		}
+		return t, nil
+	case TypeVoid:
+		if !void {
+			return nil, p.fail("void is only allowed as return type")
+		}
+	default:
+		if _, ok := baseTypeNames[c]; !ok {
+			return nil, p.fail(fmt.Sprintf("unknow type %q", c))
+		}
+	}
+	p.pos++
+	return &FieldType{Kind: c}, nil
+}
+
+// 检查内部形式的类名 (JVMS 4.2.1)，返回错误原因
+func checkClassName(name string) string {
+	if name == "" {
+		return "empty class name"
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" {
+			return "empty class name segment"
+		}
+		if strings.ContainsAny(part, ".;[") {
+			return fmt.Sprintf("illegal character in class name %q", name)
+		}
+	}
+	return ""
+}
+
+// 解析字段描述符，例如 [Ljava/lang/String;
+func ParseFieldDescriptor(desc string) (*FieldType, error) {
+	p := &descriptorParser{desc: desc}
+	t, err := p.fieldType(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(desc) {
+		return nil, p.fail("trailing characters")
+	}
+	return t, nil
+}
+
+// 解析方法描述符，例如 (ILjava/lang/String;)V
+func ParseMethodDescriptor(desc string) (*MethodDescriptor, error) {
+	p := &descriptorParser{desc: desc}
+	if !strings.HasPrefix(desc, "(") {
+		return nil, p.fail("method descriptor must start with '('")
+	}
+	p.pos++
+	m := &MethodDescriptor{}
+	for p.pos < len(desc) && desc[p.pos] != ')' {
+		param, err := p.fieldType(false)
+		if err != nil {
+			return nil, err
+		}
+		m.Params = append(m.Params, param)
+	}
+	if p.pos >= len(desc) {
+		return nil, p.fail("missing ')'")
+	}
+	p.pos++
+	var err error
+	if m.Return, err = p.fieldType(true); err != nil {
+		return nil, err
+	}
+	if p.pos != len(desc) {
+		return nil, p.fail("trailing characters")
+	}
+	return m, nil
+}
@@ -0,0 +1,95 @@
+package class
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFieldDescriptor(t *testing.T) {
+	for _, test := range []struct {
+		desc, java string
+		dims, slots int
+	}{
+		{"I", "int", 0, 1},
+		{"J", "long", 0, 2},
+		{"D", "double", 0, 2},
+		{"Ljava/util/List;", "java.util.List", 0, 1},
+		{"[Ljava/util/List;", "java.util.List[]", 1, 1},
+		{"[[J", "long[][]", 2, 1},
+		{"Ljava/util/Map$Entry;", "java.util.Map$Entry", 0, 1},
+	} {
+		typ, err := ParseFieldDescriptor(test.desc)
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		if typ.JavaName() != test.java || typ.Dimensions() != test.dims || typ.Slots() != test.slots || typ.String() != test.desc {
+			t.Errorf("%s: %s %d %d %s", test.desc, typ.JavaName(), typ.Dimensions(), typ.Slots(), typ)
+		}
+	}
+}
+
+func TestParseMethodDescriptor(t *testing.T) {
+	m, err := ParseMethodDescriptor("(IJ[Ljava/lang/String;D)V")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Params) != 4 || m.ArgSlots() != 6 || m.Return.Kind != TypeVoid {
+		t.Errorf("unexpected method descriptor %v", m)
+	}
+	if java := m.JavaName(); java != "void (int, long, java.lang.String[], double)" {
+		t.Errorf("java name %q", java)
+	}
+	if m.Params[2].ElementType().ClassName != "java/lang/String" {
+		t.Errorf("element type %v", m.Params[2].ElementType())
+	}
+}
+
+func TestDescriptorErrors(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		method bool
+		pos int
+		reason string
+	}{
+		{"", false, 0, "unexpected end"},
+		{"V", false, 0, "void"},
+		{"II", false, 1, "trailing"},
+		{"Ljava/lang/String", false, 0, "unterminated"},
+		{"L;", false, 1, "empty class name"},
+		{"Ljava//String;", false, 1, "empty class name segment"},
+		{"Ljava.lang.String;", false, 1, "illegal character"},
+		{"Q", false, 0, "unknow type"},
+		{strings.Repeat("[", 256) + "I", false, 0, "dimensions"},
+		{"I)V", true, 0, "must start"},
+		{"(I", true, 2, "missing ')'"},
+		{"(V)V", true, 1, "void"},
+		{"()", true, 2, "unexpected end"},
+		{"()VV", true, 3, "trailing"},
+	} {
+		var err error
+		if test.method {
+			_, err = ParseMethodDescriptor(test.desc)
+		} else {
+			_, err = ParseFieldDescriptor(test.desc)
+		}
+		de, ok := err.(*DescriptorError)
+		if !ok || de.Pos != test.pos || !strings.Contains(de.Reason, test.reason) {
+			t.Errorf("%q: unexpected error %v", test.desc, err)
+		}
+	}
+}
+
+// 手工构造的 Field、Method 没有描述符时返回错误而不是空指针
+func TestTypeWithoutDescriptor(t *testing.T) {
+	if _, err := (&Field{}).Type(); err == nil {
+		t.Error("expected field descriptor error")
+	} else if _, ok := err.(*DescriptorError); !ok {
+		t.Errorf("unexpected error %T", err)
+	}
+	if _, err := (&Method{}).Type(); err == nil {
+		t.Error("expected method descriptor error")
+	} else if _, ok := err.(*DescriptorError); !ok {
+		t.Errorf("unexpected error %T", err)
+	}
+}
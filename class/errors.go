@@ -0,0 +1,107 @@
+package class
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// class文件格式错误，记录出错的位置和结构路径
+type ClassFormatError struct {
+	// 已经读取到 this_class 时为类名，否则为空
+	ClassName string
+	// 检测到错误时在class文件中的字节偏移，未知时为-1
+	Offset int64
+	// 结构路径，例如 methods[12].attributes[0](Code).exception_table[3]
+	Path string
+	// 常量类型不匹配时期望的和实际的常量类型
+	Expected string
+	Actual string
+	Err error
+}
+
+func (e *ClassFormatError) Error() string {
+	s := "class format error"
+	if e.ClassName != "" {
+		s += " in " + e.ClassName
+	}
+	if e.Offset >= 0 {
+		s += fmt.Sprintf(" at offset %d", e.Offset)
+	}
+	if e.Path != "" {
+		s += " (" + e.Path + ")"
+	}
+	if e.Expected != "" {
+		s += fmt.Sprintf(": expected %s constant, got %s", e.Expected, e.Actual)
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *ClassFormatError) Unwrap() error {
+	return e.Err
+}
+
+// 使 errors.Is(err, ClassFileFormatError) 对所有格式错误成立
+func (e *ClassFormatError) Is(target error) bool {
+	return target == ClassFileFormatError
+}
+
+// 记录读取偏移量的 reader
+type offsetReader struct {
+	reader io.Reader
+	offset int64
+}
+
+func (r *offsetReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func readerOffset(reader io.Reader) int64 {
+	if r, ok := reader.(*offsetReader); ok {
+		return r.offset
+	}
+	return -1
+}
+
+// 将错误包装为 ClassFormatError 并在结构路径前添加一段
+// 以 "(" 开头的段直接追加到上一段之后，例如 attributes[0](Code)
+func formatError(reader io.Reader, err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	cfe, ok := err.(*ClassFormatError)
+	if !ok {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		cfe = &ClassFormatError{Offset: -1, Err: err}
+	}
+	if cfe.Offset < 0 {
+		cfe.Offset = readerOffset(reader)
+	}
+	segment := fmt.Sprintf(format, args...)
+	if segment != "" && cfe.Path != "" && !strings.HasPrefix(cfe.Path, "(") {
+		segment += "."
+	}
+	cfe.Path = segment + cfe.Path
+	return cfe
+}
+
+// 常量类型不匹配
+func constantKindError(index uint16, expected string, actual Constant) error {
+	kind := "none"
+	if actual != nil {
+		kind = ConstantKindName(actual.Tag())
+	}
+	return &ClassFormatError{
+		Offset: -1,
+		Expected: expected,
+		Actual: kind,
+		Err: fmt.Errorf("constant #%d", index),
+	}
+}
@@ -0,0 +1,73 @@
+package class
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestClassFormatErrorPath(t *testing.T) {
+	buf := &testWriter{}
+	buf.write(uint32(ClassFileMagic))
+	buf.write(uint16(0))
+	buf.write(uint16(52))
+	buf.write(uint16(6))
+	buf.writeUTF8("Foo")                  // #1
+	buf.write(uint8(Class))               // #2
+	buf.write(uint16(1))
+	buf.writeUTF8("m")                    // #3
+	buf.writeUTF8("()V")                  // #4
+	buf.writeUTF8(Code)                   // #5
+	buf.write([]uint16{ACCSUPER, 2, 0, 0, 0})
+	buf.write([]uint16{1, ACCPUBLIC, 3, 4, 1})
+	buf.write(uint16(5))
+	buf.write(uint32(21))
+	buf.write([]uint16{1, 1})
+	buf.write(uint32(1))
+	buf.write(uint8(0xb1))
+	buf.write(uint16(1))
+	// catch_type 指向 CONSTANT_Utf8
+	buf.write([]uint16{0, 1, 0, 1})
+
+	_, err := NewClassFile(buf)
+	var cfe *ClassFormatError
+	if !errors.As(err, &cfe) {
+		t.Fatalf("expected *ClassFormatError, got %v", err)
+	}
+	if cfe.ClassName != "Foo" || cfe.Offset != 81 || cfe.Expected != "Class" || cfe.Actual != "Utf8" {
+		t.Errorf("unexpected error fields %+v", cfe)
+	}
+	if path := "methods[0].attributes[0](Code).exception_table[0]"; cfe.Path != path {
+		t.Errorf("path %q, expected %q", cfe.Path, path)
+	}
+	if !errors.Is(err, ClassFileFormatError) {
+		t.Error("expected errors.Is(err, ClassFileFormatError)")
+	}
+	expected := "class format error in Foo at offset 81 (methods[0].attributes[0](Code).exception_table[0]): " +
+		"expected Class constant, got Utf8: constant #1"
+	if err.Error() != expected {
+		t.Errorf("%q\nexpected %q", err.Error(), expected)
+	}
+}
+
+func TestClassFormatErrorTruncated(t *testing.T) {
+	for _, test := range []struct {
+		n int
+		path string
+	}{
+		{2, "magic"},
+		{4, "minor_version"},
+		{10, "constant_pool[1]"},
+		{len(testByteCode) - 1, "attributes[2](InnerClasses).classes[4]"},
+	} {
+		_, err := NewClassFile(bytes.NewReader(testByteCode[:test.n]))
+		var cfe *ClassFormatError
+		if !errors.As(err, &cfe) {
+			t.Fatalf("%d: expected *ClassFormatError, got %v", test.n, err)
+		}
+		if cfe.Path != test.path || cfe.Offset != int64(test.n) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%d: unexpected error %v", test.n, err)
+		}
+	}
+}
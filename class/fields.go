@@ -1,7 +1,7 @@
 package class
 
 type Field struct {
-	AccessFlags uint16
+	AccessFlags FieldAccessFlags
 	Name *ConstUTF8
 	Descriptor *ConstUTF8
 	Attrs []Attr
@@ -17,4 +17,20 @@ const (
 	FieldAccTransient = 0x0080
 	FieldAccSynthetic = 0x1000
 	FieldAccEnum = 0x4000
-)
\ No newline at end of file
+)
+
+// 解析字段描述符
+func (field *Field) Type() (*FieldType, error) {
+	return ParseFieldDescriptor(utf8String(field.Descriptor))
+}
+
+// ConstantValue 属性的常量，没有时返回nil
+// 常量为 *ConstInteger、*ConstLong、*ConstFloat、*ConstDouble 或 *ConstString
+func (field *Field) ConstantValue() Constant {
+	for _, attr := range field.Attrs {
+		if cv, ok := attr.(*AttrConstantValue); ok {
+			return cv.Val
+		}
+	}
+	return nil
+}
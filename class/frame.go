@@ -137,7 +137,7 @@ func newAppendFrame(frameType uint8, io io.Reader, pool *ConstantPool) (*AppendF
 	for i := 0; i < int(numberOfLocals); i++ {
 		vt, err := NewVerificationType(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "locals[%d]", i)
 		}
 		a.Locals = append(a.Locals, vt)
 	}
@@ -167,7 +167,7 @@ func newFullFrame(frameType uint8, io io.Reader, pool *ConstantPool) (*FullFrame
 	for i := 0; i < int(numberOfLocals); i++ {
 		vt, err := NewVerificationType(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "locals[%d]", i)
 		}
 		f.Locals = append(f.Locals, vt)
 	}
@@ -177,7 +177,7 @@ func newFullFrame(frameType uint8, io io.Reader, pool *ConstantPool) (*FullFrame
 	for i := 0; i < int(numberOfStackItems); i++ {
 		vt, err := NewVerificationType(io, pool)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "stack[%d]", i)
 		}
 		f.Stack = append(f.Stack, vt)
 	}
@@ -0,0 +1,821 @@
+package class
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Opcode uint8
+
+const (
+	OpNop Opcode = 0x00
+	OpAconstNull Opcode = 0x01
+	OpIconstM1 Opcode = 0x02
+	OpIconst0 Opcode = 0x03
+	OpIconst1 Opcode = 0x04
+	OpIconst2 Opcode = 0x05
+	OpIconst3 Opcode = 0x06
+	OpIconst4 Opcode = 0x07
+	OpIconst5 Opcode = 0x08
+	OpLconst0 Opcode = 0x09
+	OpLconst1 Opcode = 0x0a
+	OpFconst0 Opcode = 0x0b
+	OpFconst1 Opcode = 0x0c
+	OpFconst2 Opcode = 0x0d
+	OpDconst0 Opcode = 0x0e
+	OpDconst1 Opcode = 0x0f
+	OpBipush Opcode = 0x10
+	OpSipush Opcode = 0x11
+	OpLdc Opcode = 0x12
+	OpLdcW Opcode = 0x13
+	OpLdc2W Opcode = 0x14
+	OpIload Opcode = 0x15
+	OpLload Opcode = 0x16
+	OpFload Opcode = 0x17
+	OpDload Opcode = 0x18
+	OpAload Opcode = 0x19
+	OpIload0 Opcode = 0x1a
+	OpIload1 Opcode = 0x1b
+	OpIload2 Opcode = 0x1c
+	OpIload3 Opcode = 0x1d
+	OpLload0 Opcode = 0x1e
+	OpLload1 Opcode = 0x1f
+	OpLload2 Opcode = 0x20
+	OpLload3 Opcode = 0x21
+	OpFload0 Opcode = 0x22
+	OpFload1 Opcode = 0x23
+	OpFload2 Opcode = 0x24
+	OpFload3 Opcode = 0x25
+	OpDload0 Opcode = 0x26
+	OpDload1 Opcode = 0x27
+	OpDload2 Opcode = 0x28
+	OpDload3 Opcode = 0x29
+	OpAload0 Opcode = 0x2a
+	OpAload1 Opcode = 0x2b
+	OpAload2 Opcode = 0x2c
+	OpAload3 Opcode = 0x2d
+	OpIaload Opcode = 0x2e
+	OpLaload Opcode = 0x2f
+	OpFaload Opcode = 0x30
+	OpDaload Opcode = 0x31
+	OpAaload Opcode = 0x32
+	OpBaload Opcode = 0x33
+	OpCaload Opcode = 0x34
+	OpSaload Opcode = 0x35
+	OpIstore Opcode = 0x36
+	OpLstore Opcode = 0x37
+	OpFstore Opcode = 0x38
+	OpDstore Opcode = 0x39
+	OpAstore Opcode = 0x3a
+	OpIstore0 Opcode = 0x3b
+	OpIstore1 Opcode = 0x3c
+	OpIstore2 Opcode = 0x3d
+	OpIstore3 Opcode = 0x3e
+	OpLstore0 Opcode = 0x3f
+	OpLstore1 Opcode = 0x40
+	OpLstore2 Opcode = 0x41
+	OpLstore3 Opcode = 0x42
+	OpFstore0 Opcode = 0x43
+	OpFstore1 Opcode = 0x44
+	OpFstore2 Opcode = 0x45
+	OpFstore3 Opcode = 0x46
+	OpDstore0 Opcode = 0x47
+	OpDstore1 Opcode = 0x48
+	OpDstore2 Opcode = 0x49
+	OpDstore3 Opcode = 0x4a
+	OpAstore0 Opcode = 0x4b
+	OpAstore1 Opcode = 0x4c
+	OpAstore2 Opcode = 0x4d
+	OpAstore3 Opcode = 0x4e
+	OpIastore Opcode = 0x4f
+	OpLastore Opcode = 0x50
+	OpFastore Opcode = 0x51
+	OpDastore Opcode = 0x52
+	OpAastore Opcode = 0x53
+	OpBastore Opcode = 0x54
+	OpCastore Opcode = 0x55
+	OpSastore Opcode = 0x56
+	OpPop Opcode = 0x57
+	OpPop2 Opcode = 0x58
+	OpDup Opcode = 0x59
+	OpDupX1 Opcode = 0x5a
+	OpDupX2 Opcode = 0x5b
+	OpDup2 Opcode = 0x5c
+	OpDup2X1 Opcode = 0x5d
+	OpDup2X2 Opcode = 0x5e
+	OpSwap Opcode = 0x5f
+	OpIadd Opcode = 0x60
+	OpLadd Opcode = 0x61
+	OpFadd Opcode = 0x62
+	OpDadd Opcode = 0x63
+	OpIsub Opcode = 0x64
+	OpLsub Opcode = 0x65
+	OpFsub Opcode = 0x66
+	OpDsub Opcode = 0x67
+	OpImul Opcode = 0x68
+	OpLmul Opcode = 0x69
+	OpFmul Opcode = 0x6a
+	OpDmul Opcode = 0x6b
+	OpIdiv Opcode = 0x6c
+	OpLdiv Opcode = 0x6d
+	OpFdiv Opcode = 0x6e
+	OpDdiv Opcode = 0x6f
+	OpIrem Opcode = 0x70
+	OpLrem Opcode = 0x71
+	OpFrem Opcode = 0x72
+	OpDrem Opcode = 0x73
+	OpIneg Opcode = 0x74
+	OpLneg Opcode = 0x75
+	OpFneg Opcode = 0x76
+	OpDneg Opcode = 0x77
+	OpIshl Opcode = 0x78
+	OpLshl Opcode = 0x79
+	OpIshr Opcode = 0x7a
+	OpLshr Opcode = 0x7b
+	OpIushr Opcode = 0x7c
+	OpLushr Opcode = 0x7d
+	OpIand Opcode = 0x7e
+	OpLand Opcode = 0x7f
+	OpIor Opcode = 0x80
+	OpLor Opcode = 0x81
+	OpIxor Opcode = 0x82
+	OpLxor Opcode = 0x83
+	OpIinc Opcode = 0x84
+	OpI2L Opcode = 0x85
+	OpI2F Opcode = 0x86
+	OpI2D Opcode = 0x87
+	OpL2I Opcode = 0x88
+	OpL2F Opcode = 0x89
+	OpL2D Opcode = 0x8a
+	OpF2I Opcode = 0x8b
+	OpF2L Opcode = 0x8c
+	OpF2D Opcode = 0x8d
+	OpD2I Opcode = 0x8e
+	OpD2L Opcode = 0x8f
+	OpD2F Opcode = 0x90
+	OpI2B Opcode = 0x91
+	OpI2C Opcode = 0x92
+	OpI2S Opcode = 0x93
+	OpLcmp Opcode = 0x94
+	OpFcmpl Opcode = 0x95
+	OpFcmpg Opcode = 0x96
+	OpDcmpl Opcode = 0x97
+	OpDcmpg Opcode = 0x98
+	OpIfeq Opcode = 0x99
+	OpIfne Opcode = 0x9a
+	OpIflt Opcode = 0x9b
+	OpIfge Opcode = 0x9c
+	OpIfgt Opcode = 0x9d
+	OpIfle Opcode = 0x9e
+	OpIfIcmpeq Opcode = 0x9f
+	OpIfIcmpne Opcode = 0xa0
+	OpIfIcmplt Opcode = 0xa1
+	OpIfIcmpge Opcode = 0xa2
+	OpIfIcmpgt Opcode = 0xa3
+	OpIfIcmple Opcode = 0xa4
+	OpIfAcmpeq Opcode = 0xa5
+	OpIfAcmpne Opcode = 0xa6
+	OpGoto Opcode = 0xa7
+	OpJsr Opcode = 0xa8
+	OpRet Opcode = 0xa9
+	OpTableswitch Opcode = 0xaa
+	OpLookupswitch Opcode = 0xab
+	OpIreturn Opcode = 0xac
+	OpLreturn Opcode = 0xad
+	OpFreturn Opcode = 0xae
+	OpDreturn Opcode = 0xaf
+	OpAreturn Opcode = 0xb0
+	OpReturn Opcode = 0xb1
+	OpGetstatic Opcode = 0xb2
+	OpPutstatic Opcode = 0xb3
+	OpGetfield Opcode = 0xb4
+	OpPutfield Opcode = 0xb5
+	OpInvokevirtual Opcode = 0xb6
+	OpInvokespecial Opcode = 0xb7
+	OpInvokestatic Opcode = 0xb8
+	OpInvokeinterface Opcode = 0xb9
+	OpInvokedynamic Opcode = 0xba
+	OpNew Opcode = 0xbb
+	OpNewarray Opcode = 0xbc
+	OpAnewarray Opcode = 0xbd
+	OpArraylength Opcode = 0xbe
+	OpAthrow Opcode = 0xbf
+	OpCheckcast Opcode = 0xc0
+	OpInstanceof Opcode = 0xc1
+	OpMonitorenter Opcode = 0xc2
+	OpMonitorexit Opcode = 0xc3
+	OpWide Opcode = 0xc4
+	OpMultianewarray Opcode = 0xc5
+	OpIfnull Opcode = 0xc6
+	OpIfnonnull Opcode = 0xc7
+	OpGotoW Opcode = 0xc8
+	OpJsrW Opcode = 0xc9
+)
+
+const (
+	operandNone = iota
+	operandS1
+	operandS2
+	operandU1
+	operandConstU1
+	operandConstU2
+	operandLocal
+	operandIinc
+	operandBranch2
+	operandBranch4
+	operandTableSwitch
+	operandLookupSwitch
+	operandInvokeInterface
+	operandInvokeDynamic
+	operandMultiANewArray
+	operandWide
+)
+
+type opcodeInfo struct {
+	mnemonic string
+	operand int
+}
+
+var opcodes = [256]opcodeInfo{
+	OpNop: {"nop", operandNone},
+	OpAconstNull: {"aconst_null", operandNone},
+	OpIconstM1: {"iconst_m1", operandNone},
+	OpIconst0: {"iconst_0", operandNone},
+	OpIconst1: {"iconst_1", operandNone},
+	OpIconst2: {"iconst_2", operandNone},
+	OpIconst3: {"iconst_3", operandNone},
+	OpIconst4: {"iconst_4", operandNone},
+	OpIconst5: {"iconst_5", operandNone},
+	OpLconst0: {"lconst_0", operandNone},
+	OpLconst1: {"lconst_1", operandNone},
+	OpFconst0: {"fconst_0", operandNone},
+	OpFconst1: {"fconst_1", operandNone},
+	OpFconst2: {"fconst_2", operandNone},
+	OpDconst0: {"dconst_0", operandNone},
+	OpDconst1: {"dconst_1", operandNone},
+	OpBipush: {"bipush", operandS1},
+	OpSipush: {"sipush", operandS2},
+	OpLdc: {"ldc", operandConstU1},
+	OpLdcW: {"ldc_w", operandConstU2},
+	OpLdc2W: {"ldc2_w", operandConstU2},
+	OpIload: {"iload", operandLocal},
+	OpLload: {"lload", operandLocal},
+	OpFload: {"fload", operandLocal},
+	OpDload: {"dload", operandLocal},
+	OpAload: {"aload", operandLocal},
+	OpIload0: {"iload_0", operandNone},
+	OpIload1: {"iload_1", operandNone},
+	OpIload2: {"iload_2", operandNone},
+	OpIload3: {"iload_3", operandNone},
+	OpLload0: {"lload_0", operandNone},
+	OpLload1: {"lload_1", operandNone},
+	OpLload2: {"lload_2", operandNone},
+	OpLload3: {"lload_3", operandNone},
+	OpFload0: {"fload_0", operandNone},
+	OpFload1: {"fload_1", operandNone},
+	OpFload2: {"fload_2", operandNone},
+	OpFload3: {"fload_3", operandNone},
+	OpDload0: {"dload_0", operandNone},
+	OpDload1: {"dload_1", operandNone},
+	OpDload2: {"dload_2", operandNone},
+	OpDload3: {"dload_3", operandNone},
+	OpAload0: {"aload_0", operandNone},
+	OpAload1: {"aload_1", operandNone},
+	OpAload2: {"aload_2", operandNone},
+	OpAload3: {"aload_3", operandNone},
+	OpIaload: {"iaload", operandNone},
+	OpLaload: {"laload", operandNone},
+	OpFaload: {"faload", operandNone},
+	OpDaload: {"daload", operandNone},
+	OpAaload: {"aaload", operandNone},
+	OpBaload: {"baload", operandNone},
+	OpCaload: {"caload", operandNone},
+	OpSaload: {"saload", operandNone},
+	OpIstore: {"istore", operandLocal},
+	OpLstore: {"lstore", operandLocal},
+	OpFstore: {"fstore", operandLocal},
+	OpDstore: {"dstore", operandLocal},
+	OpAstore: {"astore", operandLocal},
+	OpIstore0: {"istore_0", operandNone},
+	OpIstore1: {"istore_1", operandNone},
+	OpIstore2: {"istore_2", operandNone},
+	OpIstore3: {"istore_3", operandNone},
+	OpLstore0: {"lstore_0", operandNone},
+	OpLstore1: {"lstore_1", operandNone},
+	OpLstore2: {"lstore_2", operandNone},
+	OpLstore3: {"lstore_3", operandNone},
+	OpFstore0: {"fstore_0", operandNone},
+	OpFstore1: {"fstore_1", operandNone},
+	OpFstore2: {"fstore_2", operandNone},
+	OpFstore3: {"fstore_3", operandNone},
+	OpDstore0: {"dstore_0", operandNone},
+	OpDstore1: {"dstore_1", operandNone},
+	OpDstore2: {"dstore_2", operandNone},
+	OpDstore3: {"dstore_3", operandNone},
+	OpAstore0: {"astore_0", operandNone},
+	OpAstore1: {"astore_1", operandNone},
+	OpAstore2: {"astore_2", operandNone},
+	OpAstore3: {"astore_3", operandNone},
+	OpIastore: {"iastore", operandNone},
+	OpLastore: {"lastore", operandNone},
+	OpFastore: {"fastore", operandNone},
+	OpDastore: {"dastore", operandNone},
+	OpAastore: {"aastore", operandNone},
+	OpBastore: {"bastore", operandNone},
+	OpCastore: {"castore", operandNone},
+	OpSastore: {"sastore", operandNone},
+	OpPop: {"pop", operandNone},
+	OpPop2: {"pop2", operandNone},
+	OpDup: {"dup", operandNone},
+	OpDupX1: {"dup_x1", operandNone},
+	OpDupX2: {"dup_x2", operandNone},
+	OpDup2: {"dup2", operandNone},
+	OpDup2X1: {"dup2_x1", operandNone},
+	OpDup2X2: {"dup2_x2", operandNone},
+	OpSwap: {"swap", operandNone},
+	OpIadd: {"iadd", operandNone},
+	OpLadd: {"ladd", operandNone},
+	OpFadd: {"fadd", operandNone},
+	OpDadd: {"dadd", operandNone},
+	OpIsub: {"isub", operandNone},
+	OpLsub: {"lsub", operandNone},
+	OpFsub: {"fsub", operandNone},
+	OpDsub: {"dsub", operandNone},
+	OpImul: {"imul", operandNone},
+	OpLmul: {"lmul", operandNone},
+	OpFmul: {"fmul", operandNone},
+	OpDmul: {"dmul", operandNone},
+	OpIdiv: {"idiv", operandNone},
+	OpLdiv: {"ldiv", operandNone},
+	OpFdiv: {"fdiv", operandNone},
+	OpDdiv: {"ddiv", operandNone},
+	OpIrem: {"irem", operandNone},
+	OpLrem: {"lrem", operandNone},
+	OpFrem: {"frem", operandNone},
+	OpDrem: {"drem", operandNone},
+	OpIneg: {"ineg", operandNone},
+	OpLneg: {"lneg", operandNone},
+	OpFneg: {"fneg", operandNone},
+	OpDneg: {"dneg", operandNone},
+	OpIshl: {"ishl", operandNone},
+	OpLshl: {"lshl", operandNone},
+	OpIshr: {"ishr", operandNone},
+	OpLshr: {"lshr", operandNone},
+	OpIushr: {"iushr", operandNone},
+	OpLushr: {"lushr", operandNone},
+	OpIand: {"iand", operandNone},
+	OpLand: {"land", operandNone},
+	OpIor: {"ior", operandNone},
+	OpLor: {"lor", operandNone},
+	OpIxor: {"ixor", operandNone},
+	OpLxor: {"lxor", operandNone},
+	OpIinc: {"iinc", operandIinc},
+	OpI2L: {"i2l", operandNone},
+	OpI2F: {"i2f", operandNone},
+	OpI2D: {"i2d", operandNone},
+	OpL2I: {"l2i", operandNone},
+	OpL2F: {"l2f", operandNone},
+	OpL2D: {"l2d", operandNone},
+	OpF2I: {"f2i", operandNone},
+	OpF2L: {"f2l", operandNone},
+	OpF2D: {"f2d", operandNone},
+	OpD2I: {"d2i", operandNone},
+	OpD2L: {"d2l", operandNone},
+	OpD2F: {"d2f", operandNone},
+	OpI2B: {"i2b", operandNone},
+	OpI2C: {"i2c", operandNone},
+	OpI2S: {"i2s", operandNone},
+	OpLcmp: {"lcmp", operandNone},
+	OpFcmpl: {"fcmpl", operandNone},
+	OpFcmpg: {"fcmpg", operandNone},
+	OpDcmpl: {"dcmpl", operandNone},
+	OpDcmpg: {"dcmpg", operandNone},
+	OpIfeq: {"ifeq", operandBranch2},
+	OpIfne: {"ifne", operandBranch2},
+	OpIflt: {"iflt", operandBranch2},
+	OpIfge: {"ifge", operandBranch2},
+	OpIfgt: {"ifgt", operandBranch2},
+	OpIfle: {"ifle", operandBranch2},
+	OpIfIcmpeq: {"if_icmpeq", operandBranch2},
+	OpIfIcmpne: {"if_icmpne", operandBranch2},
+	OpIfIcmplt: {"if_icmplt", operandBranch2},
+	OpIfIcmpge: {"if_icmpge", operandBranch2},
+	OpIfIcmpgt: {"if_icmpgt", operandBranch2},
+	OpIfIcmple: {"if_icmple", operandBranch2},
+	OpIfAcmpeq: {"if_acmpeq", operandBranch2},
+	OpIfAcmpne: {"if_acmpne", operandBranch2},
+	OpGoto: {"goto", operandBranch2},
+	OpJsr: {"jsr", operandBranch2},
+	OpRet: {"ret", operandLocal},
+	OpTableswitch: {"tableswitch", operandTableSwitch},
+	OpLookupswitch: {"lookupswitch", operandLookupSwitch},
+	OpIreturn: {"ireturn", operandNone},
+	OpLreturn: {"lreturn", operandNone},
+	OpFreturn: {"freturn", operandNone},
+	OpDreturn: {"dreturn", operandNone},
+	OpAreturn: {"areturn", operandNone},
+	OpReturn: {"return", operandNone},
+	OpGetstatic: {"getstatic", operandConstU2},
+	OpPutstatic: {"putstatic", operandConstU2},
+	OpGetfield: {"getfield", operandConstU2},
+	OpPutfield: {"putfield", operandConstU2},
+	OpInvokevirtual: {"invokevirtual", operandConstU2},
+	OpInvokespecial: {"invokespecial", operandConstU2},
+	OpInvokestatic: {"invokestatic", operandConstU2},
+	OpInvokeinterface: {"invokeinterface", operandInvokeInterface},
+	OpInvokedynamic: {"invokedynamic", operandInvokeDynamic},
+	OpNew: {"new", operandConstU2},
+	OpNewarray: {"newarray", operandU1},
+	OpAnewarray: {"anewarray", operandConstU2},
+	OpArraylength: {"arraylength", operandNone},
+	OpAthrow: {"athrow", operandNone},
+	OpCheckcast: {"checkcast", operandConstU2},
+	OpInstanceof: {"instanceof", operandConstU2},
+	OpMonitorenter: {"monitorenter", operandNone},
+	OpMonitorexit: {"monitorexit", operandNone},
+	OpWide: {"wide", operandWide},
+	OpMultianewarray: {"multianewarray", operandMultiANewArray},
+	OpIfnull: {"ifnull", operandBranch2},
+	OpIfnonnull: {"ifnonnull", operandBranch2},
+	OpGotoW: {"goto_w", operandBranch4},
+	OpJsrW: {"jsr_w", operandBranch4},
+}
+
+func (op Opcode) String() string {
+	if info := opcodes[op]; info.mnemonic != "" {
+		return info.mnemonic
+	}
+	return fmt.Sprintf("opcode(0x%02x)", uint8(op))
+}
+
+// tableswitch 和 lookupswitch 的跳转表
+// tableswitch 的 Keys 为 Low 到 High 的连续整数
+type Switch struct {
+	Default int32
+	Low, High int32
+	Keys []int32
+	Offsets []int32
+}
+
+type Instruction struct {
+	PC int
+	Opcode Opcode
+	// 指令的总长度，包括 wide 前缀和 switch 的填充字节
+	Length int
+	// 带 wide 前缀
+	Wide bool
+	// 按字节码中出现顺序排列的操作数，有符号的操作数已做符号扩展
+	// 跳转指令的操作数为相对于 PC 的偏移
+	Operands []int32
+	// 操作数引用的常量池项
+	Const Constant
+	// tableswitch / lookupswitch 的跳转表
+	Switch *Switch
+}
+
+func (ins *Instruction) Mnemonic() string {
+	return ins.Opcode.String()
+}
+
+// 跳转指令的所有绝对目标地址
+func (ins *Instruction) Targets() []int {
+	switch opcodes[ins.Opcode].operand {
+	case operandBranch2, operandBranch4:
+		return []int{ins.PC + int(ins.Operands[0])}
+	case operandTableSwitch, operandLookupSwitch:
+		targets := []int{ins.PC + int(ins.Switch.Default)}
+		for _, offset := range ins.Switch.Offsets {
+			targets = append(targets, ins.PC + int(offset))
+		}
+		return targets
+	}
+	return nil
+}
+
+func (ins *Instruction) String() string {
+	s := fmt.Sprintf("%d: %s", ins.PC, ins.Opcode)
+	if ins.Wide {
+		s = fmt.Sprintf("%d: wide %s", ins.PC, ins.Opcode)
+	}
+	if ins.Switch != nil {
+		var pairs []string
+		for i, key := range ins.Switch.Keys {
+			pairs = append(pairs, fmt.Sprintf("%d: %d", key, ins.PC + int(ins.Switch.Offsets[i])))
+		}
+		pairs = append(pairs, fmt.Sprintf("default: %d", ins.PC + int(ins.Switch.Default)))
+		return fmt.Sprintf("%s { %s }", s, strings.Join(pairs, ", "))
+	}
+	for _, operand := range ins.Operands {
+		s += fmt.Sprintf(" %d", operand)
+	}
+	return s
+}
+
+var (
+	TruncatedCodeError = errors.New("truncated bytecode")
+)
+
+type codeReader struct {
+	code []byte
+	pc int
+}
+
+func (r *codeReader) u1() (uint8, error) {
+	if r.pc + 1 > len(r.code) {
+		return 0, TruncatedCodeError
+	}
+	v := r.code[r.pc]
+	r.pc++
+	return v, nil
+}
+
+func (r *codeReader) u2() (uint16, error) {
+	if r.pc + 2 > len(r.code) {
+		return 0, TruncatedCodeError
+	}
+	v := uint16(r.code[r.pc]) << 8 | uint16(r.code[r.pc + 1])
+	r.pc += 2
+	return v, nil
+}
+
+func (r *codeReader) s4() (int32, error) {
+	if r.pc + 4 > len(r.code) {
+		return 0, TruncatedCodeError
+	}
+	v := uint32(r.code[r.pc]) << 24 | uint32(r.code[r.pc + 1]) << 16 | uint32(r.code[r.pc + 2]) << 8 | uint32(r.code[r.pc + 3])
+	r.pc += 4
+	return int32(v), nil
+}
+
+// 将 AttrCode.Code 解码为指令序列，引用常量池的操作数会被解析为对应的常量
+func DecodeInstructions(code []byte, pool *ConstantPool) ([]*Instruction, error) {
+	r := &codeReader{code: code}
+	var instructions []*Instruction
+	for r.pc < len(code) {
+		ins, err := decodeInstruction(r, pool)
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, ins)
+	}
+	return instructions, nil
+}
+
+func decodeInstruction(r *codeReader, pool *ConstantPool) (*Instruction, error) {
+	ins := &Instruction{PC: r.pc}
+	op, _ := r.u1()
+	ins.Opcode = Opcode(op)
+	var err error
+	switch opcodes[op].operand {
+	case operandNone:
+		if opcodes[op].mnemonic == "" {
+			return nil, fmt.Errorf("illegal opcode 0x%02x at pc %d", op, ins.PC)
+		}
+	case operandS1:
+		var v uint8
+		if v, err = r.u1(); err == nil {
+			ins.Operands = []int32{int32(int8(v))}
+		}
+	case operandS2:
+		var v uint16
+		if v, err = r.u2(); err == nil {
+			ins.Operands = []int32{int32(int16(v))}
+		}
+	case operandU1, operandLocal:
+		var v uint8
+		if v, err = r.u1(); err == nil {
+			ins.Operands = []int32{int32(v)}
+		}
+	case operandConstU1:
+		var v uint8
+		if v, err = r.u1(); err == nil {
+			ins.Operands = []int32{int32(v)}
+			err = ins.resolve(uint16(v), pool)
+		}
+	case operandConstU2:
+		var v uint16
+		if v, err = r.u2(); err == nil {
+			ins.Operands = []int32{int32(v)}
+			err = ins.resolve(v, pool)
+		}
+	case operandIinc:
+		var index, v uint8
+		if index, err = r.u1(); err == nil {
+			if v, err = r.u1(); err == nil {
+				ins.Operands = []int32{int32(index), int32(int8(v))}
+			}
+		}
+	case operandBranch2:
+		var v uint16
+		if v, err = r.u2(); err == nil {
+			ins.Operands = []int32{int32(int16(v))}
+		}
+	case operandBranch4:
+		var v int32
+		if v, err = r.s4(); err == nil {
+			ins.Operands = []int32{v}
+		}
+	case operandTableSwitch:
+		err = ins.decodeTableSwitch(r)
+	case operandLookupSwitch:
+		err = ins.decodeLookupSwitch(r)
+	case operandInvokeInterface:
+		var index uint16
+		var count, zero uint8
+		if index, err = r.u2(); err == nil {
+			if count, err = r.u1(); err == nil {
+				if zero, err = r.u1(); err == nil {
+					ins.Operands = []int32{int32(index), int32(count), int32(zero)}
+					err = ins.resolve(index, pool)
+				}
+			}
+		}
+	case operandInvokeDynamic:
+		var index, zero uint16
+		if index, err = r.u2(); err == nil {
+			if zero, err = r.u2(); err == nil {
+				ins.Operands = []int32{int32(index), int32(zero >> 8), int32(zero & 0xff)}
+				err = ins.resolve(index, pool)
+			}
+		}
+	case operandMultiANewArray:
+		var index uint16
+		var dimensions uint8
+		if index, err = r.u2(); err == nil {
+			if dimensions, err = r.u1(); err == nil {
+				ins.Operands = []int32{int32(index), int32(dimensions)}
+				err = ins.resolve(index, pool)
+			}
+		}
+	case operandWide:
+		err = ins.decodeWide(r)
+	}
+	if err != nil {
+		if err == TruncatedCodeError {
+			return nil, fmt.Errorf("truncated %s at pc %d", ins.Opcode, ins.PC)
+		}
+		return nil, err
+	}
+	ins.Length = r.pc - ins.PC
+	return ins, nil
+}
+
+func (ins *Instruction) decodeWide(r *codeReader) error {
+	op, err := r.u1()
+	if err != nil {
+		return err
+	}
+	ins.Wide = true
+	ins.Opcode = Opcode(op)
+	switch opcodes[op].operand {
+	case operandLocal:
+		index, err := r.u2()
+		if err != nil {
+			return err
+		}
+		ins.Operands = []int32{int32(index)}
+	case operandIinc:
+		index, err := r.u2()
+		if err != nil {
+			return err
+		}
+		v, err := r.u2()
+		if err != nil {
+			return err
+		}
+		ins.Operands = []int32{int32(index), int32(int16(v))}
+	default:
+		return fmt.Errorf("illegal wide %s at pc %d", ins.Opcode, ins.PC)
+	}
+	return nil
+}
+
+// 跳过 switch 操作码之后的 0-3 个填充字节，使后续操作数4字节对齐
+func (r *codeReader) align() error {
+	for r.pc % 4 != 0 {
+		if _, err := r.u1(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ins *Instruction) decodeTableSwitch(r *codeReader) error {
+	if err := r.align(); err != nil {
+		return err
+	}
+	s := &Switch{}
+	var err error
+	if s.Default, err = r.s4(); err != nil {
+		return err
+	}
+	if s.Low, err = r.s4(); err != nil {
+		return err
+	}
+	if s.High, err = r.s4(); err != nil {
+		return err
+	}
+	if s.Low > s.High {
+		return fmt.Errorf("tableswitch low %d > high %d at pc %d", s.Low, s.High, ins.PC)
+	}
+	n := int64(s.High) - int64(s.Low) + 1
+	if n * 4 > int64(len(r.code) - r.pc) {
+		return TruncatedCodeError
+	}
+	for i := int64(0); i < n; i++ {
+		offset, err := r.s4()
+		if err != nil {
+			return err
+		}
+		s.Keys = append(s.Keys, int32(int64(s.Low) + i))
+		s.Offsets = append(s.Offsets, offset)
+	}
+	ins.Switch = s
+	return nil
+}
+
+func (ins *Instruction) decodeLookupSwitch(r *codeReader) error {
+	if err := r.align(); err != nil {
+		return err
+	}
+	s := &Switch{}
+	var err error
+	if s.Default, err = r.s4(); err != nil {
+		return err
+	}
+	npairs, err := r.s4()
+	if err != nil {
+		return err
+	}
+	if npairs < 0 {
+		return fmt.Errorf("lookupswitch npairs %d < 0 at pc %d", npairs, ins.PC)
+	}
+	if int64(npairs) * 8 > int64(len(r.code) - r.pc) {
+		return TruncatedCodeError
+	}
+	for i := 0; i < int(npairs); i++ {
+		key, err := r.s4()
+		if err != nil {
+			return err
+		}
+		offset, err := r.s4()
+		if err != nil {
+			return err
+		}
+		if i > 0 && key <= s.Keys[i - 1] {
+			return fmt.Errorf("lookupswitch keys not sorted at pc %d", ins.PC)
+		}
+		s.Keys = append(s.Keys, key)
+		s.Offsets = append(s.Offsets, offset)
+	}
+	if len(s.Keys) > 0 {
+		s.Low, s.High = s.Keys[0], s.Keys[len(s.Keys) - 1]
+	}
+	ins.Switch = s
+	return nil
+}
+
+// 解析操作数引用的常量，并检查常量类型是否符合指令要求
+func (ins *Instruction) resolve(index uint16, pool *ConstantPool) error {
+	constant, err := pool.Get(index)
+	if err != nil {
+		return fmt.Errorf("%s at pc %d: %s", ins.Opcode, ins.PC, err)
+	}
+	if constant == nil {
+		return fmt.Errorf("%s at pc %d: invalid constant index %d", ins.Opcode, ins.PC, index)
+	}
+	var ok bool
+	switch ins.Opcode {
+	case OpLdc, OpLdcW:
+		switch constant.(type) {
+		case *ConstInteger, *ConstFloat, *ConstString, *ConstClass, *ConstMethodType, *ConstMethodHandle, *ConstDynamic:
+			ok = true
+		}
+	case OpLdc2W:
+		switch constant.(type) {
+		case *ConstLong, *ConstDouble, *ConstDynamic:
+			ok = true
+		}
+	case OpGetstatic, OpPutstatic, OpGetfield, OpPutfield:
+		_, ok = constant.(*ConstFieldRef)
+	case OpInvokevirtual:
+		_, ok = constant.(*ConstMethodRef)
+	case OpInvokespecial, OpInvokestatic:
+		switch constant.(type) {
+		case *ConstMethodRef, *ConstInterfaceMethodRef:
+			ok = true
+		}
+	case OpInvokeinterface:
+		_, ok = constant.(*ConstInterfaceMethodRef)
+	case OpInvokedynamic:
+		_, ok = constant.(*ConstInvokeDynamic)
+	case OpNew, OpAnewarray, OpCheckcast, OpInstanceof, OpMultianewarray:
+		_, ok = constant.(*ConstClass)
+	}
+	if !ok {
+		return fmt.Errorf("%s at pc %d: unexpected constant #%d %s", ins.Opcode, ins.PC, index, constant)
+	}
+	ins.Const = constant
+	return nil
+}
@@ -0,0 +1,118 @@
+package class
+
+import (
+	"testing"
+	"bytes"
+)
+
+func TestDecodeInstructionsArrayList(t *testing.T) {
+	classFile, err := NewClassFile(bytes.NewReader(testByteCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range classFile.Methods {
+		for _, attr := range method.Attrs {
+			code, ok := attr.(*AttrCode)
+			if !ok {
+				continue
+			}
+			instructions, err := DecodeInstructions(code.Code, classFile.ConstantPool)
+			if err != nil {
+				t.Fatalf("%s: %s", method.Name, err)
+			}
+			pc := 0
+			for _, ins := range instructions {
+				if ins.PC != pc {
+					t.Fatalf("%s: unexpected pc %d, expect %d", method.Name, ins.PC, pc)
+				}
+				pc += ins.Length
+				for _, target := range ins.Targets() {
+					if target < 0 || target >= len(code.Code) {
+						t.Errorf("%s: %s jumps out of code", method.Name, ins)
+					}
+				}
+			}
+			if pc != len(code.Code) {
+				t.Errorf("%s: decoded %d bytes, expect %d", method.Name, pc, len(code.Code))
+			}
+		}
+	}
+}
+
+func TestDecodeInstructions(t *testing.T) {
+	pool := NewEmptyConstantPool()
+	imIndex, _ := pool.AddInterfaceMethodRef("java/util/List", "size", "()I")
+	indyIndex, _ := pool.AddInvokeDynamic(0, "run", "()Ljava/lang/Runnable;")
+	code := []byte{
+		byte(OpWide), byte(OpIinc), 0x01, 0x00, 0xff, 0xfe, // 0: wide iinc 256 -2
+		byte(OpWide), byte(OpAload), 0x01, 0x02, // 6: wide aload 258
+		byte(OpInvokeinterface), byte(imIndex >> 8), byte(imIndex), 1, 0, // 10
+		byte(OpInvokedynamic), byte(indyIndex >> 8), byte(indyIndex), 0, 0, // 15
+		byte(OpTableswitch), 0, 0, 0, // 20: 3 字节填充
+		0, 0, 0, 40, // default
+		0, 0, 0, 1, // low
+		0, 0, 0, 2, // high
+		0, 0, 0, 36,
+		0, 0, 0, 38,
+		byte(OpLookupswitch), // 44: 3 字节填充
+		0, 0, 0,
+		0, 0, 0, 16, // default
+		0, 0, 0, 1, // npairs
+		0xff, 0xff, 0xff, 0xff, 0, 0, 0, 16,
+		byte(OpBipush), 0x80, // 64
+		byte(OpGotoW), 0xff, 0xff, 0xff, 0xbe, // 66
+	}
+	instructions, err := DecodeInstructions(code, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instructions) != 8 {
+		t.Fatalf("decoded %d instructions: %v", len(instructions), instructions)
+	}
+	iinc := instructions[0]
+	if !iinc.Wide || iinc.Opcode != OpIinc || iinc.Length != 6 || iinc.Operands[0] != 256 || iinc.Operands[1] != -2 {
+		t.Errorf("unexpected %s", iinc)
+	}
+	if aload := instructions[1]; !aload.Wide || aload.Operands[0] != 258 || aload.PC != 6 {
+		t.Errorf("unexpected %s", aload)
+	}
+	invoke := instructions[2]
+	if ref, ok := invoke.Const.(*ConstInterfaceMethodRef); !ok || ref.NameAndType.Name.String() != "size" || invoke.Operands[1] != 1 {
+		t.Errorf("unexpected %s", invoke)
+	}
+	if indy, ok := instructions[3].Const.(*ConstInvokeDynamic); !ok || indy.NameAndType.Name.String() != "run" {
+		t.Errorf("unexpected %s", instructions[3])
+	}
+	tableswitch := instructions[4]
+	if tableswitch.Length != 24 || tableswitch.Switch.Low != 1 || tableswitch.Switch.High != 2 ||
+		len(tableswitch.Switch.Keys) != 2 || tableswitch.Switch.Offsets[1] != 38 {
+		t.Errorf("unexpected %s", tableswitch)
+	}
+	if targets := tableswitch.Targets(); len(targets) != 3 || targets[0] != 60 || targets[2] != 58 {
+		t.Errorf("unexpected targets %v", targets)
+	}
+	lookupswitch := instructions[5]
+	if lookupswitch.PC != 44 || lookupswitch.Length != 20 || lookupswitch.Switch.Keys[0] != -1 {
+		t.Errorf("unexpected %s", lookupswitch)
+	}
+	if bipush := instructions[6]; bipush.Operands[0] != -128 {
+		t.Errorf("unexpected %s", bipush)
+	}
+	if gotoW := instructions[7]; gotoW.PC != 66 || gotoW.Targets()[0] != 0 {
+		t.Errorf("unexpected %s", gotoW)
+	}
+
+	illegal := [][]byte{
+		{byte(OpSipush), 0},
+		{byte(OpWide), byte(OpIadd)},
+		{0xfe},
+		{byte(OpInvokevirtual), byte(imIndex >> 8), byte(imIndex)},
+		{byte(OpTableswitch), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 1},
+		{byte(OpLookupswitch), 0, 0, 0, 0, 0, 0, 0, 0x7f, 0xff, 0xff, 0xff},
+	}
+	for _, code := range illegal {
+		if _, err := DecodeInstructions(code, pool); err == nil {
+			t.Errorf("expected error for % x", code)
+		}
+	}
+}
@@ -0,0 +1,641 @@
+package class
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// JSON 对象，encoding/json 按键名排序输出，保证结果稳定
+type jsonObject map[string]interface{}
+
+// 按稳定的结构输出为 JSON，类名使用内部形式，常量内联展开
+// 描述符和签名中大量出现 <>，不做 HTML 转义，long 常量为十进制字符串
+func (classfile *ClassFile) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(classfile.jsonValue()); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func (classfile *ClassFile) jsonValue() jsonObject {
+	var superClass interface{}
+	if name := classfile.SuperName(); name != "" {
+		superClass = name
+	}
+	interfaces := make([]string, len(classfile.Interfaces))
+	for i, inter := range classfile.Interfaces {
+		interfaces[i] = className(inter)
+	}
+	fields := make([]jsonObject, len(classfile.Fields))
+	for i, field := range classfile.Fields {
+		fields[i] = jsonObject{
+			"access_flags": jsonFlags(uint16(field.AccessFlags), field.AccessFlags.Flags()),
+			"name": utf8String(field.Name),
+			"descriptor": utf8String(field.Descriptor),
+			"attributes": attrsJSON(field.Attrs, classfile.ConstantPool),
+		}
+	}
+	methods := make([]jsonObject, len(classfile.Methods))
+	for i, method := range classfile.Methods {
+		methods[i] = jsonObject{
+			"access_flags": jsonFlags(uint16(method.AccessFlags), method.AccessFlags.Flags()),
+			"name": utf8String(method.Name),
+			"descriptor": utf8String(method.Descriptor),
+			"attributes": attrsJSON(method.Attrs, classfile.ConstantPool),
+		}
+	}
+	return jsonObject{
+		"magic": fmt.Sprintf("%x", classfile.Magic),
+		"minor_version": classfile.Minor,
+		"major_version": classfile.Major,
+		"constant_pool": constantPoolJSON(classfile.ConstantPool),
+		"access_flags": jsonFlags(uint16(classfile.AccessFlags), classfile.AccessFlags.Flags()),
+		"this_class": classfile.Name(),
+		"super_class": superClass,
+		"interfaces": interfaces,
+		"fields": fields,
+		"methods": methods,
+		"attributes": attrsJSON(classfile.Attrs, classfile.ConstantPool),
+	}
+}
+
+func jsonFlags(value uint16, flags []string) jsonObject {
+	if flags == nil {
+		flags = []string{}
+	}
+	return jsonObject{"value": value, "flags": flags}
+}
+
+func className(c *ConstClass) string {
+	if c == nil {
+		return ""
+	}
+	return utf8String(c.Name)
+}
+
+// 可能为nil的类，nil 输出为 null
+func optionalClass(c *ConstClass) interface{} {
+	if c == nil {
+		return nil
+	}
+	return className(c)
+}
+
+func optionalUTF8(s *ConstUTF8) interface{} {
+	if s == nil {
+		return nil
+	}
+	return s.String()
+}
+
+func classNames(classes []*ConstClass) []string {
+	names := make([]string, len(classes))
+	for i, c := range classes {
+		names[i] = className(c)
+	}
+	return names
+}
+
+// NaN 和无穷大不能表示为 JSON 数字，按 Java 的写法输出为字符串
+func floatJSON(v float64) interface{} {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	return v
+}
+
+// long 输出为十进制字符串，jq 等工具按 float64 解析数字，超过 2^53 会丢失精度
+func longJSON(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func constantPoolJSON(pool *ConstantPool) []jsonObject {
+	constants := []jsonObject{}
+	if pool == nil {
+		return constants
+	}
+	for i := 1; i < pool.Length(); i++ {
+		if c := pool.pool[i]; c != nil {
+			obj := constantJSON(c)
+			obj["index"] = i
+			constants = append(constants, obj)
+		}
+	}
+	return constants
+}
+
+func constantJSON(c Constant) jsonObject {
+	if c == nil {
+		return nil
+	}
+	obj := jsonObject{"kind": ConstantKindName(c.Tag())}
+	switch c := c.(type) {
+	case *ConstUTF8:
+		obj["value"] = c.String()
+	case *ConstInteger:
+		obj["value"] = c.Val
+	case *ConstFloat:
+		obj["value"] = floatJSON(float64(c.Val))
+	case *ConstLong:
+		obj["value"] = longJSON(c.Val)
+	case *ConstDouble:
+		obj["value"] = floatJSON(c.Val)
+	case *ConstClass:
+		obj["name"] = utf8String(c.Name)
+	case *ConstString:
+		obj["value"] = utf8String(c.UTF8String)
+	case *ConstFieldRef:
+		refJSON(obj, c)
+	case *ConstMethodRef:
+		refJSON(obj, c)
+	case *ConstInterfaceMethodRef:
+		refJSON(obj, c)
+	case *ConstNameAndType:
+		obj["name"] = utf8String(c.Name)
+		obj["descriptor"] = utf8String(c.Descriptor)
+	case *ConstMethodHandle:
+		obj["reference_kind"] = c.RefKind
+		obj["reference_kind_name"] = RefKindName(c.RefKind)
+		if ref, ok := c.Ref.(Constant); ok {
+			obj["reference"] = constantJSON(ref)
+		}
+	case *ConstMethodType:
+		obj["descriptor"] = utf8String(c.Descriptor)
+	case *ConstDynamic:
+		obj["bootstrap_method_attr_index"] = c.BootstrapMethodAttrIndex
+		nameAndTypeJSON(obj, c.NameAndType)
+	case *ConstInvokeDynamic:
+		obj["bootstrap_method_attr_index"] = c.BootstrapMethodAttrIndex
+		nameAndTypeJSON(obj, c.NameAndType)
+	case *ConstModule:
+		obj["name"] = utf8String(c.Name)
+	case *ConstPackage:
+		obj["name"] = utf8String(c.Name)
+	}
+	return obj
+}
+
+func refJSON(obj jsonObject, ref ConstRef) {
+	obj["class"] = className(ref.GetClass())
+	nameAndTypeJSON(obj, ref.GetNameAndType())
+}
+
+func nameAndTypeJSON(obj jsonObject, nat *ConstNameAndType) {
+	if nat != nil {
+		obj["name"] = utf8String(nat.Name)
+		obj["descriptor"] = utf8String(nat.Descriptor)
+	}
+}
+
+func attrsJSON(attrs []Attr, pool *ConstantPool) []jsonObject {
+	objs := make([]jsonObject, 0, len(attrs))
+	for _, attr := range attrs {
+		objs = append(objs, attrJSON(attr, pool))
+	}
+	return objs
+}
+
+func attrJSON(attr Attr, pool *ConstantPool) jsonObject {
+	obj := jsonObject{"name": attr.Name()}
+	switch a := attr.(type) {
+	case *AttrConstantValue:
+		obj["value"] = constantJSON(a.Val)
+	case *AttrCode:
+		obj["max_stack"] = a.MaxStack
+		obj["max_locals"] = a.MaxLocals
+		obj["code"] = hex.EncodeToString(a.Code)
+		if instructions, err := DecodeInstructions(a.Code, pool); err == nil {
+			s := make([]string, len(instructions))
+			for i, ins := range instructions {
+				s[i] = ins.String()
+			}
+			obj["instructions"] = s
+		}
+		exceptionTable := make([]jsonObject, len(a.ExceptionTable))
+		for i, ex := range a.ExceptionTable {
+			exceptionTable[i] = jsonObject{
+				"start_pc": ex.StartPC,
+				"end_pc": ex.EndPC,
+				"handler_pc": ex.HandlerPC,
+				"catch_type": optionalClass(ex.CatchType),
+			}
+		}
+		obj["exception_table"] = exceptionTable
+		obj["attributes"] = attrsJSON(a.Attrs, pool)
+	case *AttrStackMapTable:
+		entries := make([]jsonObject, len(a.Entries))
+		for i, frame := range a.Entries {
+			entries[i] = frameJSON(frame)
+		}
+		obj["entries"] = entries
+	case *AttrExceptions:
+		obj["exceptions"] = classNames(a.ExceptionTable)
+	case *AttrInnerClasses:
+		classes := make([]jsonObject, len(a.Classes))
+		for i, c := range a.Classes {
+			classes[i] = jsonObject{
+				"inner_class": optionalClass(c.InnerClass),
+				"outer_class": optionalClass(c.OuterClass),
+				"inner_name": optionalUTF8(c.InnerName),
+				"access_flags": jsonFlags(uint16(c.InnerClassAccessFlags), c.InnerClassAccessFlags.Flags()),
+			}
+		}
+		obj["classes"] = classes
+	case *AttrEnclosingMethod:
+		obj["class"] = className(a.Class)
+		if a.Method != nil {
+			obj["method"] = jsonObject{"name": utf8String(a.Method.Name), "descriptor": utf8String(a.Method.Descriptor)}
+		} else {
+			obj["method"] = nil
+		}
+	case *AttrSignature:
+		obj["signature"] = utf8String(a.Signature)
+	case *AttrSourceFile:
+		obj["source_file"] = utf8String(a.SourceFile)
+	case *AttrSourceDebugExtension:
+		obj["debug_extension"] = string(a.DebugExtension)
+	case *AttrLineNumberTable:
+		table := make([]jsonObject, len(a.LineNumberTable))
+		for i, entry := range a.LineNumberTable {
+			table[i] = jsonObject{"start_pc": entry.StartPC, "line_number": entry.LineNumber}
+		}
+		obj["line_number_table"] = table
+	case *AttrLocalVariableTable:
+		table := make([]jsonObject, len(a.LocalVarTable))
+		for i, entry := range a.LocalVarTable {
+			table[i] = jsonObject{
+				"start_pc": entry.StartPC,
+				"length": entry.Length,
+				"name": utf8String(entry.Name),
+				"descriptor": utf8String(entry.Descriptor),
+				"index": entry.Index,
+			}
+		}
+		obj["local_variable_table"] = table
+	case *AttrLocalVariableTypeTable:
+		table := make([]jsonObject, len(a.LocalVarTypeTable))
+		for i, entry := range a.LocalVarTypeTable {
+			table[i] = jsonObject{
+				"start_pc": entry.StartPC,
+				"length": entry.Length,
+				"name": utf8String(entry.Name),
+				"signature": utf8String(entry.Signature),
+				"index": entry.Index,
+			}
+		}
+		obj["local_variable_type_table"] = table
+	case *AttrRuntimeVisibleAnnotations:
+		obj["annotations"] = annotationsJSON(a.Annotations)
+	case *AttrRuntimeInvisibleAnnotations:
+		obj["annotations"] = annotationsJSON(a.Annotations)
+	case *AttrRuntimeVisibleParameterAnnotations:
+		obj["parameter_annotations"] = parameterAnnotationsJSON(a.ParameterAnnotations)
+	case *AttrRuntimeInvisibleParameterAnnotations:
+		obj["parameter_annotations"] = parameterAnnotationsJSON(a.ParameterAnnotations)
+	case *AttrRuntimeVisibleTypeAnnotations:
+		obj["annotations"] = typeAnnotationsJSON(a.Annotations)
+	case *AttrRuntimeInvisibleTypeAnnotations:
+		obj["annotations"] = typeAnnotationsJSON(a.Annotations)
+	case *AttrAnnotationDefault:
+		obj["default_value"] = elementValJSON(a.DefaultVal)
+	case *AttrBootstrapMethods:
+		methods := make([]jsonObject, len(a.BootstrapMethods))
+		for i, bm := range a.BootstrapMethods {
+			args := make([]jsonObject, len(bm.BootstrapArguments))
+			for j, arg := range bm.BootstrapArguments {
+				args[j] = constantJSON(arg)
+			}
+			var ref jsonObject
+			if bm.BootstrapMethodRef != nil {
+				ref = constantJSON(bm.BootstrapMethodRef)
+			}
+			methods[i] = jsonObject{"method_ref": ref, "arguments": args}
+		}
+		obj["bootstrap_methods"] = methods
+	case *AttrMethodParameters:
+		params := make([]jsonObject, len(a.Parameters))
+		for i, p := range a.Parameters {
+			params[i] = jsonObject{
+				"name": optionalUTF8(p.Name),
+				"access_flags": jsonFlags(uint16(p.AccessFlags), p.AccessFlags.Flags()),
+			}
+		}
+		obj["parameters"] = params
+	case *AttrModule:
+		moduleJSON(obj, a)
+	case *AttrModulePackages:
+		packages := make([]string, len(a.Packages))
+		for i, pkg := range a.Packages {
+			packages[i] = utf8String(pkg.Name)
+		}
+		obj["packages"] = packages
+	case *AttrModuleMainClass:
+		obj["main_class"] = className(a.MainClass)
+	case *AttrNestHost:
+		obj["host_class"] = className(a.HostClass)
+	case *AttrNestMembers:
+		obj["classes"] = classNames(a.Classes)
+	case *AttrRecord:
+		components := make([]jsonObject, len(a.Components))
+		for i, rc := range a.Components {
+			components[i] = jsonObject{
+				"name": utf8String(rc.Name),
+				"descriptor": utf8String(rc.Descriptor),
+				"attributes": attrsJSON(rc.Attrs, pool),
+			}
+		}
+		obj["components"] = components
+	case *AttrPermittedSubclasses:
+		obj["classes"] = classNames(a.Classes)
+	case *AttrSynthetic, *AttrDeprecated:
+	case *AttrUnknown:
+		obj["info"] = hex.EncodeToString(a.Info)
+	case json.Marshaler:
+		// 通过 RegisterAttrDecoder 注册的属性可以自己实现 MarshalJSON
+		obj["value"] = a
+	default:
+		obj["value"] = attr.String()
+	}
+	return obj
+}
+
+var frameTypeNames = []struct {
+	max uint8
+	name string
+}{
+	{63, "same"},
+	{127, "same_locals_1_stack_item"},
+	{246, "reserved"},
+	{247, "same_locals_1_stack_item_extended"},
+	{250, "chop"},
+	{251, "same_frame_extended"},
+	{254, "append"},
+	{255, "full_frame"},
+}
+
+func frameJSON(frame StackMapFrame) jsonObject {
+	frameType := frame.FrameType()
+	obj := jsonObject{"frame_type": frameType}
+	for _, t := range frameTypeNames {
+		if frameType <= t.max {
+			obj["type"] = t.name
+			break
+		}
+	}
+	switch f := frame.(type) {
+	case *SameFrame:
+		obj["offset_delta"] = frameType
+	case *SameLocals1StackItemFrame:
+		obj["offset_delta"] = frameType - 64
+		obj["stack"] = verificationTypesJSON(f.Stack)
+	case *SameLocals1StackItemFrameExtended:
+		obj["offset_delta"] = f.OffsetDelta
+		obj["stack"] = verificationTypesJSON(f.Stack)
+	case *ChopFrame:
+		obj["offset_delta"] = f.OffsetDelta
+		obj["chopped"] = 251 - int(frameType)
+	case *SameFrameExtended:
+		obj["offset_delta"] = f.OffsetDelta
+	case *AppendFrame:
+		obj["offset_delta"] = f.OffsetDelta
+		obj["locals"] = verificationTypesJSON(f.Locals)
+	case *FullFrame:
+		obj["offset_delta"] = f.OffsetDelta
+		obj["locals"] = verificationTypesJSON(f.Locals)
+		obj["stack"] = verificationTypesJSON(f.Stack)
+	}
+	return obj
+}
+
+var verificationTypeNames = map[uint8]string{
+	ItemTop: "top",
+	ItemInteger: "integer",
+	ItemFloat: "float",
+	ItemDouble: "double",
+	ItemLong: "long",
+	ItemNull: "null",
+	ItemUninitializedThis: "uninitialized_this",
+	ItemObject: "object",
+	ItemUninitialized: "uninitialized",
+}
+
+func verificationTypesJSON(types []VerificationType) []jsonObject {
+	objs := make([]jsonObject, len(types))
+	for i, vt := range types {
+		obj := jsonObject{"type": verificationTypeNames[vt.Tag()]}
+		switch v := vt.(type) {
+		case *ObjectVariable:
+			obj["class"] = className(v.Class)
+		case *UninitializedVariable:
+			obj["offset"] = v.Offset
+		}
+		objs[i] = obj
+	}
+	return objs
+}
+
+func annotationsJSON(annotations []*Annotation) []jsonObject {
+	objs := make([]jsonObject, len(annotations))
+	for i, annotation := range annotations {
+		objs[i] = annotationJSON(annotation.Type, annotation.ElementValPairs)
+	}
+	return objs
+}
+
+func annotationJSON(typ *ConstUTF8, pairs []*ElementValPair) jsonObject {
+	elements := make([]jsonObject, len(pairs))
+	for i, pair := range pairs {
+		elements[i] = jsonObject{"name": utf8String(pair.ElementName), "value": elementValJSON(pair.Val)}
+	}
+	return jsonObject{"type": utf8String(typ), "elements": elements}
+}
+
+func parameterAnnotationsJSON(params []*ParameterAnnotation) [][]jsonObject {
+	objs := make([][]jsonObject, len(params))
+	for i, param := range params {
+		objs[i] = annotationsJSON(param.Annotations)
+	}
+	return objs
+}
+
+// 元素值按 tag 解码，boolean 输出为 true/false，char 输出为字符串
+func elementValJSON(val ElementVal) jsonObject {
+	if val == nil {
+		return nil
+	}
+	obj := jsonObject{"tag": string(rune(val.Tag()))}
+	switch v := val.(type) {
+	case *ElementValByte:
+		obj["value"] = int8(v.Val.Val)
+	case *ElementValChar:
+		obj["value"] = string(rune(uint16(v.Val.Val)))
+	case *ElementValShort:
+		obj["value"] = int16(v.Val.Val)
+	case *ElementValInt:
+		obj["value"] = v.Val.Val
+	case *ElementValBoolean:
+		obj["value"] = v.Val.Val != 0
+	case *ElementValLong:
+		obj["value"] = longJSON(v.Val.Val)
+	case *ElementValFloat:
+		obj["value"] = floatJSON(float64(v.Val.Val))
+	case *ElementValDouble:
+		obj["value"] = floatJSON(v.Val.Val)
+	case *ElementValString:
+		obj["value"] = utf8String(v.Val)
+	case *ElementValEnum:
+		obj["type"] = utf8String(v.TypeName)
+		obj["const_name"] = utf8String(v.ConstName)
+	case *ElementValClass:
+		obj["class_info"] = utf8String(v.ClassInfo)
+	case *ElementValAnnotation:
+		obj["annotation"] = annotationJSON(v.AnnotationVal.Type, v.AnnotationVal.ElementValPairs)
+	case *ElementValArray:
+		values := make([]jsonObject, len(v.Val))
+		for i, elem := range v.Val {
+			values[i] = elementValJSON(elem)
+		}
+		obj["values"] = values
+	}
+	return obj
+}
+
+var targetKindNames = map[uint8]string{
+	0x00: "class_type_parameter",
+	0x01: "method_type_parameter",
+	0x10: "supertype",
+	0x11: "class_type_parameter_bound",
+	0x12: "method_type_parameter_bound",
+	0x13: "field",
+	0x14: "method_return",
+	0x15: "method_receiver",
+	0x16: "method_formal_parameter",
+	0x17: "throws",
+	0x40: "local_variable",
+	0x41: "resource_variable",
+	0x42: "exception_parameter",
+	0x43: "instanceof",
+	0x44: "new",
+	0x45: "constructor_reference",
+	0x46: "method_reference",
+	0x47: "cast",
+	0x48: "constructor_invocation_type_argument",
+	0x49: "method_invocation_type_argument",
+	0x4A: "constructor_reference_type_argument",
+	0x4B: "method_reference_type_argument",
+}
+
+func typeAnnotationsJSON(annotations []*TypeAnnotation) []jsonObject {
+	objs := make([]jsonObject, len(annotations))
+	for i, ta := range annotations {
+		obj := annotationJSON(ta.Type, ta.ElementValPairs)
+		obj["target_type"] = ta.TargetType
+		obj["target"] = targetJSON(ta.TargetType, ta.Target)
+		path := []jsonObject{}
+		if ta.TargetPath != nil {
+			for _, p := range ta.TargetPath.Path {
+				path = append(path, jsonObject{"type_path_kind": p.TypePathKind, "type_argument_index": p.TypeArgumentIndex})
+			}
+		}
+		obj["type_path"] = path
+		objs[i] = obj
+	}
+	return objs
+}
+
+func targetJSON(targetType uint8, target Target) jsonObject {
+	obj := jsonObject{"kind": targetKindNames[targetType]}
+	switch t := target.(type) {
+	case *TypeParameterTarget:
+		obj["type_parameter_index"] = t.TypeParameterIndex
+	case *SupertypeTarget:
+		obj["supertype_index"] = t.SupertypeIndex
+	case *TypeParameterBoundTarget:
+		obj["type_parameter_index"] = t.TypeParameterIndex
+		obj["bound_index"] = t.BoundIndex
+	case *FormalParameterTarget:
+		obj["formal_parameter_index"] = t.FormalParameterIndex
+	case *ThrowsTarget:
+		obj["throws_type_index"] = t.ThrowsTypeIndex
+	case *LocalvarTarget:
+		table := make([]jsonObject, len(t.Table))
+		for i, entry := range t.Table {
+			table[i] = jsonObject{"start_pc": entry.StartPC, "length": entry.Length, "index": entry.Index}
+		}
+		obj["table"] = table
+	case *CatchTarget:
+		obj["exception_table_index"] = t.ExceptionTableIndex
+	case *OffsetTarget:
+		obj["offset"] = t.Offset
+	case *TypeArgumentTarget:
+		obj["offset"] = t.Offset
+		obj["type_argument_index"] = t.TypeArgumentIndex
+	}
+	return obj
+}
+
+func moduleJSON(obj jsonObject, m *AttrModule) {
+	obj["module_name"] = moduleName(m.ModuleName)
+	obj["module_flags"] = jsonFlags(uint16(m.ModuleFlags), m.ModuleFlags.Flags())
+	obj["module_version"] = optionalUTF8(m.ModuleVersion)
+	requires := make([]jsonObject, len(m.Requires))
+	for i, r := range m.Requires {
+		requires[i] = jsonObject{
+			"requires": moduleName(r.Requires),
+			"requires_flags": jsonFlags(uint16(r.RequiresFlags), r.RequiresFlags.Flags()),
+			"requires_version": optionalUTF8(r.RequiresVersion),
+		}
+	}
+	obj["requires"] = requires
+	exports := make([]jsonObject, len(m.Exports))
+	for i, e := range m.Exports {
+		exports[i] = exportsJSON(e)
+	}
+	obj["exports"] = exports
+	opens := make([]jsonObject, len(m.Opens))
+	for i, o := range m.Opens {
+		opens[i] = exportsJSON(o.ModuleExports)
+	}
+	obj["opens"] = opens
+	obj["uses"] = classNames(m.Uses)
+	provides := make([]jsonObject, len(m.Provides))
+	for i, p := range m.Provides {
+		provides[i] = jsonObject{"provides": className(p.Provides), "with": classNames(p.With)}
+	}
+	obj["provides"] = provides
+}
+
+func moduleName(m *ConstModule) string {
+	if m == nil {
+		return ""
+	}
+	return utf8String(m.Name)
+}
+
+func exportsJSON(e *ModuleExports) jsonObject {
+	to := make([]string, len(e.To))
+	for i, m := range e.To {
+		to[i] = moduleName(m)
+	}
+	var pkg string
+	if e.Package != nil {
+		pkg = utf8String(e.Package.Name)
+	}
+	return jsonObject{
+		"package": pkg,
+		"flags": jsonFlags(uint16(e.Flags), e.Flags.Flags()),
+		"to": to,
+	}
+}
@@ -0,0 +1,183 @@
+package class
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalJSONArrayList(t *testing.T) {
+	cf, err := NewClassFile(bytes.NewReader(testByteCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 输出必须稳定，两次序列化结果相同
+	if again, _ := json.Marshal(cf); !bytes.Equal(data, again) {
+		t.Error("MarshalJSON output is not stable")
+	}
+	var v struct {
+		Magic string
+		MajorVersion int `json:"major_version"`
+		ThisClass string `json:"this_class"`
+		SuperClass string `json:"super_class"`
+		Interfaces []string
+		AccessFlags struct {
+			Value int
+			Flags []string
+		} `json:"access_flags"`
+		ConstantPool []map[string]interface{} `json:"constant_pool"`
+		Fields []struct {
+			Name string
+			Attributes []map[string]interface{}
+		}
+		Methods []struct {
+			Name string
+			Descriptor string
+			Attributes []map[string]interface{}
+		}
+		Attributes []map[string]interface{}
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Magic != "cafebabe" || v.MajorVersion != 52 || v.ThisClass != "java/util/ArrayList" || v.SuperClass != "java/util/AbstractList" {
+		t.Errorf("unexpected header %v %v %v %v", v.Magic, v.MajorVersion, v.ThisClass, v.SuperClass)
+	}
+	if !reflect.DeepEqual(v.Interfaces, []string{"java/util/List", "java/util/RandomAccess", "java/lang/Cloneable", "java/io/Serializable"}) {
+		t.Errorf("unexpected interfaces %v", v.Interfaces)
+	}
+	if !reflect.DeepEqual(v.AccessFlags.Flags, []string{"ACC_PUBLIC", "ACC_SUPER"}) {
+		t.Errorf("unexpected access flags %v", v.AccessFlags.Flags)
+	}
+	if c := v.ConstantPool[2]; c["index"] != 3.0 || c["kind"] != "Methodref" || c["class"] != "java/util/AbstractList" || c["name"] != "<init>" {
+		t.Errorf("unexpected constant #3 %v", c)
+	}
+	for _, field := range v.Fields {
+		if field.Name == "serialVersionUID" {
+			value := field.Attributes[0]["value"].(map[string]interface{})
+			if value["kind"] != "Long" || value["value"] != "8683452581122892189" {
+				t.Errorf("unexpected ConstantValue %v", value)
+			}
+		}
+	}
+	found := false
+	for _, method := range v.Methods {
+		if method.Name != "writeObject" {
+			continue
+		}
+		for _, attr := range method.Attributes {
+			switch attr["name"] {
+			case "Code":
+				found = true
+				if _, ok := attr["instructions"].([]interface{}); !ok {
+					t.Errorf("missing instructions %v", attr)
+				}
+			case "Exceptions":
+				if !reflect.DeepEqual(attr["exceptions"], []interface{}{"java/io/IOException"}) {
+					t.Errorf("unexpected exceptions %v", attr)
+				}
+			}
+		}
+	}
+	if !found {
+		t.Error("missing writeObject Code")
+	}
+	sourceFile := ""
+	for _, attr := range v.Attributes {
+		if attr["name"] == "SourceFile" {
+			sourceFile, _ = attr["source_file"].(string)
+		}
+	}
+	if sourceFile != "ArrayList.java" {
+		t.Errorf("unexpected SourceFile %q", sourceFile)
+	}
+}
+
+func TestMarshalJSONAttrs(t *testing.T) {
+	pool := NewEmptyConstantPool()
+	utf8 := func(s string) *ConstUTF8 {
+		i, _ := pool.AddUTF8(s)
+		u, _ := pool.GetUTF8String(i)
+		return u
+	}
+	integer := func(v int32) *ConstInteger {
+		i, _ := pool.AddInteger(v)
+		c, _ := pool.GetInteger(i)
+		return c
+	}
+	classIndex, _ := pool.AddClass("java/lang/String")
+	stringClass, _ := pool.GetClass(classIndex)
+	annotation := &Annotation{Type: utf8("LAnno;"), ElementValPairs: []*ElementValPair{
+		{utf8("flag"), &ElementValBoolean{integer(1)}},
+		{utf8("c"), &ElementValChar{integer('x')}},
+		{utf8("e"), &ElementValEnum{utf8("LE;"), utf8("A")}},
+		{utf8("a"), &ElementValArray{[]ElementVal{&ElementValByte{integer(-1)}, &ElementValClass{utf8("Ljava/lang/String;")}}}},
+	}}
+	attrs := []Attr{
+		&AttrRuntimeVisibleAnnotations{[]*Annotation{annotation}},
+		&AttrRuntimeVisibleTypeAnnotations{[]*TypeAnnotation{{
+			TargetType: 0x40,
+			Target: &LocalvarTarget{[]*Table{{StartPC: 1, Length: 2, Index: 3}}},
+			TargetPath: &TypePath{[]*Path{{3, 0}}},
+			Type: utf8("LNonNull;"),
+		}}},
+		&AttrStackMapTable{[]StackMapFrame{
+			&SameFrame{frameType: 5},
+			&SameLocals1StackItemFrame{frameType: 70, Stack: []VerificationType{&IntegerVariable{}}},
+			&ChopFrame{frameType: 249, OffsetDelta: 7},
+			&FullFrame{frameType: 255, OffsetDelta: 9, Locals: []VerificationType{&ObjectVariable{stringClass}, &UninitializedVariable{4}}},
+		}},
+	}
+	data, err := json.Marshal(attrsJSON(attrs, pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"annotations":[{"elements":[{"name":"flag","value":{"tag":"Z","value":true}},` +
+		`{"name":"c","value":{"tag":"C","value":"x"}},` +
+		`{"name":"e","value":{"const_name":"A","tag":"e","type":"LE;"}},` +
+		`{"name":"a","value":{"tag":"[","values":[{"tag":"B","value":-1},{"class_info":"Ljava/lang/String;","tag":"c"}]}}],"type":"LAnno;"}],` +
+		`"name":"RuntimeVisibleAnnotations"},` +
+		`{"annotations":[{"elements":[],"target":{"kind":"local_variable","table":[{"index":3,"length":2,"start_pc":1}]},"target_type":64,` +
+		`"type":"LNonNull;","type_path":[{"type_argument_index":0,"type_path_kind":3}]}],"name":"RuntimeVisibleTypeAnnotations"},` +
+		`{"entries":[{"frame_type":5,"offset_delta":5,"type":"same"},` +
+		`{"frame_type":70,"offset_delta":6,"stack":[{"type":"integer"}],"type":"same_locals_1_stack_item"},` +
+		`{"chopped":2,"frame_type":249,"offset_delta":7,"type":"chop"},` +
+		`{"frame_type":255,"locals":[{"class":"java/lang/String","type":"object"},{"offset":4,"type":"uninitialized"}],"offset_delta":9,"stack":[],"type":"full_frame"}],` +
+		`"name":"StackMapTable"}]`
+	if string(data) != expected {
+		t.Errorf("unexpected json\n%s\nexpected\n%s", data, expected)
+	}
+}
+
+func TestMarshalJSONFloat(t *testing.T) {
+	for _, test := range []struct {
+		val float64
+		expected string
+	}{
+		{1.5, `{"kind":"Double","value":1.5}`},
+		{math.NaN(), `{"kind":"Double","value":"NaN"}`},
+		{math.Inf(-1), `{"kind":"Double","value":"-Infinity"}`},
+	} {
+		data, err := json.Marshal(constantJSON(&ConstDouble{Val: test.val}))
+		if err != nil || string(data) != test.expected {
+			t.Errorf("%v: got %s %v, expected %s", test.val, data, err, test.expected)
+		}
+	}
+}
+
+// long 超过 2^53 时按数字输出会被 jq 舍入
+func TestMarshalJSONLong(t *testing.T) {
+	long := &ConstLong{Val: 8683452581122892189}
+	for _, v := range []jsonObject{constantJSON(long), elementValJSON(&ElementValLong{long})} {
+		data, err := json.Marshal(v["value"])
+		if err != nil || string(data) != `"8683452581122892189"` {
+			t.Errorf("got %s %v", data, err)
+		}
+	}
+}
@@ -7,7 +7,7 @@ import (
 )
 
 type Method struct {
-	AccessFlags uint16
+	AccessFlags MethodAccessFlags
 	Name *ConstUTF8
 	Descriptor *ConstUTF8
 	Attrs []Attr
@@ -17,34 +17,65 @@ func (method *Method) String() string {
 	return fmt.Sprintf("Method -> %v", *method)
 }
 
+// 解析方法描述符
+func (method *Method) Type() (*MethodDescriptor, error) {
+	return ParseMethodDescriptor(utf8String(method.Descriptor))
+}
+
+// Code 属性，抽象方法、本地方法以及按 SkipCode 解析时返回nil
+func (method *Method) Code() *AttrCode {
+	for _, attr := range method.Attrs {
+		if code, ok := attr.(*AttrCode); ok {
+			return code
+		}
+	}
+	return nil
+}
+
+// Exceptions 属性中声明抛出的异常类
+func (method *Method) Exceptions() []*ConstClass {
+	for _, attr := range method.Attrs {
+		if exceptions, ok := attr.(*AttrExceptions); ok {
+			return exceptions.ExceptionTable
+		}
+	}
+	return nil
+}
+
 func NewMethod(io io.Reader, pool *ConstantPool) (*Method, error) {
+	return newMethod(io, pool, nil)
+}
+
+func newMethod(io io.Reader, pool *ConstantPool, skipAttr func(name string) bool) (*Method, error) {
 	method := &Method{}
 	if err := binary.Read(io, binary.BigEndian, &method.AccessFlags); err != nil {
-		return nil, err
+		return nil, formatError(io, err, "access_flags")
 	}
 	var nameIndex, descriptorIndex, attrCount uint16
 	if err := binary.Read(io, binary.BigEndian, &nameIndex); err != nil {
-		return nil, err
+		return nil, formatError(io, err, "name_index")
 	}
 	if err := binary.Read(io, binary.BigEndian, &descriptorIndex); err != nil {
-		return nil, err
+		return nil, formatError(io, err, "descriptor_index")
 	}
 	if err := binary.Read(io, binary.BigEndian, &attrCount); err != nil {
-		return nil, err
+		return nil, formatError(io, err, "attributes_count")
 	}
 	var err error
-	if method.Name, err = pool.GetUTF8String(nameIndex); err != nil {
-		return nil, err
+	if method.Name, err = pool.mustGetUTF8String(nameIndex); err != nil {
+		return nil, formatError(io, err, "name_index")
 	}
-	if method.Descriptor, err = pool.GetUTF8String(descriptorIndex); err != nil {
-		return nil, err
+	if method.Descriptor, err = pool.mustGetUTF8String(descriptorIndex); err != nil {
+		return nil, formatError(io, err, "descriptor_index")
 	}
 	for i := 0; i < int(attrCount); i++ {
-		attr, err := ReadAttr(io, pool)
+		attr, err := readAttr(io, pool, skipAttr)
 		if err != nil {
-			return nil, err
+			return nil, formatError(io, err, "attributes[%d]", i)
+		}
+		if attr != nil {
+			method.Attrs = append(method.Attrs, attr)
 		}
-		method.Attrs = append(method.Attrs, attr)
 	}
 	return method, nil
 }
\ No newline at end of file
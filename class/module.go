@@ -0,0 +1,297 @@
+package class
+
+import (
+	"io"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	ModuleAccOpen = 0x0020
+	ModuleAccTransitive = 0x0020
+	ModuleAccStaticPhase = 0x0040
+	ModuleAccSynthetic = 0x1000
+	ModuleAccMandated = 0x8000
+)
+
+type ModuleRequires struct {
+	Requires *ConstModule
+	RequiresFlags RequiresAccessFlags
+	// 可能为nil
+	RequiresVersion *ConstUTF8
+}
+
+func NewModuleRequires(io io.Reader, pool *ConstantPool) (*ModuleRequires, error) {
+	var requiresIndex, requiresVersionIndex uint16
+	mr := &ModuleRequires{}
+	if err := binary.Read(io, binary.BigEndian, &requiresIndex); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(io, binary.BigEndian, &mr.RequiresFlags); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(io, binary.BigEndian, &requiresVersionIndex); err != nil {
+		return nil, err
+	}
+	var err error
+	if mr.Requires, err = pool.GetModule(requiresIndex); err != nil {
+		return nil, err
+	}
+	if mr.RequiresVersion, err = pool.GetUTF8String(requiresVersionIndex); err != nil {
+		return nil, err
+	}
+	return mr, nil
+}
+
+func (mr *ModuleRequires) String() string {
+	return fmt.Sprintf("ModuleRequires -> %v", *mr)
+}
+
+// exports 和 opens 的结构完全相同
+type ModuleExports struct {
+	Package *ConstPackage
+	Flags ModuleAccessFlags
+	To []*ConstModule
+}
+
+func NewModuleExports(io io.Reader, pool *ConstantPool) (*ModuleExports, error) {
+	var packageIndex, toCount, toIndex uint16
+	me := &ModuleExports{}
+	if err := binary.Read(io, binary.BigEndian, &packageIndex); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(io, binary.BigEndian, &me.Flags); err != nil {
+		return nil, err
+	}
+	var err error
+	if me.Package, err = pool.GetPackage(packageIndex); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(io, binary.BigEndian, &toCount); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(toCount); i++ {
+		if err := binary.Read(io, binary.BigEndian, &toIndex); err != nil {
+			return nil, formatError(io, err, "exports_to_index[%d]", i)
+		}
+		module, err := pool.GetModule(toIndex)
+		if err != nil {
+			return nil, formatError(io, err, "exports_to_index[%d]", i)
+		}
+		me.To = append(me.To, module)
+	}
+	return me, nil
+}
+
+func (me *ModuleExports) String() string {
+	return fmt.Sprintf("ModuleExports -> %v", *me)
+}
+
+type ModuleOpens struct {
+	*ModuleExports
+}
+
+func NewModuleOpens(io io.Reader, pool *ConstantPool) (*ModuleOpens, error) {
+	exports, err := NewModuleExports(io, pool)
+	if err != nil {
+		return nil, err
+	}
+	return &ModuleOpens{ModuleExports: exports}, nil
+}
+
+func (mo *ModuleOpens) String() string {
+	return fmt.Sprintf("ModuleOpens -> %v", *mo.ModuleExports)
+}
+
+type ModuleProvides struct {
+	Provides *ConstClass
+	With []*ConstClass
+}
+
+func NewModuleProvides(io io.Reader, pool *ConstantPool) (*ModuleProvides, error) {
+	var providesIndex, withCount, withIndex uint16
+	if err := binary.Read(io, binary.BigEndian, &providesIndex); err != nil {
+		return nil, err
+	}
+	mp := &ModuleProvides{}
+	var err error
+	if mp.Provides, err = pool.GetClass(providesIndex); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(io, binary.BigEndian, &withCount); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(withCount); i++ {
+		if err := binary.Read(io, binary.BigEndian, &withIndex); err != nil {
+			return nil, formatError(io, err, "provides_with_index[%d]", i)
+		}
+		class, err := pool.GetClass(withIndex)
+		if err != nil {
+			return nil, formatError(io, err, "provides_with_index[%d]", i)
+		}
+		mp.With = append(mp.With, class)
+	}
+	return mp, nil
+}
+
+func (mp *ModuleProvides) String() string {
+	return fmt.Sprintf("ModuleProvides -> %v", *mp)
+}
+
+type AttrModule struct {
+	ModuleName *ConstModule
+	ModuleFlags ModuleAccessFlags
+	// 可能为nil
+	ModuleVersion *ConstUTF8
+	Requires []*ModuleRequires
+	Exports []*ModuleExports
+	Opens []*ModuleOpens
+	Uses []*ConstClass
+	Provides []*ModuleProvides
+}
+
+func NewAttrModule(io io.Reader, pool *ConstantPool) (*AttrModule, error) {
+	var moduleNameIndex, moduleVersionIndex uint16
+	m := &AttrModule{}
+	if err := binary.Read(io, binary.BigEndian, &moduleNameIndex); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(io, binary.BigEndian, &m.ModuleFlags); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(io, binary.BigEndian, &moduleVersionIndex); err != nil {
+		return nil, err
+	}
+	var err error
+	if m.ModuleName, err = pool.GetModule(moduleNameIndex); err != nil {
+		return nil, err
+	}
+	if m.ModuleVersion, err = pool.GetUTF8String(moduleVersionIndex); err != nil {
+		return nil, err
+	}
+
+	var count uint16
+	if err := binary.Read(io, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(count); i++ {
+		requires, err := NewModuleRequires(io, pool)
+		if err != nil {
+			return nil, formatError(io, err, "requires[%d]", i)
+		}
+		m.Requires = append(m.Requires, requires)
+	}
+
+	if err := binary.Read(io, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(count); i++ {
+		exports, err := NewModuleExports(io, pool)
+		if err != nil {
+			return nil, formatError(io, err, "exports[%d]", i)
+		}
+		m.Exports = append(m.Exports, exports)
+	}
+
+	if err := binary.Read(io, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(count); i++ {
+		opens, err := NewModuleOpens(io, pool)
+		if err != nil {
+			return nil, formatError(io, err, "opens[%d]", i)
+		}
+		m.Opens = append(m.Opens, opens)
+	}
+
+	if err := binary.Read(io, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	var usesIndex uint16
+	for i := 0; i < int(count); i++ {
+		if err := binary.Read(io, binary.BigEndian, &usesIndex); err != nil {
+			return nil, formatError(io, err, "uses_index[%d]", i)
+		}
+		class, err := pool.GetClass(usesIndex)
+		if err != nil {
+			return nil, formatError(io, err, "uses_index[%d]", i)
+		}
+		m.Uses = append(m.Uses, class)
+	}
+
+	if err := binary.Read(io, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(count); i++ {
+		provides, err := NewModuleProvides(io, pool)
+		if err != nil {
+			return nil, formatError(io, err, "provides[%d]", i)
+		}
+		m.Provides = append(m.Provides, provides)
+	}
+	return m, nil
+}
+
+func (c *AttrModule) Name() string {
+	return ModuleAttr
+}
+
+func (c *AttrModule) String() string {
+	return fmt.Sprintf("AttrModule ->  %v", *c)
+}
+
+type AttrModulePackages struct {
+	Packages []*ConstPackage
+}
+
+func NewAttrModulePackages(io io.Reader, pool *ConstantPool) (*AttrModulePackages, error) {
+	var packageCount, packageIndex uint16
+	if err := binary.Read(io, binary.BigEndian, &packageCount); err != nil {
+		return nil, err
+	}
+	mp := &AttrModulePackages{}
+	for i := 0; i < int(packageCount); i++ {
+		if err := binary.Read(io, binary.BigEndian, &packageIndex); err != nil {
+			return nil, formatError(io, err, "package_index[%d]", i)
+		}
+		pkg, err := pool.GetPackage(packageIndex)
+		if err != nil {
+			return nil, formatError(io, err, "package_index[%d]", i)
+		}
+		mp.Packages = append(mp.Packages, pkg)
+	}
+	return mp, nil
+}
+
+func (c *AttrModulePackages) Name() string {
+	return ModulePackages
+}
+
+func (c *AttrModulePackages) String() string {
+	return fmt.Sprintf("AttrModulePackages ->  %v", *c)
+}
+
+type AttrModuleMainClass struct {
+	MainClass *ConstClass
+}
+
+func NewAttrModuleMainClass(io io.Reader, pool *ConstantPool) (*AttrModuleMainClass, error) {
+	var mainClassIndex uint16
+	if err := binary.Read(io, binary.BigEndian, &mainClassIndex); err != nil {
+		return nil, err
+	}
+	mmc := &AttrModuleMainClass{}
+	var err error
+	if mmc.MainClass, err = pool.GetClass(mainClassIndex); err != nil {
+		return nil, err
+	}
+	return mmc, nil
+}
+
+func (c *AttrModuleMainClass) Name() string {
+	return ModuleMainClass
+}
+
+func (c *AttrModuleMainClass) String() string {
+	return fmt.Sprintf("AttrModuleMainClass ->  %v", *c)
+}
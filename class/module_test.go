@@ -0,0 +1,91 @@
+package class
+
+import (
+	"testing"
+)
+
+func TestReadAttrModule(t *testing.T) {
+	buf := &testWriter{}
+	buf.write(uint16(13))
+	buf.writeUTF8("com.example")       // #1
+	buf.write(uint8(Module))           // #2
+	buf.write(uint16(1))
+	buf.writeUTF8("java.base")         // #3
+	buf.write(uint8(Module))           // #4
+	buf.write(uint16(3))
+	buf.writeUTF8("com/example/api")   // #5
+	buf.write(uint8(Package))          // #6
+	buf.write(uint16(5))
+	buf.writeUTF8("com/example/Main")  // #7
+	buf.write(uint8(Class))            // #8
+	buf.write(uint16(7))
+	buf.writeUTF8(ModuleAttr)          // #9
+	buf.writeUTF8(ModulePackages)      // #10
+	buf.writeUTF8(ModuleMainClass)     // #11
+	buf.writeUTF8("1.0")               // #12
+	pool, err := NewConstantPool(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attr := &testWriter{}
+	attr.write(uint16(9))
+	attr.write(uint32(40))
+	attr.write([]uint16{
+		2, ModuleAccOpen, 12,
+		// requires
+		1, 4, ModuleAccMandated, 0,
+		// exports
+		1, 6, 0, 1, 4,
+		// opens
+		0,
+		// uses
+		1, 8,
+		// provides
+		1, 8, 2, 8, 8,
+	})
+	attr.write(uint16(10))
+	attr.write(uint32(4))
+	attr.write([]uint16{1, 6})
+	attr.write(uint16(11))
+	attr.write(uint32(2))
+	attr.write(uint16(8))
+
+	a, err := ReadAttr(attr, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, ok := a.(*AttrModule)
+	if !ok {
+		t.Fatalf("unexpected attr %v", a)
+	}
+	if module.ModuleName.Name.String() != "com.example" || module.ModuleVersion.String() != "1.0" ||
+		module.ModuleFlags != ModuleAccOpen {
+		t.Errorf("module header %v", module)
+	}
+	if len(module.Requires) != 1 || module.Requires[0].Requires.Name.String() != "java.base" ||
+		module.Requires[0].RequiresVersion != nil {
+		t.Errorf("module requires %v", module.Requires)
+	}
+	if len(module.Exports) != 1 || module.Exports[0].Package.Name.String() != "com/example/api" ||
+		len(module.Exports[0].To) != 1 {
+		t.Errorf("module exports %v", module.Exports)
+	}
+	if len(module.Opens) != 0 || len(module.Uses) != 1 || len(module.Provides) != 1 ||
+		len(module.Provides[0].With) != 2 {
+		t.Errorf("module body %v", module)
+	}
+
+	if a, err = ReadAttr(attr, pool); err != nil {
+		t.Fatal(err)
+	}
+	if packages, ok := a.(*AttrModulePackages); !ok || len(packages.Packages) != 1 {
+		t.Errorf("unexpected attr %v", a)
+	}
+	if a, err = ReadAttr(attr, pool); err != nil {
+		t.Fatal(err)
+	}
+	if mainClass, ok := a.(*AttrModuleMainClass); !ok || mainClass.MainClass.Name.String() != "com/example/Main" {
+		t.Errorf("unexpected attr %v", a)
+	}
+}
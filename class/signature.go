@@ -0,0 +1,546 @@
+package class
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 泛型签名解析 (JVMS 4.7.9.1)
+
+// 类型实参的通配符
+const (
+	WildcardNone = 0
+	WildcardExtends = '+'
+	WildcardSuper = '-'
+	WildcardAny = '*'
+)
+
+type SignatureError struct {
+	Signature string
+	// 出错的位置
+	Pos int
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("invalid signature %q at %d: %s", e.Signature, e.Pos, e.Reason)
+}
+
+// 签名中的类型
+type TypeSignature interface {
+	// Java 源码形式，类名使用全限定名
+	JavaName() string
+	// Java 源码形式，省略包名
+	SimpleName() string
+	javaName(qualified bool) string
+}
+
+// 基本类型，包括作为返回值的 void
+type BaseTypeSignature struct {
+	Kind byte
+}
+
+func (t *BaseTypeSignature) JavaName() string {
+	return t.javaName(true)
+}
+
+func (t *BaseTypeSignature) SimpleName() string {
+	return t.javaName(false)
+}
+
+func (t *BaseTypeSignature) javaName(qualified bool) string {
+	return baseTypeNames[t.Kind]
+}
+
+// 例如 java/util/Map<TK;TV;>.Entry 中的 Map<TK;TV;> 和 Entry
+type SimpleClassTypeSignature struct {
+	Name string
+	TypeArgs []*TypeArgument
+}
+
+type TypeArgument struct {
+	// WildcardXxx 之一
+	Wildcard byte
+	// WildcardAny 时为nil
+	Type TypeSignature
+}
+
+func (a *TypeArgument) javaName(qualified bool) string {
+	switch a.Wildcard {
+	case WildcardAny:
+		return "?"
+	case WildcardExtends:
+		return "? extends " + a.Type.javaName(qualified)
+	case WildcardSuper:
+		return "? super " + a.Type.javaName(qualified)
+	}
+	return a.Type.javaName(qualified)
+}
+
+type ClassTypeSignature struct {
+	// 内部形式的包名，例如 java/util
+	Package string
+	// 外部类在前，内部类在后
+	Classes []*SimpleClassTypeSignature
+}
+
+// 内部形式的类名（擦除后的类型），例如 java/util/Map$Entry
+func (t *ClassTypeSignature) ClassName() string {
+	names := make([]string, len(t.Classes))
+	for i, c := range t.Classes {
+		names[i] = c.Name
+	}
+	name := strings.Join(names, "$")
+	if t.Package != "" {
+		name = t.Package + "/" + name
+	}
+	return name
+}
+
+func (t *ClassTypeSignature) JavaName() string {
+	return t.javaName(true)
+}
+
+func (t *ClassTypeSignature) SimpleName() string {
+	return t.javaName(false)
+}
+
+func (t *ClassTypeSignature) javaName(qualified bool) string {
+	var s string
+	if qualified && t.Package != "" {
+		s = strings.Replace(t.Package, "/", ".", -1) + "."
+	}
+	for i, c := range t.Classes {
+		if i > 0 {
+			s += "."
+		}
+		s += c.Name
+		if len(c.TypeArgs) > 0 {
+			args := make([]string, len(c.TypeArgs))
+			for j, arg := range c.TypeArgs {
+				args[j] = arg.javaName(qualified)
+			}
+			s += "<" + strings.Join(args, ", ") + ">"
+		}
+	}
+	return s
+}
+
+type TypeVariableSignature struct {
+	Name string
+}
+
+func (t *TypeVariableSignature) JavaName() string {
+	return t.Name
+}
+
+func (t *TypeVariableSignature) SimpleName() string {
+	return t.Name
+}
+
+func (t *TypeVariableSignature) javaName(qualified bool) string {
+	return t.Name
+}
+
+type ArrayTypeSignature struct {
+	Elem TypeSignature
+}
+
+func (t *ArrayTypeSignature) JavaName() string {
+	return t.javaName(true)
+}
+
+func (t *ArrayTypeSignature) SimpleName() string {
+	return t.javaName(false)
+}
+
+func (t *ArrayTypeSignature) javaName(qualified bool) string {
+	return t.Elem.javaName(qualified) + "[]"
+}
+
+type TypeParameter struct {
+	Name string
+	// 没有类上界时为nil
+	ClassBound TypeSignature
+	InterfaceBounds []TypeSignature
+}
+
+func (p *TypeParameter) javaName(qualified bool) string {
+	var bounds []string
+	if c, ok := p.ClassBound.(*ClassTypeSignature); p.ClassBound != nil && !(ok && c.ClassName() == "java/lang/Object") {
+		bounds = append(bounds, p.ClassBound.javaName(qualified))
+	}
+	for _, bound := range p.InterfaceBounds {
+		bounds = append(bounds, bound.javaName(qualified))
+	}
+	if len(bounds) == 0 {
+		return p.Name
+	}
+	return p.Name + " extends " + strings.Join(bounds, " & ")
+}
+
+type TypeParameters []*TypeParameter
+
+// 例如 <K, V extends java.lang.Comparable<? super V>>，没有类型参数时为空字符串
+func (params TypeParameters) JavaName() string {
+	return params.javaName(true)
+}
+
+func (params TypeParameters) SimpleName() string {
+	return params.javaName(false)
+}
+
+func (params TypeParameters) javaName(qualified bool) string {
+	if len(params) == 0 {
+		return ""
+	}
+	s := make([]string, len(params))
+	for i, param := range params {
+		s[i] = param.javaName(qualified)
+	}
+	return "<" + strings.Join(s, ", ") + ">"
+}
+
+type ClassSignature struct {
+	TypeParams TypeParameters
+	Super *ClassTypeSignature
+	Interfaces []*ClassTypeSignature
+}
+
+// 例如 <E> extends java.util.AbstractList<E> implements java.util.List<E>
+func (c *ClassSignature) JavaName() string {
+	return c.javaName(true)
+}
+
+func (c *ClassSignature) SimpleName() string {
+	return c.javaName(false)
+}
+
+func (c *ClassSignature) javaName(qualified bool) string {
+	s := c.TypeParams.javaName(qualified)
+	if s != "" {
+		s += " "
+	}
+	s += "extends " + c.Super.javaName(qualified)
+	if len(c.Interfaces) > 0 {
+		interfaces := make([]string, len(c.Interfaces))
+		for i, inter := range c.Interfaces {
+			interfaces[i] = inter.javaName(qualified)
+		}
+		s += " implements " + strings.Join(interfaces, ", ")
+	}
+	return s
+}
+
+type MethodSignature struct {
+	TypeParams TypeParameters
+	Params []TypeSignature
+	// void 为 Kind 是 TypeVoid 的 BaseTypeSignature
+	Return TypeSignature
+	Throws []TypeSignature
+}
+
+// 例如 <T> T[] (T[]) throws java.io.IOException
+func (m *MethodSignature) JavaName() string {
+	return m.javaName(true)
+}
+
+func (m *MethodSignature) SimpleName() string {
+	return m.javaName(false)
+}
+
+func (m *MethodSignature) javaName(qualified bool) string {
+	s := m.TypeParams.javaName(qualified)
+	if s != "" {
+		s += " "
+	}
+	params := make([]string, len(m.Params))
+	for i, param := range m.Params {
+		params[i] = param.javaName(qualified)
+	}
+	s += m.Return.javaName(qualified) + " (" + strings.Join(params, ", ") + ")"
+	if len(m.Throws) > 0 {
+		throws := make([]string, len(m.Throws))
+		for i, t := range m.Throws {
+			throws[i] = t.javaName(qualified)
+		}
+		s += " throws " + strings.Join(throws, ", ")
+	}
+	return s
+}
+
+type signatureParser struct {
+	sig string
+	pos int
+}
+
+func (p *signatureParser) fail(reason string) error {
+	return &SignatureError{Signature: p.sig, Pos: p.pos, Reason: reason}
+}
+
+func (p *signatureParser) peek() byte {
+	if p.pos < len(p.sig) {
+		return p.sig[p.pos]
+	}
+	return 0
+}
+
+func (p *signatureParser) expect(c byte) error {
+	if p.peek() != c {
+		if p.pos >= len(p.sig) {
+			return p.fail("unexpected end of signature")
+		}
+		return p.fail(fmt.Sprintf("expected %q", c))
+	}
+	p.pos++
+	return nil
+}
+
+func (p *signatureParser) identifier() (string, error) {
+	start := p.pos
+	for p.pos < len(p.sig) && !strings.ContainsRune(".;[/<>:", rune(p.sig[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.fail("expected identifier")
+	}
+	return p.sig[start:p.pos], nil
+}
+
+func (p *signatureParser) typeParameters() (TypeParameters, error) {
+	if p.peek() != '<' {
+		return nil, nil
+	}
+	p.pos++
+	var params TypeParameters
+	for {
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		param := &TypeParameter{Name: name}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		if c := p.peek(); c == TypeObject || c == TypeArray || c == 'T' {
+			if param.ClassBound, err = p.referenceType(); err != nil {
+				return nil, err
+			}
+		}
+		for p.peek() == ':' {
+			p.pos++
+			bound, err := p.referenceType()
+			if err != nil {
+				return nil, err
+			}
+			param.InterfaceBounds = append(param.InterfaceBounds, bound)
+		}
+		params = append(params, param)
+		if p.peek() == '>' {
+			p.pos++
+			return params, nil
+		}
+	}
+}
+
+func (p *signatureParser) simpleClassType() (*SimpleClassTypeSignature, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	c := &SimpleClassTypeSignature{Name: name}
+	if p.peek() != '<' {
+		return c, nil
+	}
+	p.pos++
+	for {
+		arg := &TypeArgument{}
+		switch p.peek() {
+		case WildcardAny:
+			p.pos++
+			arg.Wildcard = WildcardAny
+		case WildcardExtends, WildcardSuper:
+			arg.Wildcard = p.peek()
+			p.pos++
+			fallthrough
+		default:
+			if arg.Type, err = p.referenceType(); err != nil {
+				return nil, err
+			}
+		}
+		c.TypeArgs = append(c.TypeArgs, arg)
+		if p.peek() == '>' {
+			p.pos++
+			return c, nil
+		}
+	}
+}
+
+func (p *signatureParser) classType() (*ClassTypeSignature, error) {
+	if err := p.expect(TypeObject); err != nil {
+		return nil, err
+	}
+	t := &ClassTypeSignature{}
+	var packages []string
+	for {
+		start := p.pos
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != '/' {
+			p.pos = start
+			break
+		}
+		p.pos++
+		packages = append(packages, name)
+	}
+	t.Package = strings.Join(packages, "/")
+	for {
+		c, err := p.simpleClassType()
+		if err != nil {
+			return nil, err
+		}
+		t.Classes = append(t.Classes, c)
+		if p.peek() != '.' {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect(';'); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (p *signatureParser) referenceType() (TypeSignature, error) {
+	switch p.peek() {
+	case TypeObject:
+		return p.classType()
+	case 'T':
+		p.pos++
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(';'); err != nil {
+			return nil, err
+		}
+		return &TypeVariableSignature{Name: name}, nil
+	case TypeArray:
+		start := p.pos
+		for p.peek() == TypeArray {
+			p.pos++
+		}
+		dims := p.pos - start
+		if dims > MaxArrayDimensions {
+			p.pos = start
+			return nil, p.fail("too many array dimensions")
+		}
+		t, err := p.javaType()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < dims; i++ {
+			t = &ArrayTypeSignature{Elem: t}
+		}
+		return t, nil
+	case 0:
+		return nil, p.fail("unexpected end of signature")
+	}
+	return nil, p.fail(fmt.Sprintf("expected reference type, got %q", p.peek()))
+}
+
+func (p *signatureParser) javaType() (TypeSignature, error) {
+	c := p.peek()
+	if _, ok := baseTypeNames[c]; ok && c != TypeVoid {
+		p.pos++
+		return &BaseTypeSignature{Kind: c}, nil
+	}
+	return p.referenceType()
+}
+
+func (p *signatureParser) end() error {
+	if p.pos != len(p.sig) {
+		return p.fail("trailing characters")
+	}
+	return nil
+}
+
+// 解析类签名，例如 <E:Ljava/lang/Object;>Ljava/util/AbstractList<TE;>;Ljava/util/List<TE;>;
+func ParseClassSignature(signature string) (*ClassSignature, error) {
+	p := &signatureParser{sig: signature}
+	c := &ClassSignature{}
+	var err error
+	if c.TypeParams, err = p.typeParameters(); err != nil {
+		return nil, err
+	}
+	if c.Super, err = p.classType(); err != nil {
+		return nil, err
+	}
+	for p.pos < len(signature) {
+		inter, err := p.classType()
+		if err != nil {
+			return nil, err
+		}
+		c.Interfaces = append(c.Interfaces, inter)
+	}
+	return c, nil
+}
+
+// 解析方法签名，例如 <T:Ljava/lang/Object;>([TT;)[TT;
+func ParseMethodSignature(signature string) (*MethodSignature, error) {
+	p := &signatureParser{sig: signature}
+	m := &MethodSignature{}
+	var err error
+	if m.TypeParams, err = p.typeParameters(); err != nil {
+		return nil, err
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	for p.pos < len(signature) && p.peek() != ')' {
+		param, err := p.javaType()
+		if err != nil {
+			return nil, err
+		}
+		m.Params = append(m.Params, param)
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	if p.peek() == TypeVoid {
+		p.pos++
+		m.Return = &BaseTypeSignature{Kind: TypeVoid}
+	} else if m.Return, err = p.javaType(); err != nil {
+		return nil, err
+	}
+	for p.peek() == '^' {
+		p.pos++
+		throws, err := p.referenceType()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := throws.(*ArrayTypeSignature); ok {
+			return nil, p.fail("throws type must be a class or type variable")
+		}
+		m.Throws = append(m.Throws, throws)
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// 解析字段签名，例如 Ljava/util/List<Ljava/lang/String;>;
+func ParseFieldSignature(signature string) (TypeSignature, error) {
+	p := &signatureParser{sig: signature}
+	t, err := p.referenceType()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
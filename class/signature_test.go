@@ -0,0 +1,113 @@
+package class
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseClassSignature(t *testing.T) {
+	for _, test := range []struct {
+		signature, java, simple string
+	}{
+		{
+			"<E:Ljava/lang/Object;>Ljava/util/AbstractList<TE;>;Ljava/util/List<TE;>;Ljava/util/RandomAccess;",
+			"<E> extends java.util.AbstractList<E> implements java.util.List<E>, java.util.RandomAccess",
+			"<E> extends AbstractList<E> implements List<E>, RandomAccess",
+		},
+		{
+			"<K:Ljava/lang/Object;V::Ljava/lang/Comparable<-TV;>;>Ljava/lang/Object;",
+			"<K, V extends java.lang.Comparable<? super V>> extends java.lang.Object",
+			"<K, V extends Comparable<? super V>> extends Object",
+		},
+		{
+			"<T:Ljava/lang/Number;:Ljava/io/Serializable;>LOuter<TT;>.Inner<*>;",
+			"<T extends java.lang.Number & java.io.Serializable> extends Outer<T>.Inner<?>",
+			"<T extends Number & Serializable> extends Outer<T>.Inner<?>",
+		},
+	} {
+		cs, err := ParseClassSignature(test.signature)
+		if err != nil {
+			t.Errorf("%s: %v", test.signature, err)
+			continue
+		}
+		if java := cs.JavaName(); java != test.java {
+			t.Errorf("%s\n got %s\nwant %s", test.signature, java, test.java)
+		}
+		if simple := cs.SimpleName(); simple != test.simple {
+			t.Errorf("%s\n got %s\nwant %s", test.signature, simple, test.simple)
+		}
+	}
+}
+
+func TestParseMethodSignature(t *testing.T) {
+	ms, err := ParseMethodSignature("<T:Ljava/lang/Object;X:Ljava/lang/Exception;>([TT;Ljava/util/Map<TK;+Ljava/util/List<TV;>;>;I)[TT;^TX;^Ljava/io/IOException;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "<T, X extends Exception> T[] (T[], Map<K, ? extends List<V>>, int) throws X, IOException"
+	if simple := ms.SimpleName(); simple != expected {
+		t.Errorf("got %s\nwant %s", simple, expected)
+	}
+	m, ok := ms.Params[1].(*ClassTypeSignature)
+	if !ok || m.ClassName() != "java/util/Map" || m.Classes[0].TypeArgs[1].Wildcard != WildcardExtends {
+		t.Errorf("unexpected param %v", ms.Params[1])
+	}
+	if ms, err = ParseMethodSignature("()V"); err != nil || ms.JavaName() != "void ()" {
+		t.Errorf("%v %v", ms, err)
+	}
+}
+
+func TestParseFieldSignature(t *testing.T) {
+	ts, err := ParseFieldSignature("Ljava/util/Map$Entry<Ljava/lang/String;[[TV;>;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if java := ts.JavaName(); java != "java.util.Map$Entry<java.lang.String, V[][]>" {
+		t.Errorf("java name %s", java)
+	}
+}
+
+func TestSignatureErrors(t *testing.T) {
+	for _, signature := range []string{
+		"",
+		"I",
+		"Ljava/util/List<>;",
+		"Ljava/util/List<TE;",
+		"TE",
+		"Ljava/lang/String;;",
+		"[V",
+		strings.Repeat("[", 256) + "Ljava/lang/Object;",
+	} {
+		if _, err := ParseFieldSignature(signature); err == nil {
+			t.Errorf("%q: expected error", signature)
+		} else if _, ok := err.(*SignatureError); !ok {
+			t.Errorf("%q: unexpected error %v", signature, err)
+		}
+	}
+	for _, signature := range []string{
+		"(I",
+		"<>()V",
+		"<T>()V",
+		"()",
+		"()V^[Ljava/lang/Exception;",
+		"(V)V",
+	} {
+		if _, err := ParseMethodSignature(signature); err == nil {
+			t.Errorf("%q: expected error", signature)
+		}
+	}
+	if _, err := ParseClassSignature("<T:Ljava/lang/Object;>"); err == nil {
+		t.Error("expected error for missing superclass")
+	}
+	// signature_index 为0的 Signature 属性返回错误而不是空指针
+	empty := &AttrSignature{}
+	if _, err := empty.ClassSignature(); err == nil {
+		t.Error("expected class signature error")
+	}
+	if _, err := empty.MethodSignature(); err == nil {
+		t.Error("expected method signature error")
+	}
+	if _, err := empty.FieldSignature(); err == nil {
+		t.Error("expected field signature error")
+	}
+}
@@ -0,0 +1,199 @@
+package class
+
+import (
+	"fmt"
+	"math"
+)
+
+// 展开后的栈映射帧，Offset 为字节码的绝对偏移
+// Locals 与 class 文件中的表示一致，long 和 double 只占一项
+type Frame struct {
+	Offset int
+	Locals []VerificationType
+	Stack []VerificationType
+}
+
+// 方法入口处的隐式帧 (JVMS 4.10.1.6)，由 this 和参数类型构成
+// 参数中的对象类型需要类常量: pool 不为nil时添加到 pool 中，CompressFrames 的结果才能写出；
+// pool 为nil时使用不在常量池中的类常量，不修改class文件，只用于读取
+func InitialFrame(pool *ConstantPool, thisClass *ConstClass, method *Method) (*Frame, error) {
+	md, err := method.Type()
+	if err != nil {
+		return nil, err
+	}
+	frame := &Frame{Offset: -1}
+	if method.AccessFlags & MethodAccStatic == 0 {
+		if utf8String(method.Name) == "<init>" && className(thisClass) != "java/lang/Object" {
+			frame.Locals = append(frame.Locals, &UninitializedThisVariable{})
+		} else {
+			frame.Locals = append(frame.Locals, &ObjectVariable{Class: thisClass})
+		}
+	}
+	for _, param := range md.Params {
+		vt, err := verificationTypeOf(pool, param)
+		if err != nil {
+			return nil, err
+		}
+		frame.Locals = append(frame.Locals, vt)
+	}
+	return frame, nil
+}
+
+func verificationTypeOf(pool *ConstantPool, t *FieldType) (VerificationType, error) {
+	switch t.Kind {
+	case TypeBoolean, TypeByte, TypeChar, TypeShort, TypeInt:
+		return &IntegerVariable{}, nil
+	case TypeFloat:
+		return &FloatVariable{}, nil
+	case TypeLong:
+		return &LongVariable{}, nil
+	case TypeDouble:
+		return &DoubleVariable{}, nil
+	}
+	name := t.ClassName
+	if t.Kind == TypeArray {
+		name = t.String()
+	}
+	if pool == nil {
+		return &ObjectVariable{Class: &ConstClass{Name: &ConstUTF8{s: name}}}, nil
+	}
+	i, err := pool.AddClass(name)
+	if err != nil {
+		return nil, err
+	}
+	class, err := pool.GetClass(i)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectVariable{Class: class}, nil
+}
+
+// 将增量编码的帧展开为每个偏移处完整的 locals 和 stack
+// initial 为方法入口处的隐式帧，参见 InitialFrame
+func ExpandStackMapTable(table *AttrStackMapTable, initial *Frame) ([]*Frame, error) {
+	frames := make([]*Frame, 0, len(table.Entries))
+	prev := initial
+	for i, entry := range table.Entries {
+		frame := &Frame{}
+		var delta int
+		switch f := entry.(type) {
+		case *SameFrame:
+			delta = int(f.frameType)
+			frame.Locals = prev.Locals
+		case *SameLocals1StackItemFrame:
+			delta = int(f.frameType) - 64
+			frame.Locals = prev.Locals
+			frame.Stack = f.Stack
+		case *SameLocals1StackItemFrameExtended:
+			delta = int(f.OffsetDelta)
+			frame.Locals = prev.Locals
+			frame.Stack = f.Stack
+		case *ChopFrame:
+			delta = int(f.OffsetDelta)
+			k := 251 - int(f.frameType)
+			if k > len(prev.Locals) {
+				return nil, fmt.Errorf("stack map frame %d: chop %d locals, only %d present", i, k, len(prev.Locals))
+			}
+			frame.Locals = prev.Locals[:len(prev.Locals) - k]
+		case *SameFrameExtended:
+			delta = int(f.OffsetDelta)
+			frame.Locals = prev.Locals
+		case *AppendFrame:
+			delta = int(f.OffsetDelta)
+			frame.Locals = append(append([]VerificationType{}, prev.Locals...), f.Locals...)
+		case *FullFrame:
+			delta = int(f.OffsetDelta)
+			frame.Locals = f.Locals
+			frame.Stack = f.Stack
+		default:
+			return nil, fmt.Errorf("stack map frame %d: unknow frame type %d", i, entry.FrameType())
+		}
+		// 第一帧的 offset_delta 即偏移，之后每帧为 offset_delta + 1
+		frame.Offset = prev.Offset + delta + 1
+		frames = append(frames, frame)
+		prev = frame
+	}
+	return frames, nil
+}
+
+// ExpandStackMapTable 的逆过程，为每一帧选择最短的编码
+// frames 必须按偏移严格递增
+func CompressFrames(initial *Frame, frames []*Frame) (*AttrStackMapTable, error) {
+	table := &AttrStackMapTable{}
+	prev := initial
+	for i, frame := range frames {
+		delta := frame.Offset - prev.Offset - 1
+		if delta < 0 || delta > math.MaxUint16 {
+			return nil, fmt.Errorf("stack map frame %d: offset %d out of order", i, frame.Offset)
+		}
+		table.Entries = append(table.Entries, compressFrame(prev.Locals, frame, delta))
+		prev = frame
+	}
+	return table, nil
+}
+
+func compressFrame(prevLocals []VerificationType, frame *Frame, delta int) StackMapFrame {
+	offsetDelta := uint16(delta)
+	diff := len(frame.Locals) - len(prevLocals)
+	switch {
+	case len(frame.Stack) == 0 && diff == 0 && sameVerificationTypes(prevLocals, frame.Locals):
+		if delta < 64 {
+			return &SameFrame{frameType: uint8(delta)}
+		}
+		return &SameFrameExtended{frameType: 251, OffsetDelta: offsetDelta}
+	case len(frame.Stack) == 1 && diff == 0 && sameVerificationTypes(prevLocals, frame.Locals):
+		if delta < 64 {
+			return &SameLocals1StackItemFrame{frameType: uint8(64 + delta), Stack: frame.Stack}
+		}
+		return &SameLocals1StackItemFrameExtended{frameType: 247, OffsetDelta: offsetDelta, Stack: frame.Stack}
+	case len(frame.Stack) == 0 && diff < 0 && diff >= -3 && sameVerificationTypes(prevLocals[:len(frame.Locals)], frame.Locals):
+		return &ChopFrame{frameType: uint8(251 + diff), OffsetDelta: offsetDelta}
+	case len(frame.Stack) == 0 && diff > 0 && diff <= 3 && sameVerificationTypes(prevLocals, frame.Locals[:len(prevLocals)]):
+		return &AppendFrame{frameType: uint8(251 + diff), OffsetDelta: offsetDelta, Locals: frame.Locals[len(prevLocals):]}
+	}
+	return &FullFrame{frameType: 255, OffsetDelta: offsetDelta, Locals: frame.Locals, Stack: frame.Stack}
+}
+
+func sameVerificationTypes(a, b []VerificationType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameVerificationType(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameVerificationType(a, b VerificationType) bool {
+	if a.Tag() != b.Tag() {
+		return false
+	}
+	switch a := a.(type) {
+	case *ObjectVariable:
+		return className(a.Class) == className(b.(*ObjectVariable).Class)
+	case *UninitializedVariable:
+		return a.Offset == b.(*UninitializedVariable).Offset
+	}
+	return true
+}
+
+// 方法 Code 属性中的栈映射帧展开后的结果，没有 StackMapTable 时返回nil
+// 只读取class文件，可以并发调用
+func (classfile *ClassFile) StackMapFrames(method *Method) ([]*Frame, error) {
+	code := method.Code()
+	if code == nil {
+		return nil, nil
+	}
+	for _, attr := range code.Attrs {
+		if table, ok := attr.(*AttrStackMapTable); ok {
+			initial, err := InitialFrame(nil, classfile.ThisClass, method)
+			if err != nil {
+				return nil, err
+			}
+			return ExpandStackMapTable(table, initial)
+		}
+	}
+	return nil, nil
+}
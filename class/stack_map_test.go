@@ -0,0 +1,120 @@
+package class
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestStackMapFramesRoundTrip(t *testing.T) {
+	cf, err := NewClassFile(bytes.NewReader(testByteCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	poolLength := cf.ConstantPool.Length()
+	for _, method := range cf.Methods {
+		frames, err := cf.StackMapFrames(method)
+		// 读取帧不能向常量池添加类常量
+		if cf.ConstantPool.Length() != poolLength {
+			t.Fatalf("%s: constant pool grew from %d to %d", method.Name, poolLength, cf.ConstantPool.Length())
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", method.Name, err)
+		}
+		if frames == nil {
+			continue
+		}
+		count++
+		var table *AttrStackMapTable
+		for _, attr := range method.Code().Attrs {
+			if smt, ok := attr.(*AttrStackMapTable); ok {
+				table = smt
+			}
+		}
+		initial, _ := InitialFrame(nil, cf.ThisClass, method)
+		compressed, err := CompressFrames(initial, frames)
+		if err != nil {
+			t.Fatalf("%s: %v", method.Name, err)
+		}
+		if !reflect.DeepEqual(compressed, table) {
+			t.Errorf("%s%s: compressed frames differ from original", method.Name, method.Descriptor)
+		}
+	}
+	if count == 0 {
+		t.Fatal("no StackMapTable found")
+	}
+	buf := &bytes.Buffer{}
+	if _, err := cf.WriteTo(buf); err != nil || !bytes.Equal(buf.Bytes(), testByteCode) {
+		t.Errorf("class file changed after reading frames: %v", err)
+	}
+
+	// ArrayList(int) 在偏移 36 处的帧，javap 输出 locals = [ class java/util/ArrayList, int ]
+	method := cf.Method("<init>", "(I)V")
+	frames, err := cf.StackMapFrames(method)
+	if err != nil || len(frames) == 0 {
+		t.Fatal(frames, err)
+	}
+	last := frames[len(frames) - 1]
+	if len(last.Locals) != 2 || className(last.Locals[0].(*ObjectVariable).Class) != "java/util/ArrayList" || last.Locals[1].Tag() != ItemInteger || len(last.Stack) != 0 {
+		t.Errorf("unexpected frame %+v", last)
+	}
+}
+
+func TestCompressFrames(t *testing.T) {
+	pool := NewEmptyConstantPool()
+	i, _ := pool.AddClass("Foo")
+	foo, _ := pool.GetClass(i)
+	name, _ := pool.AddUTF8("<init>")
+	desc, _ := pool.AddUTF8("(JLjava/lang/String;)V")
+	method := &Method{Name: pool.pool[name].(*ConstUTF8), Descriptor: pool.pool[desc].(*ConstUTF8)}
+	initial, err := InitialFrame(pool, foo, method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial.Locals) != 3 || initial.Locals[0].Tag() != ItemUninitializedThis || initial.Locals[1].Tag() != ItemLong ||
+		className(initial.Locals[2].(*ObjectVariable).Class) != "java/lang/String" {
+		t.Fatalf("unexpected initial frame %+v", initial)
+	}
+	this := &ObjectVariable{Class: foo}
+	locals := []VerificationType{this, &LongVariable{}, initial.Locals[2]}
+	frames := []*Frame{
+		{Offset: 10, Locals: initial.Locals},
+		{Offset: 200, Locals: initial.Locals, Stack: []VerificationType{&IntegerVariable{}}},
+		{Offset: 201, Locals: initial.Locals[:1]},
+		{Offset: 300, Locals: initial.Locals[:1]},
+		{Offset: 301, Locals: []VerificationType{&UninitializedThisVariable{}, &IntegerVariable{}, &FloatVariable{}}},
+		{Offset: 310, Locals: locals, Stack: []VerificationType{&NullVariable{}, &UninitializedVariable{3}}},
+		{Offset: 311, Locals: locals, Stack: []VerificationType{&NullVariable{}}},
+	}
+	table, err := CompressFrames(initial, frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []uint8
+	for _, entry := range table.Entries {
+		types = append(types, entry.FrameType())
+	}
+	if expected := []uint8{10, 247, 249, 251, 253, 255, 64}; !reflect.DeepEqual(types, expected) {
+		t.Errorf("frame types %v, expected %v", types, expected)
+	}
+	expanded, err := ExpandStackMapTable(table, initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, frame := range expanded {
+		if frame.Offset != frames[i].Offset || !sameVerificationTypes(frame.Locals, frames[i].Locals) || !sameVerificationTypes(frame.Stack, frames[i].Stack) {
+			t.Errorf("frame %d: got %+v, expected %+v", i, frame, frames[i])
+		}
+	}
+	if _, err := CompressFrames(initial, []*Frame{{Offset: 5}, {Offset: 5}}); err == nil {
+		t.Error("expected error for duplicate offset")
+	}
+	if _, err := ExpandStackMapTable(&AttrStackMapTable{[]StackMapFrame{&ChopFrame{frameType: 248}}}, &Frame{Offset: -1}); err == nil {
+		t.Error("expected error chopping empty locals")
+	}
+	// 手工构造的 ClassFile 可能没有 this_class
+	if frame, err := InitialFrame(nil, nil, method); err != nil || frame.Locals[0].Tag() != ItemUninitializedThis {
+		t.Errorf("unexpected frame %+v %v", frame, err)
+	}
+}
@@ -0,0 +1,578 @@
+package class
+
+import (
+	"io"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"unicode/utf16"
+)
+
+// 自定义属性实现该接口后可以被写回class文件
+// 只需要写入 info 部分，属性名和 attribute_length 由调用方负责
+type AttrEncoder interface {
+	WriteInfo(writer io.Writer, pool *ConstantPool) error
+}
+
+// 编码器，出现第一个错误后后续的写入都会被忽略
+type encoder struct {
+	buf *bytes.Buffer
+	pool *ConstantPool
+	err error
+}
+
+func newEncoder(pool *ConstantPool) *encoder {
+	return &encoder{buf: &bytes.Buffer{}, pool: pool}
+}
+
+func (e *encoder) u1(v uint8) {
+	if e.err == nil {
+		e.buf.WriteByte(v)
+	}
+}
+
+func (e *encoder) u2(v uint16) {
+	if e.err == nil {
+		binary.Write(e.buf, binary.BigEndian, v)
+	}
+}
+
+func (e *encoder) u4(v uint32) {
+	if e.err == nil {
+		binary.Write(e.buf, binary.BigEndian, v)
+	}
+}
+
+func (e *encoder) bytes(b []byte) {
+	if e.err == nil {
+		e.buf.Write(b)
+	}
+}
+
+func (e *encoder) length16(n int, what string) {
+	if n > math.MaxUint16 {
+		e.fail(fmt.Errorf("too many %s: %d", what, n))
+		return
+	}
+	e.u2(uint16(n))
+}
+
+func (e *encoder) length8(n int, what string) {
+	if n > math.MaxUint8 {
+		e.fail(fmt.Errorf("too many %s: %d", what, n))
+		return
+	}
+	e.u1(uint8(n))
+}
+
+func (e *encoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// 写入常量在常量池中的索引，nil 写入0
+func (e *encoder) index(c Constant) {
+	if c == nil {
+		e.u2(0)
+		return
+	}
+	if v := reflect.ValueOf(c); v.Kind() == reflect.Ptr && v.IsNil() {
+		e.u2(0)
+		return
+	}
+	i, err := e.pool.IndexOf(c)
+	if err != nil {
+		e.fail(err)
+		return
+	}
+	e.u2(i)
+}
+
+func (e *encoder) utf8Index(s string) {
+	i, err := e.pool.IndexOfUTF8(s)
+	if err != nil {
+		e.fail(err)
+		return
+	}
+	e.u2(i)
+}
+
+func (classfile *ClassFile) WriteTo(writer io.Writer) (int64, error) {
+	if classfile.IsPartial() {
+		return 0, PartialClassFileError
+	}
+	e := newEncoder(classfile.ConstantPool)
+	e.u4(classfile.Magic)
+	e.u2(classfile.Minor)
+	e.u2(classfile.Major)
+	e.constantPool(classfile.ConstantPool)
+	e.u2(uint16(classfile.AccessFlags))
+	e.index(classfile.ThisClass)
+	e.index(classfile.SuperClass)
+	e.length16(len(classfile.Interfaces), "interfaces")
+	for _, inter := range classfile.Interfaces {
+		e.index(inter)
+	}
+	e.length16(len(classfile.Fields), "fields")
+	for _, field := range classfile.Fields {
+		e.u2(uint16(field.AccessFlags))
+		e.index(field.Name)
+		e.index(field.Descriptor)
+		e.attrs(field.Attrs)
+	}
+	e.length16(len(classfile.Methods), "methods")
+	for _, method := range classfile.Methods {
+		e.u2(uint16(method.AccessFlags))
+		e.index(method.Name)
+		e.index(method.Descriptor)
+		e.attrs(method.Attrs)
+	}
+	e.attrs(classfile.Attrs)
+	if e.err != nil {
+		return 0, e.err
+	}
+	return e.buf.WriteTo(writer)
+}
+
+func (e *encoder) constantPool(pool *ConstantPool) {
+	e.length16(pool.Length(), "constants")
+	for i := 1; i < pool.Length(); i++ {
+		c := pool.pool[i]
+		if c == nil {
+			// long 和 double 之后的空槽位
+			continue
+		}
+		e.u1(uint8(c.Tag()))
+		switch c := c.(type) {
+		case *ConstUTF8:
+			b := c.raw
+			if b == nil {
+				b = encodeMUTF8(c.s)
+			}
+			e.length16(len(b), "utf8 bytes")
+			e.bytes(b)
+		case *ConstInteger:
+			e.u4(uint32(c.Val))
+		case *ConstFloat:
+			e.u4(math.Float32bits(c.Val))
+		case *ConstLong:
+			e.u4(uint32(uint64(c.Val) >> 32))
+			e.u4(uint32(c.Val))
+		case *ConstDouble:
+			bits := math.Float64bits(c.Val)
+			e.u4(uint32(bits >> 32))
+			e.u4(uint32(bits))
+		case *ConstClass:
+			e.u2(c.NameIndex)
+		case *ConstString:
+			e.u2(c.UTF8StringIndex)
+		case *ConstFieldRef:
+			e.u2(c.ClassIndex)
+			e.u2(c.NameAndTypeIndex)
+		case *ConstMethodRef:
+			e.u2(c.ClassIndex)
+			e.u2(c.NameAndTypeIndex)
+		case *ConstInterfaceMethodRef:
+			e.u2(c.ClassIndex)
+			e.u2(c.NameAndTypeIndex)
+		case *ConstNameAndType:
+			e.u2(c.NameIndex)
+			e.u2(c.DescriptorIndex)
+		case *ConstMethodHandle:
+			e.u1(c.RefKind)
+			e.u2(c.RefIndex)
+		case *ConstMethodType:
+			e.u2(c.DescriptorIndex)
+		case *ConstDynamic:
+			e.u2(c.BootstrapMethodAttrIndex)
+			e.u2(c.NameAndTypeIndex)
+		case *ConstInvokeDynamic:
+			e.u2(c.BootstrapMethodAttrIndex)
+			e.u2(c.NameAndTypeIndex)
+		case *ConstModule:
+			e.u2(c.NameIndex)
+		case *ConstPackage:
+			e.u2(c.NameIndex)
+		default:
+			e.fail(fmt.Errorf("unknow constant %s", c))
+		}
+	}
+}
+
+func (e *encoder) attrs(attrs []Attr) {
+	e.length16(len(attrs), "attributes")
+	for _, attr := range attrs {
+		e.attr(attr)
+	}
+}
+
+func (e *encoder) attr(attr Attr) {
+	if e.err != nil {
+		return
+	}
+	e.utf8Index(attr.Name())
+	info := newEncoder(e.pool)
+	info.attrInfo(attr)
+	if info.err != nil {
+		e.fail(info.err)
+		return
+	}
+	if int64(info.buf.Len()) > math.MaxUint32 {
+		e.fail(fmt.Errorf("%s attribute too large", attr.Name()))
+		return
+	}
+	e.u4(uint32(info.buf.Len()))
+	e.bytes(info.buf.Bytes())
+}
+
+func (e *encoder) attrInfo(attr Attr) {
+	switch a := attr.(type) {
+	case *AttrConstantValue:
+		e.index(a.Val)
+	case *AttrCode:
+		e.u2(a.MaxStack)
+		e.u2(a.MaxLocals)
+		e.u4(uint32(len(a.Code)))
+		e.bytes(a.Code)
+		e.length16(len(a.ExceptionTable), "exception table entries")
+		for _, ex := range a.ExceptionTable {
+			e.u2(ex.StartPC)
+			e.u2(ex.EndPC)
+			e.u2(ex.HandlerPC)
+			e.index(ex.CatchType)
+		}
+		e.attrs(a.Attrs)
+	case *AttrStackMapTable:
+		e.length16(len(a.Entries), "stack map frames")
+		for _, frame := range a.Entries {
+			e.stackMapFrame(frame)
+		}
+	case *AttrExceptions:
+		e.classes(a.ExceptionTable)
+	case *AttrInnerClasses:
+		e.length16(len(a.Classes), "inner classes")
+		for _, c := range a.Classes {
+			e.index(c.InnerClass)
+			e.index(c.OuterClass)
+			e.index(c.InnerName)
+			e.u2(uint16(c.InnerClassAccessFlags))
+		}
+	case *AttrEnclosingMethod:
+		e.index(a.Class)
+		e.index(a.Method)
+	case *AttrSynthetic, *AttrDeprecated:
+	case *AttrSignature:
+		e.index(a.Signature)
+	case *AttrSourceFile:
+		e.index(a.SourceFile)
+	case *AttrSourceDebugExtension:
+		e.bytes(a.DebugExtension)
+	case *AttrLineNumberTable:
+		e.length16(len(a.LineNumberTable), "line numbers")
+		for _, entry := range a.LineNumberTable {
+			e.u2(entry.StartPC)
+			e.u2(entry.LineNumber)
+		}
+	case *AttrLocalVariableTable:
+		e.length16(len(a.LocalVarTable), "local variables")
+		for _, entry := range a.LocalVarTable {
+			e.u2(entry.StartPC)
+			e.u2(entry.Length)
+			e.index(entry.Name)
+			e.index(entry.Descriptor)
+			e.u2(entry.Index)
+		}
+	case *AttrLocalVariableTypeTable:
+		e.length16(len(a.LocalVarTypeTable), "local variable types")
+		for _, entry := range a.LocalVarTypeTable {
+			e.u2(entry.StartPC)
+			e.u2(entry.Length)
+			e.index(entry.Name)
+			e.index(entry.Signature)
+			e.u2(entry.Index)
+		}
+	case *AttrRuntimeVisibleAnnotations:
+		e.annotations(a.Annotations)
+	case *AttrRuntimeInvisibleAnnotations:
+		e.annotations(a.Annotations)
+	case *AttrRuntimeVisibleParameterAnnotations:
+		e.parameterAnnotations(a.ParameterAnnotations)
+	case *AttrRuntimeInvisibleParameterAnnotations:
+		e.parameterAnnotations(a.ParameterAnnotations)
+	case *AttrRuntimeVisibleTypeAnnotations:
+		e.typeAnnotations(a.Annotations)
+	case *AttrRuntimeInvisibleTypeAnnotations:
+		e.typeAnnotations(a.Annotations)
+	case *AttrAnnotationDefault:
+		e.elementVal(a.DefaultVal)
+	case *AttrBootstrapMethods:
+		e.length16(len(a.BootstrapMethods), "bootstrap methods")
+		for _, bsm := range a.BootstrapMethods {
+			e.index(bsm.BootstrapMethodRef)
+			e.length16(len(bsm.BootstrapArguments), "bootstrap arguments")
+			for _, arg := range bsm.BootstrapArguments {
+				e.index(arg)
+			}
+		}
+	case *AttrMethodParameters:
+		e.length8(len(a.Parameters), "method parameters")
+		for _, p := range a.Parameters {
+			e.index(p.Name)
+			e.u2(uint16(p.AccessFlags))
+		}
+	case *AttrModule:
+		e.module(a)
+	case *AttrModulePackages:
+		e.length16(len(a.Packages), "packages")
+		for _, pkg := range a.Packages {
+			e.index(pkg)
+		}
+	case *AttrModuleMainClass:
+		e.index(a.MainClass)
+	case *AttrNestHost:
+		e.index(a.HostClass)
+	case *AttrNestMembers:
+		e.classes(a.Classes)
+	case *AttrRecord:
+		e.length16(len(a.Components), "record components")
+		for _, rc := range a.Components {
+			e.index(rc.Name)
+			e.index(rc.Descriptor)
+			e.attrs(rc.Attrs)
+		}
+	case *AttrPermittedSubclasses:
+		e.classes(a.Classes)
+	case *AttrUnknown:
+		e.bytes(a.Info)
+	case AttrEncoder:
+		e.fail(a.WriteInfo(e.buf, e.pool))
+	default:
+		e.fail(fmt.Errorf("%s attribute can not be encoded", attr.Name()))
+	}
+}
+
+func (e *encoder) classes(classes []*ConstClass) {
+	e.length16(len(classes), "classes")
+	for _, class := range classes {
+		e.index(class)
+	}
+}
+
+func (e *encoder) module(m *AttrModule) {
+	e.index(m.ModuleName)
+	e.u2(uint16(m.ModuleFlags))
+	e.index(m.ModuleVersion)
+	e.length16(len(m.Requires), "requires")
+	for _, r := range m.Requires {
+		e.index(r.Requires)
+		e.u2(uint16(r.RequiresFlags))
+		e.index(r.RequiresVersion)
+	}
+	e.length16(len(m.Exports), "exports")
+	for _, ex := range m.Exports {
+		e.moduleExports(ex)
+	}
+	e.length16(len(m.Opens), "opens")
+	for _, o := range m.Opens {
+		e.moduleExports(o.ModuleExports)
+	}
+	e.classes(m.Uses)
+	e.length16(len(m.Provides), "provides")
+	for _, p := range m.Provides {
+		e.index(p.Provides)
+		e.classes(p.With)
+	}
+}
+
+func (e *encoder) moduleExports(ex *ModuleExports) {
+	e.index(ex.Package)
+	e.u2(uint16(ex.Flags))
+	e.length16(len(ex.To), "modules")
+	for _, to := range ex.To {
+		e.index(to)
+	}
+}
+
+func (e *encoder) annotations(annotations []*Annotation) {
+	e.length16(len(annotations), "annotations")
+	for _, annotation := range annotations {
+		e.annotation(annotation.Type, annotation.ElementValPairs)
+	}
+}
+
+func (e *encoder) annotation(typ *ConstUTF8, pairs []*ElementValPair) {
+	e.index(typ)
+	e.length16(len(pairs), "element value pairs")
+	for _, pair := range pairs {
+		e.index(pair.ElementName)
+		e.elementVal(pair.Val)
+	}
+}
+
+func (e *encoder) parameterAnnotations(parameterAnnotations []*ParameterAnnotation) {
+	e.length8(len(parameterAnnotations), "parameters")
+	for _, pa := range parameterAnnotations {
+		e.annotations(pa.Annotations)
+	}
+}
+
+func (e *encoder) elementVal(val ElementVal) {
+	if val == nil {
+		e.fail(fmt.Errorf("nil element value"))
+		return
+	}
+	e.u1(val.Tag())
+	switch v := val.(type) {
+	case *ElementValByte:
+		e.index(v.Val)
+	case *ElementValChar:
+		e.index(v.Val)
+	case *ElementValDouble:
+		e.index(v.Val)
+	case *ElementValFloat:
+		e.index(v.Val)
+	case *ElementValInt:
+		e.index(v.Val)
+	case *ElementValLong:
+		e.index(v.Val)
+	case *ElementValShort:
+		e.index(v.Val)
+	case *ElementValBoolean:
+		e.index(v.Val)
+	case *ElementValString:
+		e.index(v.Val)
+	case *ElementValEnum:
+		e.index(v.TypeName)
+		e.index(v.ConstName)
+	case *ElementValClass:
+		e.index(v.ClassInfo)
+	case *ElementValAnnotation:
+		e.annotation(v.AnnotationVal.Type, v.AnnotationVal.ElementValPairs)
+	case *ElementValArray:
+		e.length16(len(v.Val), "element values")
+		for _, ev := range v.Val {
+			e.elementVal(ev)
+		}
+	default:
+		e.fail(fmt.Errorf("unknow element value %s", val))
+	}
+}
+
+func (e *encoder) typeAnnotations(annotations []*TypeAnnotation) {
+	e.length16(len(annotations), "type annotations")
+	for _, ta := range annotations {
+		e.u1(ta.TargetType)
+		e.target(ta.Target)
+		var path []*Path
+		if ta.TargetPath != nil {
+			path = ta.TargetPath.Path
+		}
+		e.length8(len(path), "type path entries")
+		for _, p := range path {
+			e.u1(p.TypePathKind)
+			e.u1(p.TypeArgumentIndex)
+		}
+		e.annotation(ta.Type, ta.ElementValPairs)
+	}
+}
+
+func (e *encoder) target(target Target) {
+	switch t := target.(type) {
+	case *TypeParameterTarget:
+		e.u1(t.TypeParameterIndex)
+	case *SupertypeTarget:
+		e.u2(t.SupertypeIndex)
+	case *TypeParameterBoundTarget:
+		e.u1(t.TypeParameterIndex)
+		e.u1(t.BoundIndex)
+	case *EmptyTarget:
+	case *FormalParameterTarget:
+		e.u1(t.FormalParameterIndex)
+	case *ThrowsTarget:
+		e.u2(t.ThrowsTypeIndex)
+	case *LocalvarTarget:
+		e.length16(len(t.Table), "localvar table entries")
+		for _, table := range t.Table {
+			e.u2(table.StartPC)
+			e.u2(table.Length)
+			e.u2(table.Index)
+		}
+	case *CatchTarget:
+		e.u2(t.ExceptionTableIndex)
+	case *OffsetTarget:
+		e.u2(t.Offset)
+	case *TypeArgumentTarget:
+		e.u2(t.Offset)
+		e.u1(t.TypeArgumentIndex)
+	default:
+		e.fail(fmt.Errorf("unknow target %v", target))
+	}
+}
+
+func (e *encoder) stackMapFrame(frame StackMapFrame) {
+	e.u1(frame.FrameType())
+	switch f := frame.(type) {
+	case *SameFrame:
+	case *SameLocals1StackItemFrame:
+		e.verificationTypes(f.Stack, 1)
+	case *SameLocals1StackItemFrameExtended:
+		e.u2(f.OffsetDelta)
+		e.verificationTypes(f.Stack, 1)
+	case *ChopFrame:
+		e.u2(f.OffsetDelta)
+	case *SameFrameExtended:
+		e.u2(f.OffsetDelta)
+	case *AppendFrame:
+		e.u2(f.OffsetDelta)
+		e.verificationTypes(f.Locals, int(f.FrameType()) - 251)
+	case *FullFrame:
+		e.u2(f.OffsetDelta)
+		e.length16(len(f.Locals), "locals")
+		e.verificationTypes(f.Locals, len(f.Locals))
+		e.length16(len(f.Stack), "stack items")
+		e.verificationTypes(f.Stack, len(f.Stack))
+	default:
+		e.fail(fmt.Errorf("unknow stack map frame type %d", frame.FrameType()))
+	}
+}
+
+func (e *encoder) verificationTypes(types []VerificationType, n int) {
+	if len(types) != n {
+		e.fail(fmt.Errorf("expect %d verification types, got %d", n, len(types)))
+		return
+	}
+	for _, vt := range types {
+		e.u1(vt.Tag())
+		switch v := vt.(type) {
+		case *ObjectVariable:
+			e.index(v.Class)
+		case *UninitializedVariable:
+			e.u2(v.Offset)
+		}
+	}
+}
+
+// see java.io.DataOutputStream.writeUTF(String, DataOutput)
+func encodeMUTF8(s string) []byte {
+	chars := utf16.Encode([]rune(s))
+	bytearr := make([]byte, 0, len(chars))
+	for _, c := range chars {
+		switch {
+		case c >= 0x0001 && c <= 0x007F:
+			bytearr = append(bytearr, byte(c))
+		case c > 0x07FF:
+			bytearr = append(bytearr,
+				byte(0xE0 | c >> 12 & 0x0F),
+				byte(0x80 | c >> 6 & 0x3F),
+				byte(0x80 | c & 0x3F))
+		default:
+			bytearr = append(bytearr,
+				byte(0xC0 | c >> 6 & 0x1F),
+				byte(0x80 | c & 0x3F))
+		}
+	}
+	return bytearr
+}
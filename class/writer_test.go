@@ -0,0 +1,69 @@
+package class
+
+import (
+	"testing"
+	"bytes"
+)
+
+func TestClassFileWriteTo(t *testing.T) {
+	classFile, err := NewClassFile(bytes.NewReader(testByteCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	n, err := classFile.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(testByteCode)) {
+		t.Errorf("wrote %d bytes, expect %d", n, len(testByteCode))
+	}
+	if !bytes.Equal(buf.Bytes(), testByteCode) {
+		for i := 0; i < len(testByteCode) && i < buf.Len(); i++ {
+			if buf.Bytes()[i] != testByteCode[i] {
+				t.Fatalf("round trip differs at offset %d", i)
+			}
+		}
+		t.Fatal("round trip differs in length")
+	}
+}
+
+// 孤立的代理项解码后变成 U+FFFD，非标准的双字节编码解码后与单字节相同，写出时必须保留原始字节
+func TestWriteToRawUTF8(t *testing.T) {
+	name := "\xed\xa0\x80\xc1\x81\xc0\x80"
+	data := flagsTestClass(ACCPUBLIC | ACCSUPER, FieldAccPrivate, MethodAccPublic, name).Bytes()
+	classFile, err := NewClassFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := classFile.Methods[0].Name.String(); s != "\uFFFDA\x00" {
+		t.Errorf("unexpected decoded name %q", s)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := classFile.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("round trip changed the class file\n% x\nexpected\n% x", buf.Bytes(), data)
+	}
+}
+
+func TestEncodeMUTF8(t *testing.T) {
+	strings := []string{"", "ArrayList", "\x00", "中文", "߿ࠀ", "\U0001F600"}
+	for _, s := range strings {
+		b := encodeMUTF8(s)
+		if bytes.IndexByte(b, 0) >= 0 {
+			t.Errorf("%q encoded with zero byte", s)
+		}
+		decoded, err := decodeMUTF8(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded != s {
+			t.Errorf("%q decoded as %q", s, decoded)
+		}
+	}
+	if b := encodeMUTF8("\U0001F600"); len(b) != 6 {
+		t.Errorf("supplementary character should be encoded as surrogate pair, got % x", b)
+	}
+}
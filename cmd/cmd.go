@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"path"
 	"flag"
-	"log"
+	"strings"
+	"errors"
+
+	"github.com/yuya008/jvm4go/loader"
 )
 
 var (
@@ -15,6 +18,16 @@ var (
 
 type VM struct {
 	classPath string
+	// -Xbootclasspath/a: 追加的引导类路径，多次指定时按顺序连接
+	bootClassPathAppend string
+	mainClass string
+	args []string
+}
+
+// 子命令，例如 jvm4go javap
+var subcommands = map[string]func(args []string) error{
+	"javap": runJavap,
+	"dump": runDump,
 }
 
 func init() {
@@ -22,35 +35,101 @@ func init() {
 }
 
 func Run() error {
-	if err := parseArgs(); err != nil {
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			return subcommand(os.Args[2:])
+		}
+	}
+	if err := vm.parseArgs(os.Args[1:]); err != nil {
 		return err
 	}
-	log.Println(vm)
-	return nil
+	app, err := vm.newAppLoader()
+	if err != nil {
+		return err
+	}
+	// 还没有解释器，只加载主类
+	_, err = app.LoadClass(vm.mainClass)
+	return err
 }
 
-func parseArgs() error {
+func (vm *VM) parseArgs(arguments []string) error {
 	flagSet := flag.NewFlagSet(programName, flag.ExitOnError)
 	flagSet.Usage = usage
-	flagSet.StringVar(&vm.classPath, "-classpath", "", "")
-	if err := flagSet.Parse(os.Args[1:]); err != nil {
+	flagSet.StringVar(&vm.classPath, "cp", "", "")
+	flagSet.StringVar(&vm.classPath, "classpath", "", "")
+	// flag 包无法解析 -Xbootclasspath/a:<路径> 这种形式，先取出来
+	// 选项在主类之前，主类之后的参数都属于程序
+	var options []string
+	i := 0
+	for ; i < len(arguments) && strings.HasPrefix(arguments[i], "-"); i++ {
+		arg := arguments[i]
+		if strings.HasPrefix(arg, "-Xbootclasspath/a:") {
+			if vm.bootClassPathAppend != "" {
+				vm.bootClassPathAppend += string(os.PathListSeparator)
+			}
+			vm.bootClassPathAppend += strings.TrimPrefix(arg, "-Xbootclasspath/a:")
+			continue
+		}
+		options = append(options, arg)
+		// -cp 的值可能是单独的参数
+		if name := strings.TrimLeft(arg, "-"); (name == "cp" || name == "classpath") && i + 1 < len(arguments) {
+			i++
+			options = append(options, arguments[i])
+		}
+	}
+	if err := flagSet.Parse(options); err != nil {
 		return err
 	}
-	log.Println(flagSet.Args())
+	args := append(flagSet.Args(), arguments[i:]...)
+	if len(args) == 0 {
+		usage()
+	}
+	vm.mainClass, vm.args = args[0], args[1:]
 	return nil
 }
 
+// 引导类路径为 $JAVA_HOME 中的 JDK 类加上 -Xbootclasspath/a:，
+// 应用类路径为 -classpath，没有指定时使用 $CLASSPATH 或当前目录
+func (vm *VM) newAppLoader() (*loader.ClassLoader, error) {
+	javaHome := os.Getenv("JAVA_HOME")
+	if javaHome == "" {
+		return nil, errors.New("没有设置 JAVA_HOME 环境变量")
+	}
+	bootClassPath, err := loader.NewBootClassPath(javaHome, vm.bootClassPathAppend)
+	if err != nil {
+		return nil, err
+	}
+	classPath := vm.classPath
+	if classPath == "" {
+		classPath = os.Getenv("CLASSPATH")
+	}
+	if classPath == "" {
+		classPath = "."
+	}
+	appClassPath, err := loader.NewClassPath(classPath)
+	if err != nil {
+		return nil, err
+	}
+	boot := loader.NewBootstrapLoader(bootClassPath)
+	return loader.NewAppLoader(loader.NewPlatformLoader(boot, nil), appClassPath), nil
+}
+
 func usage() {
 	fmt.Printf(`用法: %s [-options] class [args...] (执行类)
 或  %s [-options] -jar jarfile [args...] (执行 jar 文件)
+或  %s javap [-c] [-v] [-p] [-l] <class|file> (反汇编类文件)
+或  %s dump [--format=json] <class|file> (以 JSON 格式输出类文件)
 其中选项包括:
 	-cp <目录和 zip/jar 文件的类搜索路径>
 	-classpath <目录和 zip/jar 文件的类搜索路径>
 		用 : 分隔的目录, JAR 档案
 		和 ZIP 档案列表, 用于搜索类文件。
+	-Xbootclasspath/a:<用 : 分隔的目录和 zip/jar 文件>
+		附加在引导类路径末尾, 可以多次指定
 	-version     输出产品版本并退出
 	-? -help     输出此帮助消息
 	-D<名称>=<值> 设置系统属性
-`, programName, programName)
+引导类从环境变量 JAVA_HOME 指定的 JDK 中加载
+`, programName, programName, programName, programName)
 	os.Exit(1)
 }
\ No newline at end of file
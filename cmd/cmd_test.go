@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+// 只有类名和超类的class文件
+func testClassBytes(t *testing.T, name, super string) []byte {
+	pool := class.NewEmptyConstantPool()
+	constClass := func(name string) *class.ConstClass {
+		i, err := pool.AddClass(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, _ := pool.GetClass(i)
+		return c
+	}
+	cf := &class.ClassFile{Magic: class.ClassFileMagic, Major: 52, ConstantPool: pool,
+		AccessFlags: class.ACCPUBLIC | class.ACCSUPER, ThisClass: constClass(name)}
+	if super != "" {
+		cf.SuperClass = constClass(super)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := cf.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeTestClass(t *testing.T, dir, name, super string) {
+	path := filepath.Join(dir, filepath.FromSlash(name) + ".class")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := ioutil.WriteFile(path, testClassBytes(t, name, super), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBootClassPathAppend(t *testing.T) {
+	// JDK 8 目录结构，rt.jar 中只有 java/lang/Object
+	javaHome := t.TempDir()
+	os.MkdirAll(filepath.Join(javaHome, "jre", "lib"), 0755)
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	f, _ := w.Create("java/lang/Object.class")
+	f.Write(testClassBytes(t, "java/lang/Object", ""))
+	w.Close()
+	if err := ioutil.WriteFile(filepath.Join(javaHome, "jre", "lib", "rt.jar"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JAVA_HOME", javaHome)
+
+	boot1, boot2, appDir := t.TempDir(), t.TempDir(), t.TempDir()
+	writeTestClass(t, boot1, "p/First", "java/lang/Object")
+	writeTestClass(t, boot2, "p/Second", "java/lang/Object")
+	// 引导类路径优先于应用类路径
+	writeTestClass(t, appDir, "p/First", "java/lang/Object")
+	writeTestClass(t, appDir, "Main", "java/lang/Object")
+
+	vm := &VM{}
+	// 主类之后看起来像选项的参数属于程序
+	args := []string{"arg", "-Xbootclasspath/a:x", "-cp", "y"}
+	err := vm.parseArgs(append([]string{"-Xbootclasspath/a:" + boot1, "-cp", appDir, "-Xbootclasspath/a:" + boot2, "Main"}, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.bootClassPathAppend != boot1 + string(os.PathListSeparator) + boot2 || vm.classPath != appDir ||
+		vm.mainClass != "Main" || !reflect.DeepEqual(vm.args, args) {
+		t.Errorf("unexpected vm %+v", vm)
+	}
+	app, err := vm.newAppLoader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, loader := range map[string]string{"p.First": "bootstrap", "p.Second": "bootstrap", "Main": "app", "java.lang.Object": "bootstrap"} {
+		c, err := app.LoadClass(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Loader.Name() != loader {
+			t.Errorf("%s loaded by %s, expected %s", name, c.Loader, loader)
+		}
+	}
+
+	t.Setenv("JAVA_HOME", "")
+	if _, err := vm.newAppLoader(); err == nil {
+		t.Error("expected error without JAVA_HOME")
+	}
+}
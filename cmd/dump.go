@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+type dumpOptions struct {
+	format string
+	classPath string
+}
+
+func runDump(args []string) error {
+	opts := &dumpOptions{}
+	flagSet := flag.NewFlagSet(programName + " dump", flag.ExitOnError)
+	flagSet.StringVar(&opts.format, "format", "json", "输出格式，目前只支持 json")
+	flagSet.StringVar(&opts.classPath, "cp", ".", "指定查找用户类文件的位置")
+	flagSet.StringVar(&opts.classPath, "classpath", ".", "指定查找用户类文件的位置")
+	flagSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s dump [--format=json] <class|file>...\n", programName)
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		os.Exit(2)
+	}
+	if opts.format != "json" {
+		return fmt.Errorf("不支持的输出格式: %s", opts.format)
+	}
+	for _, name := range flagSet.Args() {
+		path, data, err := findClassFile(name, opts.classPath)
+		if err != nil {
+			return err
+		}
+		classFile, err := class.NewClassFile(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		if err := dumpJSON(os.Stdout, classFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 每个类输出一行 JSON，多个类时可以直接交给 jq 逐行处理
+func dumpJSON(out io.Writer, classFile *class.ClassFile) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(classFile)
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+func TestDumpJSON(t *testing.T) {
+	_, data, err := findClassFile("ArrayList", "../class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	classFile, err := class.NewClassFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if err := dumpJSON(out, classFile); err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v["this_class"] != "java/util/ArrayList" {
+		t.Errorf("unexpected this_class %v", v["this_class"])
+	}
+	if methods, ok := v["methods"].([]interface{}); !ok || len(methods) != len(classFile.Methods) {
+		t.Errorf("unexpected methods %v", v["methods"])
+	}
+}
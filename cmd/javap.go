@@ -0,0 +1,985 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+type javapOptions struct {
+	code bool
+	verbose bool
+	private bool
+	lines bool
+	classPath string
+}
+
+func runJavap(args []string) error {
+	opts := &javapOptions{}
+	flagSet := flag.NewFlagSet(programName + " javap", flag.ExitOnError)
+	flagSet.BoolVar(&opts.code, "c", false, "对代码进行反汇编")
+	flagSet.BoolVar(&opts.verbose, "v", false, "输出附加信息")
+	flagSet.BoolVar(&opts.private, "p", false, "显示所有类和成员")
+	flagSet.BoolVar(&opts.lines, "l", false, "输出行号和本地变量表")
+	flagSet.StringVar(&opts.classPath, "cp", ".", "指定查找用户类文件的位置")
+	flagSet.StringVar(&opts.classPath, "classpath", ".", "指定查找用户类文件的位置")
+	flagSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s javap [-c] [-v] [-p] [-l] <class|file>...\n", programName)
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		os.Exit(2)
+	}
+	if opts.verbose {
+		opts.code = true
+		opts.lines = true
+		opts.private = true
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for _, name := range flagSet.Args() {
+		path, data, err := findClassFile(name, opts.classPath)
+		if err != nil {
+			return err
+		}
+		classFile, err := class.NewClassFile(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		p := &javap{out: out, opts: opts, classFile: classFile, pool: classFile.ConstantPool}
+		if opts.verbose {
+			p.printFileHeader(path, data)
+		}
+		p.printClass()
+	}
+	return nil
+}
+
+// name 可以是class文件路径，也可以是类名
+func findClassFile(name, classPath string) (string, []byte, error) {
+	if info, err := os.Stat(name); err == nil && !info.IsDir() {
+		data, err := ioutil.ReadFile(name)
+		return name, data, err
+	}
+	file := strings.Replace(strings.TrimSuffix(name, ".class"), ".", "/", -1) + ".class"
+	for _, dir := range filepath.SplitList(classPath) {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if data, err := ioutil.ReadFile(path); err == nil {
+			return path, data, nil
+		}
+	}
+	return "", nil, fmt.Errorf("找不到类: %s", name)
+}
+
+type javap struct {
+	out io.Writer
+	opts *javapOptions
+	classFile *class.ClassFile
+	pool *class.ConstantPool
+}
+
+func (p *javap) printf(format string, args ...interface{}) {
+	fmt.Fprintf(p.out, format, args...)
+}
+
+func (p *javap) index(c class.Constant) string {
+	i, err := p.pool.IndexOf(c)
+	if err != nil {
+		return "#?"
+	}
+	return fmt.Sprintf("#%d", i)
+}
+
+func (p *javap) printFileHeader(path string, data []byte) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	p.printf("Classfile %s\n", path)
+	if info, err := os.Stat(path); err == nil {
+		p.printf("  Last modified %s; size %d bytes\n", info.ModTime().Format("Jan 2, 2006"), len(data))
+	}
+	p.printf("  SHA-256 checksum %x\n", sha256.Sum256(data))
+	if sourceFile := p.classFile.SourceFile(); sourceFile != "" {
+		p.printf("  Compiled from \"%s\"\n", sourceFile)
+	}
+}
+
+func (p *javap) printClass() {
+	cf := p.classFile
+	if !p.opts.verbose {
+		if sourceFile := p.classFile.SourceFile(); sourceFile != "" {
+			p.printf("Compiled from \"%s\"\n", sourceFile)
+		}
+	}
+	p.printf("%s", p.classDeclaration())
+	if p.opts.verbose {
+		p.printf("\n")
+		p.printf("  minor version: %d\n", cf.Minor)
+		p.printf("  major version: %d\n", cf.Major)
+		p.printf("  flags: (0x%04x) %s\n", cf.AccessFlags, strings.Join(cf.AccessFlags.Flags(), ", "))
+		p.printf("  %-38s// %s\n", "this_class: " + p.index(cf.ThisClass), className(cf.ThisClass))
+		if cf.SuperClass != nil {
+			p.printf("  %-38s// %s\n", "super_class: " + p.index(cf.SuperClass), className(cf.SuperClass))
+		} else {
+			p.printf("  super_class: #0\n")
+		}
+		p.printf("  interfaces: %d, fields: %d, methods: %d, attributes: %d\n",
+			len(cf.Interfaces), len(cf.Fields), len(cf.Methods), len(cf.Attrs))
+		p.printConstantPool()
+		p.printf("{\n")
+	} else {
+		p.printf(" {\n")
+	}
+	first := true
+	for _, field := range cf.Fields {
+		if !p.visible(field.AccessFlags & class.FieldAccPrivate != 0) {
+			continue
+		}
+		if p.opts.verbose && !first {
+			p.printf("\n")
+		}
+		first = false
+		p.printField(field)
+	}
+	for _, method := range cf.Methods {
+		if !p.visible(method.AccessFlags & class.MethodAccPrivate != 0) {
+			continue
+		}
+		if (p.opts.verbose || p.opts.code) && !first {
+			p.printf("\n")
+		}
+		first = false
+		p.printMethod(method)
+	}
+	p.printf("}\n")
+	if p.opts.verbose {
+		for _, attr := range cf.Attrs {
+			p.printAttr(attr, "")
+		}
+	}
+}
+
+func (p *javap) visible(private bool) bool {
+	return p.opts.private || !private
+}
+
+func (p *javap) classDeclaration() string {
+	cf := p.classFile
+	var s []string
+	flags := cf.AccessFlags
+	isInterface := flags & class.ACCINTERFACE != 0
+	if flags & class.ACCPUBLIC != 0 {
+		s = append(s, "public")
+	}
+	if flags & class.ACCFINAL != 0 {
+		s = append(s, "final")
+	}
+	if flags & class.ACCABSTRACT != 0 && !isInterface {
+		s = append(s, "abstract")
+	}
+	switch {
+	case cf.AccessFlags & class.ACCMODULE != 0:
+		s = append(s, "module")
+	case cf.AccessFlags & class.ACCANNOTATION != 0:
+		s = append(s, "@interface")
+	case isInterface:
+		s = append(s, "interface")
+	case cf.AccessFlags & class.ACCENUM != 0:
+		s = append(s, "enum")
+	default:
+		s = append(s, "class")
+	}
+	name := javaClassName(cf.Name())
+	var superName string
+	if cf.SuperClass != nil {
+		superName = javaClassName(cf.SuperName())
+	}
+	var interfaces []string
+	for _, inter := range cf.Interfaces {
+		interfaces = append(interfaces, javaClassName(className(inter)))
+	}
+	// 有泛型签名时显示泛型信息
+	if signature := findSignature(cf.Attrs); signature != nil {
+		if cs, err := signature.ClassSignature(); err == nil {
+			name += cs.TypeParams.JavaName()
+			superName = cs.Super.JavaName()
+			interfaces = interfaces[:0]
+			for _, inter := range cs.Interfaces {
+				interfaces = append(interfaces, inter.JavaName())
+			}
+		}
+	}
+	s = append(s, name)
+	if isInterface {
+		if len(interfaces) > 0 {
+			s = append(s, "extends", strings.Join(interfaces, ", "))
+		}
+		return strings.Join(s, " ")
+	}
+	if superName != "" && superName != "java.lang.Object" {
+		s = append(s, "extends", superName)
+	}
+	if len(interfaces) > 0 {
+		s = append(s, "implements", strings.Join(interfaces, ", "))
+	}
+	return strings.Join(s, " ")
+}
+
+func findSignature(attrs []class.Attr) *class.AttrSignature {
+	for _, attr := range attrs {
+		if signature, ok := attr.(*class.AttrSignature); ok {
+			return signature
+		}
+	}
+	return nil
+}
+
+// 字段的类型，有泛型签名时使用签名
+func fieldTypeName(descriptor *class.ConstUTF8, attrs []class.Attr) string {
+	if signature := findSignature(attrs); signature != nil {
+		if t, err := signature.FieldSignature(); err == nil {
+			return t.JavaName()
+		}
+	}
+	return javaTypeName(utf8String(descriptor))
+}
+
+func (p *javap) printConstantPool() {
+	p.printf("Constant pool:\n")
+	width := len(fmt.Sprintf("#%d", p.pool.Length() - 1))
+	for i := 1; i < p.pool.Length(); i++ {
+		c, _ := p.pool.Get(uint16(i))
+		if c == nil {
+			continue
+		}
+		kind, args, comment := p.describeConstant(c)
+		index := fmt.Sprintf("#%d", i)
+		if comment == "" {
+			p.printf("%*s = %-18s %s\n", width + 2, index, kind, args)
+		} else {
+			p.printf("%*s = %-18s %-14s // %s\n", width + 2, index, kind, args, comment)
+		}
+	}
+}
+
+// 返回常量类型名、引用的常量池索引和注释
+func (p *javap) describeConstant(c class.Constant) (string, string, string) {
+	kind := class.ConstantKindName(c.Tag())
+	switch c := c.(type) {
+	case *class.ConstUTF8:
+		return kind, c.String(), ""
+	case *class.ConstClass:
+		return kind, fmt.Sprintf("#%d", c.NameIndex), quoteName(utf8String(c.Name))
+	case *class.ConstString:
+		return kind, fmt.Sprintf("#%d", c.UTF8StringIndex), utf8String(c.UTF8String)
+	case *class.ConstModule:
+		return kind, fmt.Sprintf("#%d", c.NameIndex), utf8String(c.Name)
+	case *class.ConstPackage:
+		return kind, fmt.Sprintf("#%d", c.NameIndex), utf8String(c.Name)
+	case *class.ConstFieldRef:
+		return kind, fmt.Sprintf("#%d.#%d", c.ClassIndex, c.NameAndTypeIndex), refString(c)
+	case *class.ConstMethodRef:
+		return kind, fmt.Sprintf("#%d.#%d", c.ClassIndex, c.NameAndTypeIndex), refString(c)
+	case *class.ConstInterfaceMethodRef:
+		return kind, fmt.Sprintf("#%d.#%d", c.ClassIndex, c.NameAndTypeIndex), refString(c)
+	case *class.ConstNameAndType:
+		return kind, fmt.Sprintf("#%d:#%d", c.NameIndex, c.DescriptorIndex), nameAndTypeString(c)
+	case *class.ConstMethodHandle:
+		return kind, fmt.Sprintf("%d:#%d", c.RefKind, c.RefIndex), methodHandleString(c)
+	case *class.ConstMethodType:
+		return kind, fmt.Sprintf("#%d", c.DescriptorIndex), utf8String(c.Descriptor)
+	case *class.ConstDynamic:
+		return kind, fmt.Sprintf("#%d:#%d", c.BootstrapMethodAttrIndex, c.NameAndTypeIndex),
+			fmt.Sprintf("#%d:%s", c.BootstrapMethodAttrIndex, nameAndTypeString(c.NameAndType))
+	case *class.ConstInvokeDynamic:
+		return kind, fmt.Sprintf("#%d:#%d", c.BootstrapMethodAttrIndex, c.NameAndTypeIndex),
+			fmt.Sprintf("#%d:%s", c.BootstrapMethodAttrIndex, nameAndTypeString(c.NameAndType))
+	}
+	return kind, constantValue(c), ""
+}
+
+// 数值常量按 javap 的格式输出
+func constantValue(c class.Constant) string {
+	switch c := c.(type) {
+	case *class.ConstInteger:
+		return fmt.Sprintf("%d", c.Val)
+	case *class.ConstFloat:
+		return fmt.Sprintf("%vf", javaFloat(float64(c.Val), 32))
+	case *class.ConstLong:
+		return fmt.Sprintf("%dl", c.Val)
+	case *class.ConstDouble:
+		return fmt.Sprintf("%vd", javaFloat(c.Val, 64))
+	case *class.ConstString:
+		return utf8String(c.UTF8String)
+	}
+	return c.String()
+}
+
+func javaFloat(v float64, bitSize int) string {
+	s := fmt.Sprint(v)
+	if bitSize == 32 {
+		s = fmt.Sprint(float32(v))
+	}
+	if !strings.ContainsAny(s, ".eEIN") {
+		s += ".0"
+	}
+	return s
+}
+
+func quoteName(name string) string {
+	if strings.HasPrefix(name, "[") {
+		return "\"" + name + "\""
+	}
+	return name
+}
+
+func nameAndTypeString(nat *class.ConstNameAndType) string {
+	if nat == nil {
+		return ""
+	}
+	name := utf8String(nat.Name)
+	if name == "<init>" || name == "<clinit>" {
+		name = "\"" + name + "\""
+	}
+	return name + ":" + utf8String(nat.Descriptor)
+}
+
+func refString(ref class.ConstRef) string {
+	return quoteName(className(ref.GetClass())) + "." + nameAndTypeString(ref.GetNameAndType())
+}
+
+func methodHandleString(mh *class.ConstMethodHandle) string {
+	kind := class.RefKindName(mh.RefKind)
+	if mh.Ref == nil {
+		return kind
+	}
+	return kind + " " + refString(mh.Ref)
+}
+
+// 指令注释中常量的描述，例如 Method java/lang/Object."<init>":()V
+func constantComment(c class.Constant) string {
+	switch c := c.(type) {
+	case nil:
+		// 常量池索引为0
+		return ""
+	case *class.ConstFieldRef:
+		return "Field " + refString(c)
+	case *class.ConstMethodRef:
+		return "Method " + refString(c)
+	case *class.ConstInterfaceMethodRef:
+		return "InterfaceMethod " + refString(c)
+	case *class.ConstClass:
+		return "class " + quoteName(utf8String(c.Name))
+	case *class.ConstString:
+		return "String " + utf8String(c.UTF8String)
+	case *class.ConstInteger:
+		return "int " + constantValue(c)
+	case *class.ConstFloat:
+		return "float " + constantValue(c)
+	case *class.ConstLong:
+		return "long " + constantValue(c)
+	case *class.ConstDouble:
+		return "double " + constantValue(c)
+	case *class.ConstMethodType:
+		return "MethodType " + utf8String(c.Descriptor)
+	case *class.ConstMethodHandle:
+		return "MethodHandle " + methodHandleString(c)
+	case *class.ConstDynamic:
+		return fmt.Sprintf("Dynamic #%d:%s", c.BootstrapMethodAttrIndex, nameAndTypeString(c.NameAndType))
+	case *class.ConstInvokeDynamic:
+		return fmt.Sprintf("InvokeDynamic #%d:%s", c.BootstrapMethodAttrIndex, nameAndTypeString(c.NameAndType))
+	}
+	return c.String()
+}
+
+func (p *javap) printField(field *class.Field) {
+	var s []string
+	if modifiers := field.AccessFlags.String(); modifiers != "" {
+		s = append(s, modifiers)
+	}
+	s = append(s, fieldTypeName(field.Descriptor, field.Attrs), utf8String(field.Name))
+	p.printf("  %s;\n", strings.Join(s, " "))
+	if !p.opts.verbose {
+		return
+	}
+	p.printf("    descriptor: %s\n", utf8String(field.Descriptor))
+	p.printf("    flags: (0x%04x) %s\n", field.AccessFlags, strings.Join(field.AccessFlags.Flags(), ", "))
+	for _, attr := range field.Attrs {
+		p.printAttr(attr, "    ")
+	}
+}
+
+func (p *javap) methodDeclaration(method *class.Method) string {
+	name := utf8String(method.Name)
+	if name == "<clinit>" {
+		return "static {}"
+	}
+	var s []string
+	flags := method.AccessFlags
+	if p.classFile.AccessFlags & class.ACCINTERFACE != 0 {
+		// 接口方法省略隐含的 abstract，非 abstract 的实例方法为 default 方法
+		if flags & (class.MethodAccStatic | class.MethodAccPrivate | class.MethodAccAbstract) == 0 {
+			s = append(s, "default")
+		}
+		flags &^= class.MethodAccAbstract
+	}
+	if modifiers := flags.String(); modifiers != "" {
+		s = append([]string{modifiers}, s...)
+	}
+	params, ret := javaMethodTypes(utf8String(method.Descriptor))
+	var throws []string
+	for _, ex := range method.Exceptions() {
+		throws = append(throws, javaClassName(className(ex)))
+	}
+	// 有泛型签名时显示泛型信息
+	if signature := findSignature(method.Attrs); signature != nil {
+		if ms, err := signature.MethodSignature(); err == nil {
+			if typeParams := ms.TypeParams.JavaName(); typeParams != "" {
+				s = append(s, typeParams)
+			}
+			params = params[:0]
+			for _, param := range ms.Params {
+				params = append(params, param.JavaName())
+			}
+			ret = ms.Return.JavaName()
+			if len(ms.Throws) > 0 {
+				throws = throws[:0]
+				for _, t := range ms.Throws {
+					throws = append(throws, t.JavaName())
+				}
+			}
+		}
+	}
+	if name == "<init>" {
+		name = javaClassName(p.classFile.Name())
+	} else {
+		s = append(s, ret)
+	}
+	decl := strings.Join(append(s, name), " ")
+	if flags & class.MethodAccVarargs != 0 && len(params) > 0 {
+		last := params[len(params) - 1]
+		params[len(params) - 1] = strings.TrimSuffix(last, "[]") + "..."
+	}
+	decl += "(" + strings.Join(params, ", ") + ")"
+	if len(throws) > 0 {
+		decl += " throws " + strings.Join(throws, ", ")
+	}
+	return decl
+}
+
+func (p *javap) printMethod(method *class.Method) {
+	p.printf("  %s;\n", p.methodDeclaration(method))
+	if p.opts.verbose {
+		p.printf("    descriptor: %s\n", utf8String(method.Descriptor))
+		p.printf("    flags: (0x%04x) %s\n", method.AccessFlags, strings.Join(method.AccessFlags.Flags(), ", "))
+	}
+	for _, attr := range method.Attrs {
+		switch a := attr.(type) {
+		case *class.AttrCode:
+			p.printCode(method, a)
+		default:
+			if p.opts.verbose {
+				p.printAttr(attr, "    ")
+			}
+		}
+	}
+}
+
+func (p *javap) printCode(method *class.Method, code *class.AttrCode) {
+	if p.opts.code {
+		p.printf("    Code:\n")
+		if p.opts.verbose {
+			params, _ := javaMethodTypes(utf8String(method.Descriptor))
+			argsSize := 0
+			for _, param := range params {
+				argsSize++
+				if param == "long" || param == "double" {
+					argsSize++
+				}
+			}
+			if method.AccessFlags & class.MethodAccStatic == 0 {
+				argsSize++
+			}
+			p.printf("      stack=%d, locals=%d, args_size=%d\n", code.MaxStack, code.MaxLocals, argsSize)
+		}
+		p.printInstructions(code)
+		if len(code.ExceptionTable) > 0 {
+			p.printf("      Exception table:\n")
+			p.printf("         from    to  target type\n")
+			for _, ex := range code.ExceptionTable {
+				catchType := "any"
+				if ex.CatchType != nil {
+					catchType = "Class " + className(ex.CatchType)
+				}
+				p.printf("         %5d %5d %5d   %s\n", ex.StartPC, ex.EndPC, ex.HandlerPC, catchType)
+			}
+		}
+	}
+	for _, attr := range code.Attrs {
+		switch attr.(type) {
+		case *class.AttrLineNumberTable, *class.AttrLocalVariableTable, *class.AttrLocalVariableTypeTable:
+			if p.opts.lines {
+				p.printAttr(attr, "      ")
+			}
+		default:
+			if p.opts.verbose {
+				p.printAttr(attr, "      ")
+			}
+		}
+	}
+}
+
+var arrayTypeNames = map[int32]string{
+	4: "boolean",
+	5: "char",
+	6: "float",
+	7: "double",
+	8: "byte",
+	9: "short",
+	10: "int",
+	11: "long",
+}
+
+func (p *javap) printInstructions(code *class.AttrCode) {
+	instructions, err := class.DecodeInstructions(code.Code, p.pool)
+	if err != nil {
+		p.printf("      // %s\n", err)
+		return
+	}
+	for _, ins := range instructions {
+		mnemonic := ins.Mnemonic()
+		if ins.Wide {
+			mnemonic += "_w"
+		}
+		if len(ins.Operands) == 0 && ins.Switch == nil {
+			p.printf("      %4d: %s\n", ins.PC, mnemonic)
+			continue
+		}
+		var operands string
+		switch ins.Opcode {
+		case class.OpTableswitch, class.OpLookupswitch:
+			s := ins.Switch
+			if ins.Opcode == class.OpTableswitch {
+				p.printf("      %4d: %-13s { // %d to %d\n", ins.PC, mnemonic, s.Low, s.High)
+			} else {
+				p.printf("      %4d: %-13s { // %d\n", ins.PC, mnemonic, len(s.Keys))
+			}
+			for i, key := range s.Keys {
+				p.printf("      %24d: %d\n", key, ins.PC + int(s.Offsets[i]))
+			}
+			p.printf("      %24s: %d\n", "default", ins.PC + int(s.Default))
+			p.printf("            }\n")
+			continue
+		case class.OpNewarray:
+			operands = arrayTypeNames[ins.Operands[0]]
+		case class.OpIinc:
+			operands = fmt.Sprintf("%d, %d", ins.Operands[0], ins.Operands[1])
+		case class.OpInvokeinterface, class.OpInvokedynamic, class.OpMultianewarray:
+			operands = fmt.Sprintf("#%d,  %d", ins.Operands[0], ins.Operands[1])
+		default:
+			if targets := ins.Targets(); targets != nil {
+				operands = fmt.Sprintf("%d", targets[0])
+			} else if ins.Const != nil {
+				operands = fmt.Sprintf("#%d", ins.Operands[0])
+			} else {
+				operands = fmt.Sprintf("%d", ins.Operands[0])
+			}
+		}
+		if ins.Const != nil {
+			p.printf("      %4d: %-13s %-18s // %s\n", ins.PC, mnemonic, operands, constantComment(ins.Const))
+		} else {
+			p.printf("      %4d: %-13s %s\n", ins.PC, mnemonic, operands)
+		}
+	}
+}
+
+func (p *javap) printAttr(attr class.Attr, indent string) {
+	switch a := attr.(type) {
+	case *class.AttrSourceFile:
+		p.printf("%sSourceFile: \"%s\"\n", indent, utf8String(a.SourceFile))
+	case *class.AttrSignature:
+		p.printf("%s%-38s// %s\n", indent, "Signature: " + p.index(a.Signature), utf8String(a.Signature))
+	case *class.AttrConstantValue:
+		p.printf("%sConstantValue: %s\n", indent, constantComment(a.Val))
+	case *class.AttrDeprecated:
+		p.printf("%sDeprecated: true\n", indent)
+	case *class.AttrSynthetic:
+		p.printf("%sSynthetic: true\n", indent)
+	case *class.AttrExceptions:
+		p.printf("%sExceptions:\n", indent)
+		var names []string
+		for _, ex := range a.ExceptionTable {
+			names = append(names, javaClassName(className(ex)))
+		}
+		p.printf("%s  throws %s\n", indent, strings.Join(names, ", "))
+	case *class.AttrLineNumberTable:
+		p.printf("%sLineNumberTable:\n", indent)
+		for _, entry := range a.LineNumberTable {
+			p.printf("%s  line %d: %d\n", indent, entry.LineNumber, entry.StartPC)
+		}
+	case *class.AttrLocalVariableTable:
+		p.printf("%sLocalVariableTable:\n", indent)
+		p.printf("%s  Start  Length  Slot  Name   Signature\n", indent)
+		for _, entry := range a.LocalVarTable {
+			p.printf("%s  %5d  %6d  %4d %5s   %s\n", indent, entry.StartPC, entry.Length, entry.Index, utf8String(entry.Name), utf8String(entry.Descriptor))
+		}
+	case *class.AttrLocalVariableTypeTable:
+		p.printf("%sLocalVariableTypeTable:\n", indent)
+		p.printf("%s  Start  Length  Slot  Name   Signature\n", indent)
+		for _, entry := range a.LocalVarTypeTable {
+			p.printf("%s  %5d  %6d  %4d %5s   %s\n", indent, entry.StartPC, entry.Length, entry.Index, utf8String(entry.Name), utf8String(entry.Signature))
+		}
+	case *class.AttrStackMapTable:
+		p.printStackMapTable(a, indent)
+	case *class.AttrInnerClasses:
+		p.printf("%sInnerClasses:\n", indent)
+		for _, c := range a.Classes {
+			p.printf("%s  %s\n", indent, p.innerClassString(c))
+		}
+	case *class.AttrEnclosingMethod:
+		method := ""
+		if a.Method != nil {
+			method = "." + utf8String(a.Method.Name)
+		}
+		p.printf("%sEnclosingMethod: %s%s\n", indent, className(a.Class), method)
+	case *class.AttrBootstrapMethods:
+		p.printf("%sBootstrapMethods:\n", indent)
+		for i, bsm := range a.BootstrapMethods {
+			p.printf("%s  %d: %s %s\n", indent, i, p.index(bsm.BootstrapMethodRef), methodHandleString(bsm.BootstrapMethodRef))
+			p.printf("%s    Method arguments:\n", indent)
+			for _, arg := range bsm.BootstrapArguments {
+				p.printf("%s      %s %s\n", indent, p.index(arg), constantComment(arg))
+			}
+		}
+	case *class.AttrRuntimeVisibleAnnotations:
+		p.printAnnotations("RuntimeVisibleAnnotations", a.Annotations, indent)
+	case *class.AttrRuntimeInvisibleAnnotations:
+		p.printAnnotations("RuntimeInvisibleAnnotations", a.Annotations, indent)
+	case *class.AttrRuntimeVisibleParameterAnnotations:
+		p.printParameterAnnotations("RuntimeVisibleParameterAnnotations", a.ParameterAnnotations, indent)
+	case *class.AttrRuntimeInvisibleParameterAnnotations:
+		p.printParameterAnnotations("RuntimeInvisibleParameterAnnotations", a.ParameterAnnotations, indent)
+	case *class.AttrRuntimeVisibleTypeAnnotations:
+		p.printTypeAnnotations("RuntimeVisibleTypeAnnotations", a.Annotations, indent)
+	case *class.AttrRuntimeInvisibleTypeAnnotations:
+		p.printTypeAnnotations("RuntimeInvisibleTypeAnnotations", a.Annotations, indent)
+	case *class.AttrAnnotationDefault:
+		p.printf("%sAnnotationDefault:\n", indent)
+		p.printf("%s  default_value: %s\n", indent, elementValString(a.DefaultVal))
+	case *class.AttrMethodParameters:
+		p.printf("%sMethodParameters:\n", indent)
+		p.printf("%s  Name                           Flags\n", indent)
+		for _, param := range a.Parameters {
+			name := "<no name>"
+			if param.Name != nil {
+				name = param.Name.String()
+			}
+			p.printf("%s  %-30s %s\n", indent, name, strings.Join(param.AccessFlags.Flags(), ", "))
+		}
+	case *class.AttrNestHost:
+		p.printf("%sNestHost: class %s\n", indent, className(a.HostClass))
+	case *class.AttrNestMembers:
+		p.printf("%sNestMembers:\n", indent)
+		for _, c := range a.Classes {
+			p.printf("%s  %s\n", indent, className(c))
+		}
+	case *class.AttrPermittedSubclasses:
+		p.printf("%sPermittedSubclasses:\n", indent)
+		for _, c := range a.Classes {
+			p.printf("%s  %s\n", indent, className(c))
+		}
+	case *class.AttrRecord:
+		p.printf("%sRecord:\n", indent)
+		for _, rc := range a.Components {
+			p.printf("%s  %s %s;\n", indent, fieldTypeName(rc.Descriptor, rc.Attrs), utf8String(rc.Name))
+			p.printf("%s    descriptor: %s\n", indent, utf8String(rc.Descriptor))
+			for _, attr := range rc.Attrs {
+				p.printAttr(attr, indent + "    ")
+			}
+		}
+	case *class.AttrModulePackages:
+		p.printf("%sModulePackages:\n", indent)
+		for _, pkg := range a.Packages {
+			name := ""
+			if pkg != nil {
+				name = utf8String(pkg.Name)
+			}
+			p.printf("%s  %s\n", indent, name)
+		}
+	case *class.AttrModuleMainClass:
+		p.printf("%sModuleMainClass: %s\n", indent, className(a.MainClass))
+	default:
+		p.printf("%s%s: %s\n", indent, attr.Name(), attr)
+	}
+}
+
+func (p *javap) innerClassString(c *class.Classes) string {
+	flags := c.InnerClassAccessFlags
+	// 和 javap 一样省略接口隐含的 abstract
+	if flags & class.InnerClassAccInterface != 0 {
+		flags &^= class.InnerClassAccAbstract
+	}
+	decl := flags.String()
+	if decl != "" {
+		decl += " "
+	}
+	comment := "class " + className(c.InnerClass)
+	if c.InnerName != nil {
+		decl += p.index(c.InnerName) + "= "
+		comment = c.InnerName.String() + "=" + comment
+	}
+	decl += p.index(c.InnerClass)
+	if c.OuterClass != nil {
+		decl += " of " + p.index(c.OuterClass)
+		comment += " of class " + className(c.OuterClass)
+	}
+	return fmt.Sprintf("%-38s// %s", decl + ";", comment)
+}
+
+var frameTypeNames = []struct {
+	min, max uint8
+	name string
+}{
+	{0, 63, "same"},
+	{64, 127, "same_locals_1_stack_item"},
+	{247, 247, "same_locals_1_stack_item_frame_extended"},
+	{248, 250, "chop"},
+	{251, 251, "same_frame_extended"},
+	{252, 254, "append"},
+	{255, 255, "full_frame"},
+}
+
+func (p *javap) printStackMapTable(smt *class.AttrStackMapTable, indent string) {
+	p.printf("%sStackMapTable: number_of_entries = %d\n", indent, len(smt.Entries))
+	for _, frame := range smt.Entries {
+		frameType := frame.FrameType()
+		name := ""
+		for _, n := range frameTypeNames {
+			if frameType >= n.min && frameType <= n.max {
+				name = n.name
+			}
+		}
+		p.printf("%s  frame_type = %d /* %s */\n", indent, frameType, name)
+		switch f := frame.(type) {
+		case *class.SameLocals1StackItemFrame:
+			p.printf("%s    stack = %s\n", indent, p.verificationTypes(f.Stack))
+		case *class.SameLocals1StackItemFrameExtended:
+			p.printf("%s    offset_delta = %d\n", indent, f.OffsetDelta)
+			p.printf("%s    stack = %s\n", indent, p.verificationTypes(f.Stack))
+		case *class.ChopFrame:
+			p.printf("%s    offset_delta = %d\n", indent, f.OffsetDelta)
+		case *class.SameFrameExtended:
+			p.printf("%s    offset_delta = %d\n", indent, f.OffsetDelta)
+		case *class.AppendFrame:
+			p.printf("%s    offset_delta = %d\n", indent, f.OffsetDelta)
+			p.printf("%s    locals = %s\n", indent, p.verificationTypes(f.Locals))
+		case *class.FullFrame:
+			p.printf("%s    offset_delta = %d\n", indent, f.OffsetDelta)
+			p.printf("%s    locals = %s\n", indent, p.verificationTypes(f.Locals))
+			p.printf("%s    stack = %s\n", indent, p.verificationTypes(f.Stack))
+		}
+	}
+}
+
+func (p *javap) verificationTypes(types []class.VerificationType) string {
+	var s []string
+	for _, vt := range types {
+		switch v := vt.(type) {
+		case *class.TopVariable:
+			s = append(s, "top")
+		case *class.IntegerVariable:
+			s = append(s, "int")
+		case *class.FloatVariable:
+			s = append(s, "float")
+		case *class.LongVariable:
+			s = append(s, "long")
+		case *class.DoubleVariable:
+			s = append(s, "double")
+		case *class.NullVariable:
+			s = append(s, "null")
+		case *class.UninitializedThisVariable:
+			s = append(s, "this")
+		case *class.ObjectVariable:
+			s = append(s, "class " + quoteName(className(v.Class)))
+		case *class.UninitializedVariable:
+			s = append(s, fmt.Sprintf("uninitialized %d", v.Offset))
+		}
+	}
+	if len(s) == 0 {
+		return "[]"
+	}
+	return "[ " + strings.Join(s, ", ") + " ]"
+}
+
+func (p *javap) printAnnotations(name string, annotations []*class.Annotation, indent string) {
+	p.printf("%s%s:\n", indent, name)
+	for i, annotation := range annotations {
+		p.printf("%s  %d: %s\n", indent, i, annotationString(annotation.Type, annotation.ElementValPairs))
+	}
+}
+
+func (p *javap) printParameterAnnotations(name string, parameters []*class.ParameterAnnotation, indent string) {
+	p.printf("%s%s:\n", indent, name)
+	for i, parameter := range parameters {
+		p.printf("%s  parameter %d:\n", indent, i)
+		for j, annotation := range parameter.Annotations {
+			p.printf("%s    %d: %s\n", indent, j, annotationString(annotation.Type, annotation.ElementValPairs))
+		}
+	}
+}
+
+func (p *javap) printTypeAnnotations(name string, annotations []*class.TypeAnnotation, indent string) {
+	p.printf("%s%s:\n", indent, name)
+	for i, ta := range annotations {
+		p.printf("%s  %d: %s: %s\n", indent, i, annotationString(ta.Type, ta.ElementValPairs), typeAnnotationTarget(ta))
+	}
+}
+
+var targetTypeNames = map[uint8]string{
+	0x00: "CLASS_TYPE_PARAMETER",
+	0x01: "METHOD_TYPE_PARAMETER",
+	0x10: "CLASS_EXTENDS",
+	0x11: "CLASS_TYPE_PARAMETER_BOUND",
+	0x12: "METHOD_TYPE_PARAMETER_BOUND",
+	0x13: "FIELD",
+	0x14: "METHOD_RETURN",
+	0x15: "METHOD_RECEIVER",
+	0x16: "METHOD_FORMAL_PARAMETER",
+	0x17: "THROWS",
+	0x40: "LOCAL_VARIABLE",
+	0x41: "RESOURCE_VARIABLE",
+	0x42: "EXCEPTION_PARAMETER",
+	0x43: "INSTANCEOF",
+	0x44: "NEW",
+	0x45: "CONSTRUCTOR_REFERENCE",
+	0x46: "METHOD_REFERENCE",
+	0x47: "CAST",
+	0x48: "CONSTRUCTOR_INVOCATION_TYPE_ARGUMENT",
+	0x49: "METHOD_INVOCATION_TYPE_ARGUMENT",
+	0x4A: "CONSTRUCTOR_REFERENCE_TYPE_ARGUMENT",
+	0x4B: "METHOD_REFERENCE_TYPE_ARGUMENT",
+}
+
+var typePathKindNames = []string{"ARRAY", "INNER_TYPE", "WILDCARD", "TYPE_ARGUMENT"}
+
+// 和 javap 一样输出目标类型名、目标的各项以及类型路径，例如
+// LOCAL_VARIABLE, {start_pc=8, length=5, index=1}, location=[TYPE_ARGUMENT(0)]
+func typeAnnotationTarget(ta *class.TypeAnnotation) string {
+	name, ok := targetTypeNames[ta.TargetType]
+	if !ok {
+		name = fmt.Sprintf("0x%x", ta.TargetType)
+	}
+	s := []string{name}
+	switch t := ta.Target.(type) {
+	case *class.TypeParameterTarget:
+		s = append(s, fmt.Sprintf("param_index=%d", t.TypeParameterIndex))
+	case *class.SupertypeTarget:
+		// 65535 表示超类，javap 输出为 -1
+		s = append(s, fmt.Sprintf("type_index=%d", int16(t.SupertypeIndex)))
+	case *class.TypeParameterBoundTarget:
+		s = append(s, fmt.Sprintf("param_index=%d", t.TypeParameterIndex), fmt.Sprintf("bound_index=%d", t.BoundIndex))
+	case *class.FormalParameterTarget:
+		s = append(s, fmt.Sprintf("param_index=%d", t.FormalParameterIndex))
+	case *class.ThrowsTarget:
+		s = append(s, fmt.Sprintf("type_index=%d", t.ThrowsTypeIndex))
+	case *class.LocalvarTarget:
+		for _, entry := range t.Table {
+			s = append(s, fmt.Sprintf("{start_pc=%d, length=%d, index=%d}", entry.StartPC, entry.Length, entry.Index))
+		}
+	case *class.CatchTarget:
+		s = append(s, fmt.Sprintf("exception_index=%d", t.ExceptionTableIndex))
+	case *class.OffsetTarget:
+		s = append(s, fmt.Sprintf("offset=%d", t.Offset))
+	case *class.TypeArgumentTarget:
+		s = append(s, fmt.Sprintf("offset=%d", t.Offset), fmt.Sprintf("type_index=%d", t.TypeArgumentIndex))
+	}
+	if ta.TargetPath != nil && len(ta.TargetPath.Path) > 0 {
+		var location []string
+		for _, path := range ta.TargetPath.Path {
+			kind := fmt.Sprintf("%d", path.TypePathKind)
+			if int(path.TypePathKind) < len(typePathKindNames) {
+				kind = typePathKindNames[path.TypePathKind]
+			}
+			if path.TypePathKind == 3 {
+				kind += fmt.Sprintf("(%d)", path.TypeArgumentIndex)
+			}
+			location = append(location, kind)
+		}
+		s = append(s, "location=[" + strings.Join(location, ", ") + "]")
+	}
+	return strings.Join(s, ", ")
+}
+
+func annotationString(typ *class.ConstUTF8, pairs []*class.ElementValPair) string {
+	var s []string
+	for _, pair := range pairs {
+		s = append(s, utf8String(pair.ElementName) + "=" + elementValString(pair.Val))
+	}
+	return javaTypeName(utf8String(typ)) + "(" + strings.Join(s, ",") + ")"
+}
+
+func elementValString(val class.ElementVal) string {
+	switch v := val.(type) {
+	case *class.ElementValByte:
+		return fmt.Sprintf("(byte)%d", intConst(v.Val))
+	case *class.ElementValChar:
+		return fmt.Sprintf("'%c'", rune(intConst(v.Val)))
+	case *class.ElementValDouble:
+		if v.Val != nil {
+			return constantValue(v.Val)
+		}
+	case *class.ElementValFloat:
+		if v.Val != nil {
+			return constantValue(v.Val)
+		}
+	case *class.ElementValInt:
+		return fmt.Sprint(intConst(v.Val))
+	case *class.ElementValLong:
+		if v.Val != nil {
+			return constantValue(v.Val)
+		}
+	case *class.ElementValShort:
+		return fmt.Sprintf("(short)%d", intConst(v.Val))
+	case *class.ElementValBoolean:
+		return fmt.Sprint(intConst(v.Val) != 0)
+	case *class.ElementValString:
+		return fmt.Sprintf("%q", utf8String(v.Val))
+	case *class.ElementValEnum:
+		return javaTypeName(utf8String(v.TypeName)) + "." + utf8String(v.ConstName)
+	case *class.ElementValClass:
+		return javaTypeName(utf8String(v.ClassInfo)) + ".class"
+	case *class.ElementValAnnotation:
+		return "@" + annotationString(v.AnnotationVal.Type, v.AnnotationVal.ElementValPairs)
+	case *class.ElementValArray:
+		var s []string
+		for _, ev := range v.Val {
+			s = append(s, elementValString(ev))
+		}
+		return "[" + strings.Join(s, ",") + "]"
+	default:
+		return val.String()
+	}
+	// const_value_index 为0
+	return ""
+}
+
+// 索引为0的整数常量按0输出
+func intConst(c *class.ConstInteger) int32 {
+	if c == nil {
+		return 0
+	}
+	return c.Val
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"testing"
+	"bytes"
+	"strings"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+func TestJavap(t *testing.T) {
+	path, data, err := findClassFile("ArrayList", "../class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	classFile, err := class.NewClassFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	p := &javap{out: out, opts: &javapOptions{code: true, verbose: true, private: true, lines: true},
+		classFile: classFile, pool: classFile.ConstantPool}
+	p.printFileHeader(path, data)
+	p.printClass()
+	expects := []string{
+		"public class java.util.ArrayList<E> extends java.util.AbstractList<E> implements java.util.List<E>, java.util.RandomAccess, java.lang.Cloneable, java.io.Serializable",
+		"   #3 = Methodref          #81.#217       // java/util/AbstractList.\"<init>\":()V",
+		"  public java.util.ArrayList(java.util.Collection<? extends E>);",
+		"  public <T> T[] toArray(T[]);",
+		"         6: invokeinterface #16,  1            // InterfaceMethod java/util/Collection.toArray:()[Ljava/lang/Object;",
+		"    ConstantValue: long 8683452581122892189l",
+		"          locals = [ class java/util/ArrayList, int ]",
+		"  private void writeObject(java.io.ObjectOutputStream) throws java.io.IOException;",
+		"SourceFile: \"ArrayList.java\"",
+	}
+	for _, expect := range expects {
+		if !strings.Contains(out.String(), expect + "\n") {
+			t.Errorf("missing line %q", expect)
+		}
+	}
+}
+
+// 常量池索引为0的项在 ClassFile 中为nil，反汇编时不能出现空指针
+func TestJavapZeroIndexes(t *testing.T) {
+	pool := class.NewEmptyConstantPool()
+	classFile := &class.ClassFile{
+		Major: 52,
+		ConstantPool: pool,
+		Interfaces: []*class.ConstClass{nil},
+		Fields: []*class.Field{{Attrs: []class.Attr{&class.AttrConstantValue{}, &class.AttrSignature{}}}},
+		Methods: []*class.Method{{Attrs: []class.Attr{
+			&class.AttrExceptions{ExceptionTable: []*class.ConstClass{nil}},
+			&class.AttrCode{ExceptionTable: []*class.Exception{{}}, Attrs: []class.Attr{
+				&class.AttrLocalVariableTable{LocalVarTable: []*class.LocalVarTableEntry{{}}},
+			}},
+		}}},
+		Attrs: []class.Attr{
+			&class.AttrSourceFile{},
+			&class.AttrSignature{},
+			&class.AttrInnerClasses{Classes: []*class.Classes{{}}},
+			&class.AttrEnclosingMethod{},
+			&class.AttrNestHost{},
+			&class.AttrModulePackages{Packages: []*class.ConstPackage{nil}},
+			&class.AttrRuntimeVisibleAnnotations{Annotations: []*class.Annotation{{ElementValPairs: []*class.ElementValPair{
+				{Val: &class.ElementValInt{}},
+				{Val: &class.ElementValLong{}},
+				{Val: &class.ElementValChar{}},
+				{Val: &class.ElementValString{}},
+				{Val: &class.ElementValEnum{}},
+			}}}},
+		},
+	}
+	out := &bytes.Buffer{}
+	p := &javap{out: out, opts: &javapOptions{code: true, verbose: true, private: true, lines: true},
+		classFile: classFile, pool: pool}
+	p.printClass()
+	if !strings.Contains(out.String(), "NestHost: class \n") {
+		t.Errorf("unexpected output\n%s", out)
+	}
+}
+
+func TestJavapTypeAnnotations(t *testing.T) {
+	pool := class.NewEmptyConstantPool()
+	i, _ := pool.AddUTF8("LNonNull;")
+	nonNull, _ := pool.GetUTF8String(i)
+	annotations := []*class.TypeAnnotation{
+		{TargetType: 0x13, Target: &class.EmptyTarget{}, TargetPath: &class.TypePath{}, Type: nonNull},
+		{TargetType: 0x10, Target: &class.SupertypeTarget{SupertypeIndex: 65535}, TargetPath: &class.TypePath{}, Type: nonNull},
+		{TargetType: 0x40, Target: &class.LocalvarTarget{Table: []*class.Table{{StartPC: 8, Length: 5, Index: 1}}},
+			TargetPath: &class.TypePath{Path: []*class.Path{{TypePathKind: 3, TypeArgumentIndex: 0}, {TypePathKind: 0}}}, Type: nonNull},
+		{TargetType: 0x47, Target: &class.TypeArgumentTarget{Offset: 12, TypeArgumentIndex: 1}, TargetPath: &class.TypePath{}, Type: nonNull},
+	}
+	out := &bytes.Buffer{}
+	p := &javap{out: out, opts: &javapOptions{}, pool: pool}
+	p.printTypeAnnotations("RuntimeVisibleTypeAnnotations", annotations, "")
+	expected := "RuntimeVisibleTypeAnnotations:\n" +
+		"  0: NonNull(): FIELD\n" +
+		"  1: NonNull(): CLASS_EXTENDS, type_index=-1\n" +
+		"  2: NonNull(): LOCAL_VARIABLE, {start_pc=8, length=5, index=1}, location=[TYPE_ARGUMENT(0), ARRAY]\n" +
+		"  3: NonNull(): CAST, offset=12, type_index=1\n"
+	if out.String() != expected {
+		t.Errorf("unexpected output\n%s\nexpected\n%s", out, expected)
+	}
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"strings"
+	"github.com/yuya008/jvm4go/class"
+)
+
+// java/util/ArrayList -> java.util.ArrayList
+func javaClassName(name string) string {
+	return strings.Replace(name, "/", ".", -1)
+}
+
+// [Ljava/lang/String; -> java.lang.String[]，描述符无效时原样返回
+func javaTypeName(desc string) string {
+	t, err := class.ParseFieldDescriptor(desc)
+	if err != nil {
+		return desc
+	}
+	return t.JavaName()
+}
+
+// (ILjava/lang/String;)V -> [int java.lang.String], void
+func javaMethodTypes(desc string) ([]string, string) {
+	m, err := class.ParseMethodDescriptor(desc)
+	if err != nil {
+		return nil, desc
+	}
+	var params []string
+	for _, param := range m.Params {
+		params = append(params, param.JavaName())
+	}
+	return params, m.Return.JavaName()
+}
+
+// 可能为0的常量池索引对应的常量为nil，输出为空字符串
+func utf8String(s *class.ConstUTF8) string {
+	if s == nil {
+		return ""
+	}
+	return s.String()
+}
+
+func className(c *class.ConstClass) string {
+	if c == nil {
+		return ""
+	}
+	return utf8String(c.Name)
+}
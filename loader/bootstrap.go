@@ -0,0 +1,51 @@
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// JDK 8 引导类路径中的 jar，顺序与 sun.boot.class.path 相同
+var jdk8BootJars = []string{
+	"resources.jar",
+	"rt.jar",
+	"jsse.jar",
+	"jce.jar",
+	"charsets.jar",
+	"jfr.jar",
+}
+
+// 根据 javaHome 构造引导类路径
+// JDK 9 及以后使用 lib/modules，JDK 8 使用 jre/lib 下的 rt.jar 等，javaHome 也可以直接是 JRE 目录
+// appendPath 为 -Xbootclasspath/a: 指定的类路径，追加在 JDK 的类之后
+func NewBootClassPath(javaHome, appendPath string) (*ClassPath, error) {
+	cp := &ClassPath{}
+	if modules := filepath.Join(javaHome, "lib", "modules"); isFile(modules) {
+		cp.Add(NewJImageEntry(modules))
+	} else {
+		lib := filepath.Join(javaHome, "jre", "lib")
+		if !isFile(filepath.Join(lib, "rt.jar")) {
+			lib = filepath.Join(javaHome, "lib")
+		}
+		if !isFile(filepath.Join(lib, "rt.jar")) {
+			return nil, fmt.Errorf("no lib/modules or rt.jar found in %s", javaHome)
+		}
+		for _, jar := range jdk8BootJars {
+			if path := filepath.Join(lib, jar); isFile(path) {
+				cp.Add(NewZipEntry(path))
+			}
+		}
+	}
+	appended, err := NewClassPath(appendPath)
+	if err != nil {
+		return nil, err
+	}
+	cp.Add(appended.Entries()...)
+	return cp, nil
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
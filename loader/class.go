@@ -0,0 +1,83 @@
+package loader
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+// 运行时类，由类名和定义类加载器唯一确定 (JVMS 5.3)
+// 不同的类加载器可以定义同名的不同的类
+type Class struct {
+	// 内部形式的类名，例如 java/lang/Object，数组类为描述符，例如 [Ljava/lang/String;
+	Name string
+	// 数组类为nil
+	ClassFile *class.ClassFile
+	// 定义类加载器
+	Loader *ClassLoader
+	Package *Package
+	// java/lang/Object 和接口的超类分别为nil和 java/lang/Object
+	SuperClass *Class
+	Interfaces []*Class
+	// 数组类的元素类型，基本类型的数组为nil
+	ComponentType *Class
+	// 准备阶段分配的静态字段，按class文件中的顺序
+	StaticFields []*StaticField
+
+	lock sync.Mutex
+	cond *sync.Cond
+	state ClassState
+	// 正在执行初始化的线程
+	initThread interface{}
+	// 链接失败的原因
+	linkErr error
+	// 链接或初始化失败的原因
+	err error
+}
+
+func newClass(name string, cf *class.ClassFile, loader *ClassLoader) *Class {
+	c := &Class{Name: name, ClassFile: cf, Loader: loader}
+	c.cond = sync.NewCond(&c.lock)
+	return c
+}
+
+type ClassKey struct {
+	Name string
+	Loader *ClassLoader
+}
+
+func (c *Class) Key() ClassKey {
+	return ClassKey{Name: c.Name, Loader: c.Loader}
+}
+
+func (c *Class) IsInterface() bool {
+	return c.ClassFile != nil && c.ClassFile.AccessFlags & class.ACCINTERFACE != 0
+}
+
+func (c *Class) IsArray() bool {
+	return strings.HasPrefix(c.Name, "[")
+}
+
+func (c *Class) String() string {
+	return fmt.Sprintf("%s (%s)", c.Name, c.Loader)
+}
+
+// 运行时包，由包名和定义类加载器唯一确定，只有同一运行时包中的类才能互相访问包级成员
+type Package struct {
+	// 内部形式的包名，例如 java/lang，默认包为空字符串
+	Name string
+	Loader *ClassLoader
+}
+
+func (p *Package) String() string {
+	return fmt.Sprintf("%s (%s)", p.Name, p.Loader)
+}
+
+func packageName(className string) string {
+	if i := strings.LastIndex(className, "/"); i >= 0 {
+		return className[:i]
+	}
+	return ""
+}
@@ -0,0 +1,431 @@
+package loader
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+// 用户自定义类加载器查找类的回调，对应 java.lang.ClassLoader.findClass
+// 通常读取字节后调用 loader.DefineClass，找不到时返回 ClassNotFoundException
+type ClassFinder func(loader *ClassLoader, name string) (*Class, error)
+
+type ClassLoader struct {
+	name string
+	// 引导类加载器的 parent 为nil
+	parent *ClassLoader
+	classPath *ClassPath
+	finder ClassFinder
+	// 引导和平台类加载器可以定义 java.* 包中的类
+	trusted bool
+	lock sync.Mutex
+	// 以该加载器为定义加载器的类
+	defined map[string]*Class
+	// 以该加载器为初始加载器的类，包括委托父加载器加载的类
+	initiated map[string]*Class
+	// 正在进行的 LoadClass，同名的并发请求等待其完成，保证每个类只加载一次
+	loading map[string]*loadCall
+	// 正在定义的类，重复定义时抛出 LinkageError
+	defining map[string]bool
+	packages map[string]*Package
+}
+
+func newClassLoader(name string, parent *ClassLoader, classPath *ClassPath, finder ClassFinder, trusted bool) *ClassLoader {
+	return &ClassLoader{
+		name: name,
+		parent: parent,
+		classPath: classPath,
+		finder: finder,
+		trusted: trusted,
+		defined: map[string]*Class{},
+		initiated: map[string]*Class{},
+		loading: map[string]*loadCall{},
+		defining: map[string]bool{},
+		packages: map[string]*Package{},
+	}
+}
+
+// 引导类加载器，从引导类路径加载 java.base 等核心类，参见 NewBootClassPath
+func NewBootstrapLoader(classPath *ClassPath) *ClassLoader {
+	return newClassLoader("bootstrap", nil, classPath, nil, true)
+}
+
+// 平台类加载器，classPath 可以为nil
+func NewPlatformLoader(parent *ClassLoader, classPath *ClassPath) *ClassLoader {
+	return newClassLoader("platform", parent, classPath, nil, true)
+}
+
+// 应用类加载器，从 -classpath 指定的类路径加载
+func NewAppLoader(parent *ClassLoader, classPath *ClassPath) *ClassLoader {
+	return newClassLoader("app", parent, classPath, nil, false)
+}
+
+// 用户自定义类加载器，父加载器找不到的类交给 finder 查找
+func NewClassLoader(name string, parent *ClassLoader, finder ClassFinder) *ClassLoader {
+	return newClassLoader(name, parent, nil, finder, false)
+}
+
+func (l *ClassLoader) Name() string {
+	return l.name
+}
+
+func (l *ClassLoader) Parent() *ClassLoader {
+	return l.parent
+}
+
+func (l *ClassLoader) String() string {
+	return l.name
+}
+
+// 以该加载器为初始加载器已经加载的类，没有时返回nil
+func (l *ClassLoader) FindLoadedClass(name string) *Class {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.initiated[name]
+}
+
+// 该加载器定义的运行时包，没有时返回nil
+func (l *ClassLoader) Package(name string) *Package {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.packages[name]
+}
+
+func (l *ClassLoader) Packages() []*Package {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	packages := make([]*Package, 0, len(l.packages))
+	for _, pkg := range l.packages {
+		packages = append(packages, pkg)
+	}
+	return packages
+}
+
+// 按父加载器优先的顺序加载类，name 为内部形式或 . 分隔的类名
+// 可以被多个 goroutine 并发调用，同一个类只会被加载和定义一次
+func (l *ClassLoader) LoadClass(name string) (*Class, error) {
+	return l.loadClass(strings.Replace(name, ".", "/", -1), &loadThread{})
+}
+
+func (l *ClassLoader) loadClass(name string, thread *loadThread) (*Class, error) {
+	if strings.HasPrefix(name, "[") {
+		return l.loadArrayClass(name, thread)
+	}
+	l.lock.Lock()
+	if c, ok := l.initiated[name]; ok {
+		l.lock.Unlock()
+		return c, nil
+	}
+	if call, ok := l.loading[name]; ok {
+		l.lock.Unlock()
+		return call.wait(name, thread)
+	}
+	call := &loadCall{thread: thread, done: make(chan struct{})}
+	l.loading[name] = call
+	l.lock.Unlock()
+
+	c, err := l.delegate(name, call)
+	l.lock.Lock()
+	delete(l.loading, name)
+	if err == nil {
+		// 记录为初始加载器，已经有记录时使用已有的类
+		if prev, ok := l.initiated[name]; ok {
+			c = prev
+		} else {
+			l.initiated[name] = c
+		}
+	}
+	l.lock.Unlock()
+	call.finish(c, err)
+	return c, err
+}
+
+func (l *ClassLoader) delegate(name string, call *loadCall) (*Class, error) {
+	if l.parent != nil {
+		c, err := l.parent.loadClass(name, call.thread)
+		if err == nil || !IsError(err, ClassNotFoundException) {
+			return c, err
+		}
+	}
+	return l.findClass(name, call)
+}
+
+func (l *ClassLoader) findClass(name string, call *loadCall) (*Class, error) {
+	if l.finder != nil {
+		// finder 中调用 DefineClass 时沿用当前的调用链
+		l.lock.Lock()
+		call.finding = true
+		l.lock.Unlock()
+		c, err := l.finder(l, name)
+		if err != nil {
+			return nil, err
+		}
+		if c.Name != name {
+			return nil, newError(NoClassDefFoundError, nil, "%s (wrong name: %s)", name, c.Name)
+		}
+		return c, nil
+	}
+	if l.classPath == nil {
+		return nil, newError(ClassNotFoundException, nil, "%s", name)
+	}
+	data, _, err := l.classPath.ReadClass(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, newError(ClassNotFoundException, err, "%s", name)
+		}
+		return nil, newError(NoClassDefFoundError, err, "%s", name)
+	}
+	return l.defineClass(name, data, call.thread)
+}
+
+// 数组类由虚拟机创建而不是从类路径加载 (JVMS 5.3.3)
+// 元素为引用类型时数组类的定义加载器为元素类型的定义加载器，否则为引导类加载器，该加载器成为初始加载器
+func (l *ClassLoader) loadArrayClass(name string, thread *loadThread) (*Class, error) {
+	if c := l.FindLoadedClass(name); c != nil {
+		return c, nil
+	}
+	component := name[1:]
+	var element *Class
+	var err error
+	switch {
+	case strings.HasPrefix(component, "["):
+		element, err = l.loadClass(component, thread)
+	case len(component) > 2 && component[0] == 'L' && component[len(component) - 1] == ';':
+		element, err = l.loadClass(component[1:len(component) - 1], thread)
+	case len(component) == 1 && strings.Contains("BCDFIJSZ", component):
+	default:
+		return nil, newError(ClassNotFoundException, nil, "%s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var definer *ClassLoader
+	if element != nil {
+		definer = element.Loader
+	} else {
+		object, err := l.loadClass("java/lang/Object", thread)
+		if err != nil {
+			return nil, err
+		}
+		definer = object.Loader
+	}
+	c, err := definer.defineArrayClass(name, element, thread)
+	if err != nil {
+		return nil, err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if prev, ok := l.initiated[name]; ok {
+		return prev, nil
+	}
+	l.initiated[name] = c
+	return c, nil
+}
+
+// 数组类继承 java/lang/Object 并实现 Cloneable 和 Serializable，创建后即为已初始化状态
+func (l *ClassLoader) defineArrayClass(name string, element *Class, thread *loadThread) (*Class, error) {
+	l.lock.Lock()
+	c, ok := l.defined[name]
+	l.lock.Unlock()
+	if ok {
+		return c, nil
+	}
+	var supers []*Class
+	for _, superName := range []string{"java/lang/Object", "java/lang/Cloneable", "java/io/Serializable"} {
+		super, err := l.loadSuper(name, superName, thread)
+		if err != nil {
+			return nil, err
+		}
+		supers = append(supers, super)
+	}
+	c = newClass(name, nil, l)
+	c.SuperClass, c.Interfaces, c.ComponentType = supers[0], supers[1:], element
+	// 基本类型数组与 java/lang/Object 在同一个包中
+	c.Package = supers[0].Package
+	if element != nil {
+		c.Package = element.Package
+	}
+	c.state = ClassInitialized
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if prev, ok := l.defined[name]; ok {
+		return prev, nil
+	}
+	l.defined[name] = c
+	l.initiated[name] = c
+	return c, nil
+}
+
+// 从class文件定义类，该加载器成为类的定义加载器 (JVMS 5.3.5)
+// name 为空时使用class文件中的类名，超类和接口会通过该加载器加载
+// 类已经定义或者正在被其他 goroutine 定义时返回 LinkageError
+func (l *ClassLoader) DefineClass(name string, data []byte) (*Class, error) {
+	return l.defineClass(name, data, nil)
+}
+
+// thread 为nil时是从外部直接定义类
+func (l *ClassLoader) defineClass(name string, data []byte, thread *loadThread) (*Class, error) {
+	cf, err := class.NewClassFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, newError(ClassFormatError, err, "%s", name)
+	}
+	if cf.Name() == "" {
+		return nil, newError(ClassFormatError, nil, "%s: invalid this_class", name)
+	}
+	if name == "" {
+		name = cf.Name()
+	}
+	if strings.HasPrefix(name, "[") {
+		return nil, newError(ClassFormatError, nil, "Illegal class name %s", name)
+	}
+	if cf.Name() != name {
+		return nil, newError(NoClassDefFoundError, nil, "%s (wrong name: %s)", name, cf.Name())
+	}
+	if !l.trusted && strings.HasPrefix(name, "java/") {
+		return nil, newError(SecurityException, nil, "Prohibited package name: %s", strings.Replace(packageName(name), "/", ".", -1))
+	}
+
+	l.lock.Lock()
+	if _, ok := l.defined[name]; ok || l.defining[name] {
+		l.lock.Unlock()
+		return nil, newError(LinkageError, nil, "loader %s attempted duplicate class definition for %s", l, name)
+	}
+	l.defining[name] = true
+	if thread == nil {
+		thread = &loadThread{}
+		if call := l.loading[name]; call != nil && call.finding {
+			thread = call.thread
+		}
+	}
+	l.lock.Unlock()
+	defer func() {
+		l.lock.Lock()
+		delete(l.defining, name)
+		l.lock.Unlock()
+	}()
+
+	c := newClass(name, cf, l)
+	if err := l.resolveSupers(c, thread); err != nil {
+		return nil, err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	pkgName := packageName(name)
+	c.Package = l.packages[pkgName]
+	if c.Package == nil {
+		c.Package = &Package{Name: pkgName, Loader: l}
+		l.packages[pkgName] = c.Package
+	}
+	l.defined[name] = c
+	l.initiated[name] = c
+	return c, nil
+}
+
+// 加载超类和接口 (JVMS 5.3.5 第3、4步)
+func (l *ClassLoader) resolveSupers(c *Class, thread *loadThread) error {
+	cf := c.ClassFile
+	if superName := cf.SuperName(); superName != "" {
+		super, err := l.loadSuper(c.Name, superName, thread)
+		if err != nil {
+			return err
+		}
+		if super.IsInterface() {
+			return newError(IncompatibleClassChangeError, nil, "class %s has interface %s as super class", c.Name, super.Name)
+		}
+		if super.IsArray() {
+			return newError(ClassFormatError, nil, "class %s has array %s as super class", c.Name, super.Name)
+		}
+		if super.ClassFile.AccessFlags & class.ACCFINAL != 0 {
+			return newError(VerifyError, nil, "Cannot inherit from final class %s", super.Name)
+		}
+		c.SuperClass = super
+	}
+	for _, inter := range cf.Interfaces {
+		name := inter.Name.String()
+		super, err := l.loadSuper(c.Name, name, thread)
+		if err != nil {
+			return err
+		}
+		if !super.IsInterface() {
+			return newError(IncompatibleClassChangeError, nil, "class %s can not implement %s, because it is not an interface", c.Name, name)
+		}
+		c.Interfaces = append(c.Interfaces, super)
+	}
+	return nil
+}
+
+// 超类找不到时 ClassNotFoundException 转换为 NoClassDefFoundError
+func (l *ClassLoader) loadSuper(name, superName string, thread *loadThread) (*Class, error) {
+	if superName == name {
+		return nil, newError(ClassCircularityError, nil, "%s", name)
+	}
+	super, err := l.loadClass(superName, thread)
+	if IsError(err, ClassNotFoundException) {
+		return nil, newError(NoClassDefFoundError, err, "%s", superName)
+	}
+	return super, err
+}
+
+// 一条加载调用链，对应一个 LoadClass 或 DefineClass 以及其中递归加载的超类
+type loadThread struct {
+	// 正在等待的其他调用链的加载
+	waiting *loadCall
+}
+
+// 保护所有加载器的 loadThread.waiting 和 loadCall.done，等待关系可以跨加载器
+var waitLock sync.Mutex
+
+// 一次正在进行的 LoadClass
+type loadCall struct {
+	thread *loadThread
+	// 正在执行 finder
+	finding bool
+	done chan struct{}
+	c *Class
+	err error
+}
+
+// 等待其他请求加载同一个类，等待关系成环时返回 ClassCircularityError
+// 同一调用链中的环是类的循环继承，不同调用链之间的环会造成死锁，同样视为循环继承
+func (call *loadCall) wait(name string, thread *loadThread) (*Class, error) {
+	waitLock.Lock()
+	for t := call.thread; ; t = t.waiting.thread {
+		if t == thread {
+			waitLock.Unlock()
+			return nil, newError(ClassCircularityError, nil, "%s", name)
+		}
+		if t.waiting == nil || t.waiting.finished() {
+			break
+		}
+	}
+	thread.waiting = call
+	waitLock.Unlock()
+
+	<-call.done
+	waitLock.Lock()
+	thread.waiting = nil
+	waitLock.Unlock()
+	return call.c, call.err
+}
+
+func (call *loadCall) finished() bool {
+	select {
+	case <-call.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (call *loadCall) finish(c *Class, err error) {
+	waitLock.Lock()
+	defer waitLock.Unlock()
+	call.c, call.err = c, err
+	close(call.done)
+}
@@ -0,0 +1,445 @@
+package loader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+// 用于生成测试用class文件的构造器
+type testClass struct {
+	t testing.TB
+	pool *class.ConstantPool
+	cf *class.ClassFile
+}
+
+func newTestClass(t testing.TB, name, super string, flags uint16, interfaces ...string) *testClass {
+	tc := &testClass{t: t, pool: class.NewEmptyConstantPool()}
+	tc.cf = &class.ClassFile{
+		Magic: class.ClassFileMagic,
+		Major: 52,
+		ConstantPool: tc.pool,
+		AccessFlags: class.ClassAccessFlags(flags),
+		ThisClass: tc.class(name),
+	}
+	if super != "" {
+		tc.cf.SuperClass = tc.class(super)
+	}
+	for _, inter := range interfaces {
+		tc.cf.Interfaces = append(tc.cf.Interfaces, tc.class(inter))
+	}
+	return tc
+}
+
+func (tc *testClass) check(i uint16, err error) uint16 {
+	if err != nil {
+		tc.t.Fatal(err)
+	}
+	return i
+}
+
+func (tc *testClass) class(name string) *class.ConstClass {
+	c, _ := tc.pool.GetClass(tc.check(tc.pool.AddClass(name)))
+	return c
+}
+
+func (tc *testClass) utf8(s string) *class.ConstUTF8 {
+	u, _ := tc.pool.GetUTF8String(tc.check(tc.pool.AddUTF8(s)))
+	return u
+}
+
+// value 不为nil时添加 ConstantValue 属性
+func (tc *testClass) field(flags uint16, name, descriptor string, value class.Constant) *testClass {
+	field := &class.Field{AccessFlags: class.FieldAccessFlags(flags), Name: tc.utf8(name), Descriptor: tc.utf8(descriptor)}
+	if value != nil {
+		tc.utf8(class.ConstantValue)
+		field.Attrs = append(field.Attrs, &class.AttrConstantValue{Val: value})
+	}
+	tc.cf.Fields = append(tc.cf.Fields, field)
+	return tc
+}
+
+func (tc *testClass) constant(i uint16, err error) class.Constant {
+	c, _ := tc.pool.Get(tc.check(i, err))
+	return c
+}
+
+// 非抽象方法的代码只有一条 return
+func (tc *testClass) method(flags uint16, name, descriptor string) *testClass {
+	method := &class.Method{AccessFlags: class.MethodAccessFlags(flags), Name: tc.utf8(name), Descriptor: tc.utf8(descriptor)}
+	if flags & (class.MethodAccAbstract | class.MethodAccNative) == 0 {
+		tc.utf8(class.Code)
+		method.Attrs = append(method.Attrs, &class.AttrCode{MaxStack: 0, MaxLocals: 1, Code: []byte{0xb1}})
+	}
+	tc.cf.Methods = append(tc.cf.Methods, method)
+	return tc
+}
+
+func (tc *testClass) bytes() []byte {
+	buf := &bytes.Buffer{}
+	if _, err := tc.cf.WriteTo(buf); err != nil {
+		tc.t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// 生成只有类名、超类和接口的最简单的class文件
+func makeClass(t testing.TB, name, super string, flags uint16, interfaces ...string) []byte {
+	return newTestClass(t, name, super, flags, interfaces...).bytes()
+}
+
+func writeClass(t testing.TB, dir, name string, data []byte) {
+	path := filepath.Join(dir, filepath.FromSlash(name) + ".class")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const (
+	publicClass = class.ACCPUBLIC | class.ACCSUPER
+	publicInterface = class.ACCPUBLIC | class.ACCINTERFACE | class.ACCABSTRACT
+)
+
+// 引导、平台和应用三层类加载器，引导类路径中有 java/lang/Object、几个接口和异常类
+func testLoaders(t testing.TB) (boot, platform, app *ClassLoader, appDir string) {
+	bootDir := t.TempDir()
+	writeClass(t, bootDir, "java/lang/Object", makeClass(t, "java/lang/Object", "", publicClass))
+	for _, name := range []string{"java/lang/Runnable", "java/lang/Cloneable", "java/io/Serializable"} {
+		writeClass(t, bootDir, name, makeClass(t, name, "java/lang/Object", publicInterface))
+	}
+	for name, super := range map[string]string{
+		"java/lang/Throwable": "java/lang/Object",
+		"java/lang/Exception": "java/lang/Throwable",
+		"java/lang/Error": "java/lang/Throwable",
+		"java/lang/OutOfMemoryError": "java/lang/Error",
+	} {
+		writeClass(t, bootDir, name, makeClass(t, name, super, publicClass))
+	}
+	appDir = t.TempDir()
+	bootPath, _ := NewClassPath(bootDir)
+	appPath, _ := NewClassPath(appDir)
+	boot = NewBootstrapLoader(bootPath)
+	platform = NewPlatformLoader(boot, nil)
+	app = NewAppLoader(platform, appPath)
+	return
+}
+
+func TestClassLoaderDelegation(t *testing.T) {
+	boot, platform, app, appDir := testLoaders(t)
+	writeClass(t, appDir, "p/A", makeClass(t, "p/A", "java/lang/Object", publicClass, "java/lang/Runnable"))
+	// 父加载器优先，应用类路径中的同名类不会被使用
+	writeClass(t, appDir, "java/lang/Runnable", makeClass(t, "java/lang/Runnable", "java/lang/Object", publicClass))
+
+	a, err := app.LoadClass("p.A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Loader != app || a.Package.Name != "p" || a.Package != app.Package("p") {
+		t.Errorf("unexpected class %v in %v", a, a.Package)
+	}
+	if a.SuperClass.Loader != boot || a.SuperClass.Name != "java/lang/Object" || a.SuperClass.SuperClass != nil {
+		t.Errorf("unexpected super class %v", a.SuperClass)
+	}
+	if len(a.Interfaces) != 1 || a.Interfaces[0].Loader != boot {
+		t.Errorf("unexpected interfaces %v", a.Interfaces)
+	}
+	if c, _ := app.LoadClass("p/A"); c != a {
+		t.Error("class loaded twice")
+	}
+	// 应用加载器是 java/lang/Object 的初始加载器，平台加载器也是
+	object := boot.FindLoadedClass("java/lang/Object")
+	if app.FindLoadedClass("java/lang/Object") != object || platform.FindLoadedClass("java/lang/Object") != object {
+		t.Error("initiating loaders not recorded")
+	}
+	if _, err := boot.LoadClass("p/A"); !IsError(err, ClassNotFoundException) {
+		t.Errorf("expected ClassNotFoundException, got %v", err)
+	}
+	if platform.FindLoadedClass("p/A") != nil {
+		t.Error("p/A should not be visible to the platform loader")
+	}
+}
+
+func TestClassLoaderIdentity(t *testing.T) {
+	_, _, app, _ := testLoaders(t)
+	data := makeClass(t, "p/Plugin", "java/lang/Object", publicClass)
+	finder := func(loader *ClassLoader, name string) (*Class, error) {
+		if name != "p/Plugin" {
+			return nil, newError(ClassNotFoundException, nil, "%s", name)
+		}
+		// 模拟 Java 中的 findClass 回调 defineClass
+		return loader.DefineClass(name, data)
+	}
+	l1 := NewClassLoader("l1", app, finder)
+	l2 := NewClassLoader("l2", app, finder)
+	c1, err := l1.LoadClass("p/Plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := l2.LoadClass("p/Plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1 == c2 || c1.Key() == c2.Key() || c1.Key() != (ClassKey{"p/Plugin", l1}) {
+		t.Errorf("same class %v %v", c1, c2)
+	}
+	if c1.Package == c2.Package || c1.Package.Loader != l1 || len(l2.Packages()) != 1 {
+		t.Errorf("runtime packages not separated: %v %v", c1.Package, c2.Package)
+	}
+	if c1.SuperClass != c2.SuperClass {
+		t.Error("java/lang/Object should be shared")
+	}
+	if _, err := l1.DefineClass("p/Plugin", data); !IsError(err, LinkageError) {
+		t.Errorf("expected LinkageError, got %v", err)
+	}
+	if _, err := l1.LoadClass("p/Missing"); !IsError(err, ClassNotFoundException) {
+		t.Errorf("expected ClassNotFoundException, got %v", err)
+	}
+}
+
+func TestArrayClasses(t *testing.T) {
+	boot, _, app, appDir := testLoaders(t)
+	writeClass(t, appDir, "p/A", makeClass(t, "p/A", "java/lang/Object", publicClass))
+	a, err := app.LoadClass("p/A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := boot.FindLoadedClass("java/lang/Object")
+	for _, test := range []struct {
+		name string
+		loader *ClassLoader
+		component string
+	}{
+		{"[I", boot, ""},
+		{"[[I", boot, "[I"},
+		{"[Ljava.lang.Object;", boot, "java/lang/Object"},
+		{"[Lp/A;", app, "p/A"},
+		{"[[Lp/A;", app, "[Lp/A;"},
+	} {
+		c, err := app.LoadClass(test.name)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !c.IsArray() || c.ClassFile != nil || c.Loader != test.loader || c.SuperClass != object || len(c.Interfaces) != 2 {
+			t.Errorf("%s: unexpected class %v", test.name, c)
+		}
+		if test.component == "" && c.ComponentType != nil || test.component != "" && c.ComponentType.Name != test.component {
+			t.Errorf("%s: unexpected component type %v", test.name, c.ComponentType)
+		}
+		// 数组类不需要链接和初始化
+		if c.State() != ClassInitialized || c.Initialize(1, nil) != nil {
+			t.Errorf("%s: unexpected state %v", test.name, c.State())
+		}
+		if prev := app.FindLoadedClass(c.Name); prev != c || test.loader.FindLoadedClass(c.Name) != c {
+			t.Errorf("%s: not recorded", test.name)
+		}
+	}
+	if c := app.FindLoadedClass("[Lp/A;"); c.Package != a.Package {
+		t.Errorf("array in package %v", c.Package)
+	}
+	for _, name := range []string{"[Lp/Missing;", "[X", "[Lp/A", "[L;", "["} {
+		if _, err := app.LoadClass(name); !IsError(err, ClassNotFoundException) {
+			t.Errorf("%s: expected ClassNotFoundException, got %v", name, err)
+		}
+	}
+	if _, err := app.DefineClass("[I", makeClass(t, "[I", "java/lang/Object", publicClass)); !IsError(err, ClassFormatError) {
+		t.Errorf("expected ClassFormatError, got %v", err)
+	}
+}
+
+func TestDefineClassErrors(t *testing.T) {
+	_, _, app, appDir := testLoaders(t)
+	writeClass(t, appDir, "p/C", makeClass(t, "p/C", "p/D", publicClass))
+	writeClass(t, appDir, "p/D", makeClass(t, "p/D", "p/C", publicClass))
+	writeClass(t, appDir, "p/E", makeClass(t, "p/E", "p/Missing", publicClass))
+	writeClass(t, appDir, "p/F", makeClass(t, "p/F", "java/lang/Runnable", publicClass))
+	writeClass(t, appDir, "p/G", makeClass(t, "p/G", "java/lang/Object", publicClass, "java/lang/Object"))
+	writeClass(t, appDir, "p/Final", makeClass(t, "p/Final", "java/lang/Object", publicClass | class.ACCFINAL))
+	writeClass(t, appDir, "p/H", makeClass(t, "p/H", "p/Final", publicClass))
+	writeClass(t, appDir, "p/Wrong", makeClass(t, "p/Right", "java/lang/Object", publicClass))
+	writeClass(t, appDir, "p/Bad", []byte{0xca, 0xfe})
+	for name, expected := range map[string]string{
+		"p/C": ClassCircularityError,
+		"p/E": NoClassDefFoundError,
+		"p/F": IncompatibleClassChangeError,
+		"p/G": IncompatibleClassChangeError,
+		"p/H": VerifyError,
+		"p/Wrong": NoClassDefFoundError,
+		"p/Bad": ClassFormatError,
+	} {
+		if _, err := app.LoadClass(name); !IsError(err, expected) {
+			t.Errorf("%s: expected %s, got %v", name, expected, err)
+		}
+	}
+	if _, err := app.DefineClass("", makeClass(t, "java/lang/Evil", "java/lang/Object", publicClass)); !IsError(err, SecurityException) {
+		t.Errorf("expected SecurityException, got %v", err)
+	}
+	// 索引为0的接口和 this_class 是格式错误，而不是空指针
+	noInterface := newTestClass(t, "p/I0", "java/lang/Object", publicClass)
+	noInterface.cf.Interfaces = append(noInterface.cf.Interfaces, nil)
+	noThis := newTestClass(t, "p/T0", "java/lang/Object", publicClass)
+	noThis.cf.ThisClass = nil
+	for _, data := range [][]byte{noInterface.bytes(), noThis.bytes()} {
+		if _, err := NewClassLoader("x", nil, nil).DefineClass("", data); !IsError(err, ClassFormatError) {
+			t.Errorf("expected ClassFormatError, got %v", err)
+		}
+	}
+	var cfe *class.ClassFormatError
+	if _, err := app.DefineClass("p/I0", noInterface.bytes()); !errors.As(err, &cfe) || cfe.Path != "interfaces[0]" {
+		t.Errorf("unexpected error %v", err)
+	}
+	// 出错后可以重新加载
+	if app.FindLoadedClass("p/C") != nil || app.FindLoadedClass("p/D") != nil {
+		t.Error("failed class recorded")
+	}
+}
+
+// 多个 goroutine 并发加载有重叠的类集合，用 go test -race 运行
+func TestClassLoaderConcurrent(t *testing.T) {
+	_, _, app, _ := testLoaders(t)
+	// p/C0 <- p/C1 <- ... 每个类继承前一个类，并实现一个公共接口
+	files := map[string][]byte{"p/I.class": makeClass(t, "p/I", "java/lang/Object", publicInterface)}
+	const n = 32
+	for i := 0; i < n; i++ {
+		super := "java/lang/Object"
+		if i > 0 {
+			super = fmt.Sprintf("p/C%d", i - 1)
+		}
+		files[fmt.Sprintf("p/C%d.class", i)] = makeClass(t, fmt.Sprintf("p/C%d", i), super, publicClass, "p/I")
+	}
+	jar := filepath.Join(t.TempDir(), "classes.jar")
+	writeJar(t, jar, files)
+	cp, err := NewClassPath(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	var lock sync.Mutex
+	finds := map[string]int{}
+	custom := NewClassLoader("custom", app, func(loader *ClassLoader, name string) (*Class, error) {
+		lock.Lock()
+		finds[name]++
+		lock.Unlock()
+		data, _, err := cp.ReadClass(name)
+		if err != nil {
+			return nil, newError(ClassNotFoundException, err, "%s", name)
+		}
+		return loader.DefineClass(name, data)
+	})
+
+	const goroutines = 16
+	results := make([][]*Class, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			results[g] = make([]*Class, n)
+			// 每个 goroutine 从不同的位置开始，按不同的方向加载
+			for j := 0; j < n; j++ {
+				i := (g * 7 + j) % n
+				if g % 2 == 1 {
+					i = n - 1 - i
+				}
+				c, err := custom.LoadClass(fmt.Sprintf("p.C%d", i))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				results[g][i] = c
+			}
+		}(g)
+	}
+	wg.Wait()
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("p/C%d", i)
+		c := custom.FindLoadedClass(name)
+		if c == nil || c.Loader != custom || len(c.Interfaces) != 1 {
+			t.Fatalf("%s not loaded", name)
+		}
+		if i > 0 && c.SuperClass != custom.FindLoadedClass(fmt.Sprintf("p/C%d", i - 1)) {
+			t.Errorf("%s has super class %v", name, c.SuperClass)
+		}
+		for g := range results {
+			if results[g][i] != c {
+				t.Errorf("goroutine %d got a different %s", g, name)
+			}
+		}
+		if finds[name] != 1 {
+			t.Errorf("%s found %d times", name, finds[name])
+		}
+	}
+	if finds["p/I"] != 1 || len(custom.Packages()) != 1 {
+		t.Errorf("p/I found %d times", finds["p/I"])
+	}
+}
+
+func TestDefineClassConcurrent(t *testing.T) {
+	_, _, app, _ := testLoaders(t)
+	data := makeClass(t, "p/Dup", "java/lang/Object", publicClass)
+	l := NewClassLoader("l", app, nil)
+	var wg sync.WaitGroup
+	errs := make([]error, 16)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = l.DefineClass("p/Dup", data)
+		}(i)
+	}
+	wg.Wait()
+	defined := 0
+	for _, err := range errs {
+		if err == nil {
+			defined++
+		} else if !IsError(err, LinkageError) {
+			t.Errorf("expected LinkageError, got %v", err)
+		}
+	}
+	if defined != 1 || l.FindLoadedClass("p/Dup") == nil {
+		t.Errorf("class defined %d times", defined)
+	}
+}
+
+// 两个 goroutine 分别加载互为超类的两个类，等待成环时报告循环继承而不是死锁
+func TestClassCircularityConcurrent(t *testing.T) {
+	_, _, app, _ := testLoaders(t)
+	classes := map[string][]byte{
+		"p/C": makeClass(t, "p/C", "p/D", publicClass),
+		"p/D": makeClass(t, "p/D", "p/C", publicClass),
+	}
+	var started sync.WaitGroup
+	started.Add(2)
+	l := NewClassLoader("l", app, func(loader *ClassLoader, name string) (*Class, error) {
+		data, ok := classes[name]
+		if !ok {
+			return nil, newError(ClassNotFoundException, nil, "%s", name)
+		}
+		// 确保两个类同时处于加载中
+		started.Done()
+		started.Wait()
+		return loader.DefineClass(name, data)
+	})
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, name := range []string{"p/C", "p/D"} {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			_, errs[i] = l.LoadClass(name)
+		}(i, name)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if !IsError(err, ClassCircularityError) {
+			t.Errorf("expected ClassCircularityError, got %v", err)
+		}
+	}
+}
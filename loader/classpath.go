@@ -0,0 +1,250 @@
+package loader
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 类路径中的一项，可以是目录、jar/zip 文件或者自定义的实现
+type Entry interface {
+	// name 为 / 分隔的资源名，例如 java/util/ArrayList.class
+	// 资源不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)
+	ReadResource(name string) ([]byte, error)
+	Close() error
+	String() string
+}
+
+// 在所有类路径项中都找不到资源
+type NotFoundError struct {
+	Name string
+	// 查找过的类路径项
+	Searched []string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s not found in class path [%s]", e.Name, strings.Join(e.Searched, string(filepath.ListSeparator)))
+}
+
+// 使 errors.Is(err, os.ErrNotExist) 成立
+func (e *NotFoundError) Is(target error) bool {
+	return target == os.ErrNotExist
+}
+
+type ClassPath struct {
+	entries []Entry
+}
+
+// path 为 : 分隔的类路径 (Windows 上为 ;)，每项可以是
+// 目录、.jar/.zip 文件或者 dir/* 通配符 (目录下所有的 .jar 文件)
+func NewClassPath(path string) (*ClassPath, error) {
+	cp := &ClassPath{}
+	for _, item := range filepath.SplitList(path) {
+		if item == "" {
+			continue
+		}
+		entries, err := NewEntries(item)
+		if err != nil {
+			cp.Close()
+			return nil, err
+		}
+		cp.entries = append(cp.entries, entries...)
+	}
+	return cp, nil
+}
+
+// 解析类路径中的一项，通配符会展开为多项
+func NewEntries(path string) ([]Entry, error) {
+	if dir, file := filepath.Split(path); file == "*" {
+		return newWildcardEntries(dir)
+	}
+	if isArchive(path) {
+		return []Entry{NewZipEntry(path)}, nil
+	}
+	return []Entry{NewDirEntry(path)}, nil
+}
+
+func isArchive(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".jar" || ext == ".zip"
+}
+
+// 与 java 相同，通配符只匹配 .jar 文件，不递归子目录，按文件名排序
+func newWildcardEntries(dir string) ([]Entry, error) {
+	if dir == "" {
+		dir = "."
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []Entry
+	for _, file := range files {
+		if !file.IsDir() && strings.ToLower(filepath.Ext(file.Name())) == ".jar" {
+			entries = append(entries, NewZipEntry(filepath.Join(dir, file.Name())))
+		}
+	}
+	return entries, nil
+}
+
+func (cp *ClassPath) Entries() []Entry {
+	return cp.entries
+}
+
+// 追加到类路径末尾
+func (cp *ClassPath) Add(entries ...Entry) {
+	cp.entries = append(cp.entries, entries...)
+}
+
+// 按顺序在各项中查找资源，返回找到资源的项
+func (cp *ClassPath) ReadResource(name string) ([]byte, Entry, error) {
+	searched := make([]string, 0, len(cp.entries))
+	for _, entry := range cp.entries {
+		data, err := entry.ReadResource(name)
+		if err == nil {
+			return data, entry, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, entry, fmt.Errorf("%s: %s", entry, err)
+		}
+		searched = append(searched, entry.String())
+	}
+	return nil, nil, &NotFoundError{Name: name, Searched: searched}
+}
+
+// className 为内部形式的类名，例如 java/util/ArrayList，也接受 . 分隔的类名
+// 返回的字节可以直接交给 class.NewClassFile
+func (cp *ClassPath) ReadClass(className string) ([]byte, Entry, error) {
+	return cp.ReadResource(strings.Replace(className, ".", "/", -1) + ".class")
+}
+
+func (cp *ClassPath) Close() error {
+	var first error
+	for _, entry := range cp.entries {
+		if err := entry.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (cp *ClassPath) String() string {
+	paths := make([]string, len(cp.entries))
+	for i, entry := range cp.entries {
+		paths[i] = entry.String()
+	}
+	return strings.Join(paths, string(filepath.ListSeparator))
+}
+
+// 目录
+type DirEntry struct {
+	dir string
+}
+
+func NewDirEntry(dir string) *DirEntry {
+	return &DirEntry{dir: dir}
+}
+
+func (e *DirEntry) ReadResource(name string) ([]byte, error) {
+	if !validResourceName(name) {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.ReadFile(filepath.Join(e.dir, filepath.FromSlash(name)))
+}
+
+func (e *DirEntry) Close() error {
+	return nil
+}
+
+func (e *DirEntry) String() string {
+	return e.dir
+}
+
+// 不允许 .. 和绝对路径逃出类路径目录
+func validResourceName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// jar 或 zip 文件，第一次查找时打开并建立索引，之后一直复用直到 Close
+type ZipEntry struct {
+	path string
+	lock sync.Mutex
+	reader *zip.ReadCloser
+	files map[string]*zip.File
+	// 打开失败的错误也会被缓存
+	err error
+}
+
+func NewZipEntry(path string) *ZipEntry {
+	return &ZipEntry{path: path}
+}
+
+func (e *ZipEntry) open() (map[string]*zip.File, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.files != nil || e.err != nil {
+		return e.files, e.err
+	}
+	reader, err := zip.OpenReader(e.path)
+	if err != nil {
+		e.err = err
+		return nil, err
+	}
+	e.reader = reader
+	e.files = make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		if _, ok := e.files[file.Name]; !ok {
+			e.files[file.Name] = file
+		}
+	}
+	return e.files, nil
+}
+
+func (e *ZipEntry) ReadResource(name string) ([]byte, error) {
+	files, err := e.open()
+	if err != nil {
+		return nil, err
+	}
+	file, ok := files[name]
+	if !ok || file.FileInfo().IsDir() {
+		return nil, os.ErrNotExist
+	}
+	// zip.File.Open 基于 ReaderAt，可以并发读取
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+func (e *ZipEntry) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	var err error
+	if e.reader != nil {
+		err = e.reader.Close()
+	}
+	e.reader, e.files, e.err = nil, nil, nil
+	return err
+}
+
+func (e *ZipEntry) String() string {
+	return e.path
+}
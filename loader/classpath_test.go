@@ -0,0 +1,125 @@
+package loader
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+func testClassBytes(t testing.TB) []byte {
+	data, err := ioutil.ReadFile("../class/ArrayList.class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeJar(t testing.TB, path string, files map[string][]byte) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClassPath(t *testing.T) {
+	data := testClassBytes(t)
+	root := t.TempDir()
+	classes := filepath.Join(root, "classes")
+	os.MkdirAll(filepath.Join(classes, "java", "util"), 0755)
+	ioutil.WriteFile(filepath.Join(classes, "java", "util", "ArrayList.class"), data, 0644)
+	lib := filepath.Join(root, "lib")
+	os.Mkdir(lib, 0755)
+	writeJar(t, filepath.Join(lib, "b.jar"), map[string][]byte{"b/B.class": []byte("b"), "a/A.class": []byte("from b.jar")})
+	writeJar(t, filepath.Join(lib, "a.jar"), map[string][]byte{"a/A.class": []byte("a")})
+	writeJar(t, filepath.Join(lib, "ignored.zip"), map[string][]byte{"c/C.class": []byte("c")})
+	other := filepath.Join(root, "other.zip")
+	writeJar(t, other, map[string][]byte{"c/C.class": []byte("c"), "d/": nil})
+
+	path := strings.Join([]string{classes, filepath.Join(lib, "*"), other, filepath.Join(root, "missing.jar")}, string(filepath.ListSeparator))
+	cp, err := NewClassPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	var names []string
+	for _, entry := range cp.Entries() {
+		names = append(names, filepath.Base(entry.String()))
+	}
+	if strings.Join(names, " ") != "classes a.jar b.jar other.zip missing.jar" {
+		t.Errorf("unexpected entries %v", names)
+	}
+
+	got, entry, err := cp.ReadClass("java.util.ArrayList")
+	if err != nil || entry != cp.Entries()[0] {
+		t.Fatal(entry, err)
+	}
+	if cf, err := class.NewClassFile(bytes.NewReader(got)); err != nil || cf.Name() != "java/util/ArrayList" {
+		t.Errorf("unexpected class %v", err)
+	}
+	for name, expected := range map[string]string{"a/A": "a", "b/B": "b", "c/C": "c"} {
+		if got, _, err := cp.ReadClass(name); err != nil || string(got) != expected {
+			t.Errorf("%s: got %q %v, expected %q", name, got, err, expected)
+		}
+	}
+
+	for _, name := range []string{"x/Missing.class", "d/", "../classes/java/util/ArrayList.class"} {
+		_, _, err = cp.ReadResource(name)
+		var nf *NotFoundError
+		if !errors.As(err, &nf) || !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%s: expected NotFoundError, got %v", name, err)
+		}
+		if len(nf.Searched) != 5 || !strings.Contains(err.Error(), other) {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+}
+
+func TestZipEntryCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.jar")
+	writeJar(t, path, map[string][]byte{"a/A.class": []byte("a")})
+	entry := NewZipEntry(path)
+	if _, err := entry.ReadResource("a/A.class"); err != nil {
+		t.Fatal(err)
+	}
+	reader := entry.reader
+	if _, err := entry.ReadResource("a/B.class"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not exist, got %v", err)
+	}
+	if entry.reader != reader {
+		t.Error("zip reader reopened")
+	}
+	if err := entry.Close(); err != nil || entry.reader != nil {
+		t.Error("zip reader not closed", err)
+	}
+	// 关闭后可以重新打开
+	if data, err := entry.ReadResource("a/A.class"); err != nil || string(data) != "a" {
+		t.Error(data, err)
+	}
+	entry.Close()
+
+	bad := filepath.Join(t.TempDir(), "bad.jar")
+	ioutil.WriteFile(bad, []byte("not a zip"), 0644)
+	cp := &ClassPath{}
+	cp.Add(NewZipEntry(bad))
+	if _, _, err := cp.ReadClass("a/A"); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected zip error, got %v", err)
+	}
+}
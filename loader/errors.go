@@ -0,0 +1,54 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 加载和链接过程中需要以 Java 异常抛出的错误类型
+const (
+	ClassNotFoundException = "java/lang/ClassNotFoundException"
+	NoClassDefFoundError = "java/lang/NoClassDefFoundError"
+	ClassFormatError = "java/lang/ClassFormatError"
+	ClassCircularityError = "java/lang/ClassCircularityError"
+	IncompatibleClassChangeError = "java/lang/IncompatibleClassChangeError"
+	LinkageError = "java/lang/LinkageError"
+	VerifyError = "java/lang/VerifyError"
+	SecurityException = "java/lang/SecurityException"
+	IllegalAccessError = "java/lang/IllegalAccessError"
+	ExceptionInInitializerError = "java/lang/ExceptionInInitializerError"
+)
+
+// 对应一个 Java 异常，ClassName 为异常的内部形式类名
+type Error struct {
+	ClassName string
+	Message string
+	// 引起错误的原因，可能为nil
+	Err error
+}
+
+func newError(className string, err error, format string, args ...interface{}) *Error {
+	return &Error{ClassName: className, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+func (e *Error) Error() string {
+	s := strings.Replace(e.ClassName, "/", ".", -1)
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// err 是否为 className 对应的 Java 异常，只检查最外层的 *Error
+func IsError(err error, className string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.ClassName == className
+}
@@ -0,0 +1,371 @@
+package loader
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// JDK 9 之后 lib/modules 文件使用的 jimage 容器格式
+// 文件头、重定向表和偏移表使用生成镜像时平台的字节序，通过魔数判断
+const (
+	JImageMagic = 0xCAFEDADA
+	JImageMajorVersion = 1
+	JImageMinorVersion = 0
+	// 字符串哈希的乘数，也是默认的种子
+	jimageHashMultiplier = 0x01000193
+	jimageHeaderSize = 7 * 4
+)
+
+// 资源位置的属性，值在字符串表中的为偏移
+const (
+	jimageAttrEnd = iota
+	jimageAttrModule
+	jimageAttrParent
+	jimageAttrBase
+	jimageAttrExtension
+	jimageAttrOffset
+	jimageAttrCompressed
+	jimageAttrUncompressed
+	jimageAttrCount
+)
+
+type JImageHeader struct {
+	Magic uint32
+	Version uint32
+	Flags uint32
+	ResourceCount uint32
+	TableLength uint32
+	LocationsSize uint32
+	StringsSize uint32
+}
+
+func (h *JImageHeader) indexSize() int64 {
+	return jimageHeaderSize + int64(h.TableLength) * 8 + int64(h.LocationsSize) + int64(h.StringsSize)
+}
+
+// jimage 中资源的位置
+type JImageLocation struct {
+	Module, Parent, Base, Extension string
+	// 相对于资源区开头的偏移
+	Offset uint64
+	// 未压缩时 Compressed 为0
+	Compressed, Uncompressed uint64
+}
+
+// 完整的资源名，例如 /java.base/java/lang/Object.class
+func (l *JImageLocation) Name() string {
+	s := ""
+	if l.Module != "" {
+		s += "/" + l.Module + "/"
+	}
+	if l.Parent != "" {
+		s += l.Parent + "/"
+	}
+	s += l.Base
+	if l.Extension != "" {
+		s += "." + l.Extension
+	}
+	return s
+}
+
+// jimage 文件，作为类路径项时按 /packages 目录查找包所在的模块
+type JImageEntry struct {
+	path string
+	lock sync.Mutex
+	image *JImage
+	err error
+}
+
+func NewJImageEntry(path string) *JImageEntry {
+	return &JImageEntry{path: path}
+}
+
+func (e *JImageEntry) open() (*JImage, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.image == nil && e.err == nil {
+		e.image, e.err = OpenJImage(e.path)
+	}
+	return e.image, e.err
+}
+
+func (e *JImageEntry) ReadResource(name string) ([]byte, error) {
+	image, err := e.open()
+	if err != nil {
+		return nil, err
+	}
+	return image.ReadResource(name)
+}
+
+func (e *JImageEntry) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	var err error
+	if e.image != nil {
+		err = e.image.Close()
+	}
+	e.image, e.err = nil, nil
+	return err
+}
+
+func (e *JImageEntry) String() string {
+	return e.path
+}
+
+type JImage struct {
+	Header JImageHeader
+	order binary.ByteOrder
+	reader io.ReaderAt
+	size int64
+	closer io.Closer
+	redirect []int32
+	offsets []uint32
+	locations []byte
+	strings []byte
+	packagesLock sync.Mutex
+	packages map[string]string
+}
+
+func OpenJImage(path string) (*JImage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	image, err := NewJImage(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	image.closer = file
+	return image, nil
+}
+
+// 读取并缓存索引部分，资源内容在需要时通过 reader 读取，size 为文件大小
+func NewJImage(reader io.ReaderAt, size int64) (*JImage, error) {
+	image := &JImage{reader: reader, size: size, packages: map[string]string{}}
+	buf := make([]byte, jimageHeaderSize)
+	if _, err := reader.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("read jimage header: %s", err)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(buf) == JImageMagic:
+		image.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(buf) == JImageMagic:
+		image.order = binary.BigEndian
+	default:
+		return nil, errors.New("not a jimage file")
+	}
+	h := &image.Header
+	for i, field := range []*uint32{&h.Magic, &h.Version, &h.Flags, &h.ResourceCount, &h.TableLength, &h.LocationsSize, &h.StringsSize} {
+		*field = image.order.Uint32(buf[i * 4:])
+	}
+	if major := h.Version >> 16; major != JImageMajorVersion || h.Version & 0xFFFF != JImageMinorVersion {
+		return nil, fmt.Errorf("unsupported jimage version %d.%d", major, h.Version & 0xFFFF)
+	}
+	// 按文件头分配内存之前先检查大小，避免损坏的文件导致分配过多内存
+	if h.indexSize() > size {
+		return nil, fmt.Errorf("jimage index size %d exceeds file size %d", h.indexSize(), size)
+	}
+	index := make([]byte, h.indexSize() - jimageHeaderSize)
+	if _, err := reader.ReadAt(index, jimageHeaderSize); err != nil {
+		return nil, fmt.Errorf("read jimage index: %s", err)
+	}
+	n := int(h.TableLength)
+	image.redirect = make([]int32, n)
+	image.offsets = make([]uint32, n)
+	for i := 0; i < n; i++ {
+		image.redirect[i] = int32(image.order.Uint32(index[i * 4:]))
+		image.offsets[i] = image.order.Uint32(index[(n + i) * 4:])
+	}
+	index = index[n * 8:]
+	image.locations = index[:h.LocationsSize]
+	image.strings = index[h.LocationsSize:]
+	return image, nil
+}
+
+func (image *JImage) Close() error {
+	if image.closer != nil {
+		return image.closer.Close()
+	}
+	return nil
+}
+
+// jimage 使用的 FNV 风格字符串哈希，结果为非负数
+func jimageHash(s string, seed int32) int32 {
+	h := uint32(seed)
+	for i := 0; i < len(s); i++ {
+		h = h * jimageHashMultiplier ^ uint32(s[i])
+	}
+	return int32(h & 0x7FFFFFFF)
+}
+
+func (image *JImage) getString(offset uint64) (string, error) {
+	if offset >= uint64(len(image.strings)) {
+		return "", fmt.Errorf("string offset %d out of range", offset)
+	}
+	s := image.strings[offset:]
+	end := 0
+	for end < len(s) && s[end] != 0 {
+		end++
+	}
+	return string(s[:end]), nil
+}
+
+// 按完整的资源名查找位置，不存在时返回nil
+// 重定向表为完美哈希: 负数为直接的下标，正数为再次哈希的种子
+func (image *JImage) FindLocation(name string) (*JImageLocation, error) {
+	n := int32(image.Header.TableLength)
+	if n == 0 {
+		return nil, nil
+	}
+	index := jimageHash(name, jimageHashMultiplier) % n
+	switch value := image.redirect[index]; {
+	case value < 0:
+		index = -1 - value
+	case value > 0:
+		index = jimageHash(name, value) % n
+	default:
+		return nil, nil
+	}
+	if index < 0 || index >= n {
+		return nil, fmt.Errorf("location index %d out of range", index)
+	}
+	location, err := image.decodeLocation(image.offsets[index])
+	if err != nil {
+		return nil, err
+	}
+	// 哈希冲突时找到的是其他资源
+	if location.Name() != name {
+		return nil, nil
+	}
+	return location, nil
+}
+
+// 属性以 kind<<3 | (length-1) 的字节开头，随后是 length 字节的大端序值
+func (image *JImage) decodeLocation(offset uint32) (*JImageLocation, error) {
+	var attrs [jimageAttrCount]uint64
+	data := image.locations
+	for i := int(offset); ; {
+		if i >= len(data) {
+			return nil, fmt.Errorf("location %d is truncated", offset)
+		}
+		kind := data[i] >> 3
+		if kind == jimageAttrEnd {
+			break
+		}
+		if kind >= jimageAttrCount {
+			return nil, fmt.Errorf("location %d: unknow attribute kind %d", offset, kind)
+		}
+		length := int(data[i] & 7) + 1
+		if i + 1 + length > len(data) {
+			return nil, fmt.Errorf("location %d is truncated", offset)
+		}
+		var value uint64
+		for _, b := range data[i + 1:i + 1 + length] {
+			value = value << 8 | uint64(b)
+		}
+		attrs[kind] = value
+		i += 1 + length
+	}
+	location := &JImageLocation{
+		Offset: attrs[jimageAttrOffset],
+		Compressed: attrs[jimageAttrCompressed],
+		Uncompressed: attrs[jimageAttrUncompressed],
+	}
+	for _, s := range []struct {
+		kind int
+		value *string
+	}{
+		{jimageAttrModule, &location.Module},
+		{jimageAttrParent, &location.Parent},
+		{jimageAttrBase, &location.Base},
+		{jimageAttrExtension, &location.Extension},
+	} {
+		var err error
+		if *s.value, err = image.getString(attrs[s.kind]); err != nil {
+			return nil, fmt.Errorf("location %d: %s", offset, err)
+		}
+	}
+	return location, nil
+}
+
+func (image *JImage) readLocation(location *JImageLocation) ([]byte, error) {
+	if location.Compressed != 0 {
+		return nil, fmt.Errorf("%s: compressed resources are not supported", location.Name())
+	}
+	if remain := uint64(image.size - image.Header.indexSize()); location.Offset > remain || location.Uncompressed > remain - location.Offset {
+		return nil, fmt.Errorf("%s: resource exceeds file size", location.Name())
+	}
+	data := make([]byte, location.Uncompressed)
+	if _, err := image.reader.ReadAt(data, image.Header.indexSize() + int64(location.Offset)); err != nil {
+		return nil, fmt.Errorf("%s: %s", location.Name(), err)
+	}
+	return data, nil
+}
+
+// module 中的资源，name 例如 java/lang/Object.class
+func (image *JImage) ReadModuleResource(module, name string) ([]byte, error) {
+	location, err := image.FindLocation("/" + module + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if location == nil {
+		return nil, os.ErrNotExist
+	}
+	return image.readLocation(location)
+}
+
+// 通过 /packages/<包名> 找到包所在的模块后读取资源
+func (image *JImage) ReadResource(name string) ([]byte, error) {
+	i := strings.LastIndex(name, "/")
+	if i <= 0 {
+		return nil, os.ErrNotExist
+	}
+	module, err := image.PackageModule(strings.Replace(name[:i], "/", ".", -1))
+	if err != nil {
+		return nil, err
+	}
+	if module == "" {
+		return nil, os.ErrNotExist
+	}
+	return image.ReadModuleResource(module, name)
+}
+
+// 包所在的模块，不存在时返回空字符串
+// /packages/<包名> 的内容为 (isEmpty, 模块名偏移) 对，取第一个非空的模块
+func (image *JImage) PackageModule(pkg string) (string, error) {
+	image.packagesLock.Lock()
+	defer image.packagesLock.Unlock()
+	if module, ok := image.packages[pkg]; ok {
+		return module, nil
+	}
+	location, err := image.FindLocation("/packages/" + pkg)
+	if err != nil || location == nil {
+		return "", err
+	}
+	data, err := image.readLocation(location)
+	if err != nil {
+		return "", err
+	}
+	module := ""
+	for i := 0; i + 8 <= len(data); i += 8 {
+		if image.order.Uint32(data[i:]) == 0 {
+			if module, err = image.getString(uint64(image.order.Uint32(data[i + 4:]))); err != nil {
+				return "", err
+			}
+			break
+		}
+	}
+	image.packages[pkg] = module
+	return module, nil
+}
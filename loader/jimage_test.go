@@ -0,0 +1,336 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+var updateFixture = flag.Bool("update", false, "重新生成 testdata/modules")
+
+type jimagePackage struct {
+	name string
+	// 模块名，以 - 开头表示该模块中的包为空
+	modules []string
+}
+
+// 按 jlink 的格式生成 jimage，用于测试
+func buildJImage(order binary.ByteOrder, resources map[string][]byte, packages []jimagePackage) []byte {
+	strs := &bytes.Buffer{}
+	stringOffsets := map[string]uint64{}
+	addString := func(s string) uint64 {
+		if offset, ok := stringOffsets[s]; ok {
+			return offset
+		}
+		offset := uint64(strs.Len())
+		strs.WriteString(s)
+		strs.WriteByte(0)
+		stringOffsets[s] = offset
+		return offset
+	}
+	addString("")
+	all := map[string][]byte{}
+	for name, data := range resources {
+		all[name] = data
+	}
+	for _, pkg := range packages {
+		content := make([]byte, 8 * len(pkg.modules))
+		for i, module := range pkg.modules {
+			if strings.HasPrefix(module, "-") {
+				order.PutUint32(content[i * 8:], 1)
+				module = module[1:]
+			}
+			order.PutUint32(content[i * 8 + 4:], uint32(addString(module)))
+		}
+		all["/packages/" + pkg.name] = content
+	}
+	var names []string
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	locations := &bytes.Buffer{}
+	locations.WriteByte(0)
+	resourceData := &bytes.Buffer{}
+	locationOffsets := make([]uint32, len(names))
+	for i, name := range names {
+		parts := strings.SplitN(name[1:], "/", 2)
+		parent, base := path.Split(parts[1])
+		ext := ""
+		if dot := strings.LastIndex(base, "."); dot >= 0 {
+			base, ext = base[:dot], base[dot + 1:]
+		}
+		locationOffsets[i] = uint32(locations.Len())
+		for kind, value := range []uint64{
+			jimageAttrModule: addString(parts[0]),
+			jimageAttrParent: addString(strings.TrimSuffix(parent, "/")),
+			jimageAttrBase: addString(base),
+			jimageAttrExtension: addString(ext),
+			jimageAttrOffset: uint64(resourceData.Len()),
+			jimageAttrUncompressed: uint64(len(all[name])),
+		} {
+			if value == 0 {
+				continue
+			}
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], value)
+			n := 8
+			for n > 1 && buf[8 - n] == 0 {
+				n--
+			}
+			locations.WriteByte(byte(kind << 3 | (n - 1)))
+			locations.Write(buf[8 - n:])
+		}
+		locations.WriteByte(0)
+		resourceData.Write(all[name])
+	}
+
+	// 完美哈希: 冲突的桶寻找一个种子重新哈希，单个的桶直接指向空闲的槽位
+	n := int32(len(names))
+	buckets := make([][]int, n)
+	for i, name := range names {
+		h := jimageHash(name, jimageHashMultiplier) % n
+		buckets[h] = append(buckets[h], i)
+	}
+	bucketOrder := make([]int32, n)
+	for i := range bucketOrder {
+		bucketOrder[i] = int32(i)
+	}
+	sort.SliceStable(bucketOrder, func(i, j int) bool {
+		return len(buckets[bucketOrder[i]]) > len(buckets[bucketOrder[j]])
+	})
+	redirect := make([]int32, n)
+	offsets := make([]uint32, n)
+	used := make([]bool, n)
+	for _, b := range bucketOrder {
+		bucket := buckets[b]
+		switch {
+		case len(bucket) > 1:
+		seed:
+			for seed := int32(1); ; seed++ {
+				slots := map[int32]bool{}
+				for _, i := range bucket {
+					slot := jimageHash(names[i], seed) % n
+					if used[slot] || slots[slot] {
+						continue seed
+					}
+					slots[slot] = true
+				}
+				for _, i := range bucket {
+					slot := jimageHash(names[i], seed) % n
+					used[slot] = true
+					offsets[slot] = locationOffsets[i]
+				}
+				redirect[b] = seed
+				break
+			}
+		case len(bucket) == 1:
+			slot := int32(0)
+			for used[slot] {
+				slot++
+			}
+			used[slot] = true
+			offsets[slot] = locationOffsets[bucket[0]]
+			redirect[b] = -1 - slot
+		}
+	}
+
+	image := &bytes.Buffer{}
+	for _, v := range []uint32{JImageMagic, JImageMajorVersion << 16 | JImageMinorVersion, 0, uint32(n), uint32(n), uint32(locations.Len()), uint32(strs.Len())} {
+		binary.Write(image, order, v)
+	}
+	binary.Write(image, order, redirect)
+	binary.Write(image, order, offsets)
+	image.Write(locations.Bytes())
+	image.Write(strs.Bytes())
+	image.Write(resourceData.Bytes())
+	return image.Bytes()
+}
+
+func testJImage(t testing.TB, order binary.ByteOrder) []byte {
+	return buildJImage(order, map[string][]byte{
+		"/java.base/java/util/ArrayList.class": testClassBytes(t),
+		"/java.base/java/lang/Object.class": []byte("object"),
+		"/java.sql/java/sql/Driver.class": []byte("driver"),
+		"/java.sql/META-INF/MANIFEST.MF": []byte("manifest"),
+	}, []jimagePackage{
+		{"java.util", []string{"-java.sql", "java.base"}},
+		{"java.lang", []string{"java.base"}},
+		{"java.sql", []string{"java.sql"}},
+	})
+}
+
+func TestJImageFixture(t *testing.T) {
+	data := testJImage(t, binary.LittleEndian)
+	if *updateFixture {
+		if err := ioutil.WriteFile("testdata/modules", data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fixture, err := ioutil.ReadFile("testdata/modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fixture, data) {
+		t.Error("testdata/modules is out of date, run go test -run TestJImageFixture -update")
+	}
+}
+
+func TestJImage(t *testing.T) {
+	entry := NewJImageEntry("testdata/modules")
+	defer entry.Close()
+	bigData := testJImage(t, binary.BigEndian)
+	big, err := NewJImage(bytes.NewReader(bigData), int64(len(bigData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []interface{ ReadResource(string) ([]byte, error) }{entry, big} {
+		data, err := entry.ReadResource("java/util/ArrayList.class")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cf, err := class.NewClassFile(bytes.NewReader(data)); err != nil || cf.Name() != "java/util/ArrayList" {
+			t.Errorf("unexpected class %v", err)
+		}
+		for name, expected := range map[string]string{"java/lang/Object.class": "object", "java/sql/Driver.class": "driver"} {
+			if data, err := entry.ReadResource(name); err != nil || string(data) != expected {
+				t.Errorf("%s: got %q %v", name, data, err)
+			}
+		}
+		for _, name := range []string{"java/lang/String.class", "javax/Foo.class", "Foo.class", "java/util/Missing.class"} {
+			if _, err := entry.ReadResource(name); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s: expected not exist, got %v", name, err)
+			}
+		}
+	}
+	image, err := entry.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Header.ResourceCount != 7 {
+		t.Errorf("unexpected header %+v", image.Header)
+	}
+	if data, err := image.ReadModuleResource("java.sql", "META-INF/MANIFEST.MF"); err != nil || string(data) != "manifest" {
+		t.Errorf("got %q %v", data, err)
+	}
+	if module, _ := image.PackageModule("java.util"); module != "java.base" {
+		t.Errorf("java.util in module %q", module)
+	}
+	if _, err := NewJImage(bytes.NewReader(testClassBytes(t)), int64(len(testClassBytes(t)))); err == nil {
+		t.Error("expected error for non jimage file")
+	}
+}
+
+// 文件头中的大小超出文件时不按文件头分配内存
+func TestJImageTruncated(t *testing.T) {
+	data := testJImage(t, binary.LittleEndian)
+	huge := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(huge[4 * 4:], 0x7fffffff)
+	if _, err := NewJImage(bytes.NewReader(huge), int64(len(huge))); err == nil || !strings.Contains(err.Error(), "exceeds file size") {
+		t.Errorf("unexpected error %v", err)
+	}
+	// 截断资源区，索引完整但资源超出文件
+	image, err := NewJImage(bytes.NewReader(data), int64(len(data)) - 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := image.ReadResource("java/util/ArrayList.class"); err == nil || !strings.Contains(err.Error(), "exceeds file size") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// 用真实的 JDK 验证 buildJImage 和读取实现的哈希、重定向和位置编码，没有 JDK 9+ 时跳过
+func TestJImageJDK(t *testing.T) {
+	modules := filepath.Join(os.Getenv("JAVA_HOME"), "lib", "modules")
+	if os.Getenv("JAVA_HOME") == "" || !isFile(modules) {
+		t.Skip("JAVA_HOME is not a JDK 9+ installation")
+	}
+	image, err := OpenJImage(modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer image.Close()
+	// 每个位置都能通过名称的哈希找回
+	for i, offset := range image.offsets {
+		location, err := image.decodeLocation(offset)
+		if err != nil {
+			t.Fatalf("slot %d: %v", i, err)
+		}
+		found, err := image.FindLocation(location.Name())
+		if err != nil || found == nil || found.Offset != location.Offset {
+			t.Fatalf("slot %d: %s not found: %v", i, location.Name(), err)
+		}
+	}
+	if module, err := image.PackageModule("java.lang"); err != nil || module != "java.base" {
+		t.Errorf("java.lang in module %q %v", module, err)
+	}
+	location, err := image.FindLocation("/java.base/java/lang/Object.class")
+	if err != nil || location == nil {
+		t.Fatal("java/lang/Object.class not found", err)
+	}
+	if location.Compressed != 0 {
+		t.Skip("resources in this image are compressed")
+	}
+	data, err := image.ReadResource("java/lang/Object.class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cf, err := class.NewClassFile(bytes.NewReader(data)); err != nil || cf.Name() != "java/lang/Object" {
+		t.Errorf("unexpected class %v", err)
+	}
+}
+
+func TestBootClassPath(t *testing.T) {
+	fixture, err := ioutil.ReadFile("testdata/modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jdk11 := t.TempDir()
+	os.Mkdir(filepath.Join(jdk11, "lib"), 0755)
+	ioutil.WriteFile(filepath.Join(jdk11, "lib", "modules"), fixture, 0644)
+	jdk8 := t.TempDir()
+	os.MkdirAll(filepath.Join(jdk8, "jre", "lib"), 0755)
+	writeJar(t, filepath.Join(jdk8, "jre", "lib", "rt.jar"), map[string][]byte{"java/lang/Object.class": []byte("rt")})
+	writeJar(t, filepath.Join(jdk8, "jre", "lib", "jce.jar"), map[string][]byte{"javax/crypto/Cipher.class": []byte("jce")})
+	extra := t.TempDir()
+	writeJar(t, filepath.Join(extra, "a.jar"), map[string][]byte{"java/lang/Object.class": []byte("a"), "p/A.class": []byte("a")})
+
+	for _, test := range []struct {
+		javaHome string
+		entries int
+		object string
+	}{
+		{jdk11, 2, "object"},
+		{jdk8, 3, "rt"},
+		{filepath.Join(jdk8, "jre"), 3, "rt"},
+	} {
+		cp, err := NewBootClassPath(test.javaHome, filepath.Join(extra, "*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cp.Entries()) != test.entries {
+			t.Errorf("%s: unexpected entries %v", test.javaHome, cp)
+		}
+		if data, _, err := cp.ReadClass("java/lang/Object"); err != nil || string(data) != test.object {
+			t.Errorf("%s: got %q %v", test.javaHome, data, err)
+		}
+		if data, _, err := cp.ReadClass("p/A"); err != nil || string(data) != "a" {
+			t.Errorf("%s: appended class path not searched: %q %v", test.javaHome, data, err)
+		}
+		cp.Close()
+	}
+	if _, err := NewBootClassPath(extra, ""); err == nil {
+		t.Error("expected error for directory without JDK")
+	}
+}
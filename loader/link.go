@@ -0,0 +1,295 @@
+package loader
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+type ClassState int
+
+// 类的状态只会向后转换，出错后停留在 ClassErroneous
+const (
+	// 已经加载，超类和接口也已加载
+	ClassLoaded ClassState = iota
+	// 已经验证和准备
+	ClassLinked
+	// 某个线程正在执行初始化
+	ClassBeingInitialized
+	ClassInitialized
+	ClassErroneous
+)
+
+var classStateNames = []string{"loaded", "linked", "being initialized", "initialized", "erroneous"}
+
+func (s ClassState) String() string {
+	if int(s) < len(classStateNames) {
+		return classStateNames[s]
+	}
+	return "unknown"
+}
+
+// 静态字段，准备阶段设置为默认值
+// 有 ConstantValue 属性时直接使用其中的常量，不要求 final (JVMS 4.7.2)，与 HotSpot 相同，不等到 <clinit>
+type StaticField struct {
+	Name string
+	Descriptor string
+	Field *class.Field
+	// int32 (boolean、byte、char、short、int)、int64、float32、float64 或引用
+	// 在有堆对象之前 String 常量以 Go string 表示，其他引用为nil
+	Value interface{}
+}
+
+// 执行类的 <clinit> 方法，由解释器提供，返回的错误为 <clinit> 抛出的异常
+type ClinitFunc func(c *Class) error
+
+func (c *Class) State() ClassState {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.state
+}
+
+// 链接或初始化失败的原因，没有失败时返回nil
+func (c *Class) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+// 按名称和描述符查找静态字段，只查找当前类
+func (c *Class) StaticField(name, descriptor string) *StaticField {
+	for _, field := range c.StaticFields {
+		if field.Name == name && field.Descriptor == descriptor {
+			return field
+		}
+	}
+	return nil
+}
+
+// 链接类: 先链接超类和接口，然后验证和准备 (JVMS 5.4)
+// 解析是惰性的，参见 ResolveClass，失败后再次链接返回同一个错误
+func (c *Class) Link() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.linkErr != nil || c.state != ClassLoaded {
+		return c.linkErr
+	}
+	// 类层次没有环，按超类、接口的顺序加锁不会死锁
+	supers := c.Interfaces
+	if c.SuperClass != nil {
+		supers = append([]*Class{c.SuperClass}, supers...)
+	}
+	var err error
+	for _, super := range supers {
+		if err = super.Link(); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = c.verify()
+	}
+	if err == nil {
+		err = c.prepare()
+	}
+	if err != nil {
+		c.state, c.linkErr, c.err = ClassErroneous, err, err
+		return err
+	}
+	c.state = ClassLinked
+	return nil
+}
+
+// 目前只做 JVMS 4.8 的格式检查，尚不进行字节码的类型检查
+func (c *Class) verify() error {
+	if err := class.Check(c.ClassFile); err != nil {
+		return newError(ClassFormatError, err, "%s", c.Name)
+	}
+	return nil
+}
+
+// 为静态字段分配空间并设置初始值 (JVMS 5.4.2)
+func (c *Class) prepare() error {
+	c.StaticFields = nil
+	for _, field := range c.ClassFile.Fields {
+		if field.AccessFlags & class.FieldAccStatic == 0 {
+			continue
+		}
+		sf := &StaticField{
+			Name: field.Name.String(),
+			Descriptor: field.Descriptor.String(),
+			Field: field,
+		}
+		sf.Value = zeroValue(sf.Descriptor)
+		if cv := field.ConstantValue(); cv != nil {
+			value, err := constantValue(cv)
+			if err != nil {
+				return newError(ClassFormatError, err, "%s.%s", c.Name, sf.Name)
+			}
+			sf.Value = value
+		}
+		c.StaticFields = append(c.StaticFields, sf)
+	}
+	return nil
+}
+
+func zeroValue(descriptor string) interface{} {
+	switch descriptor {
+	case "Z", "B", "C", "S", "I":
+		return int32(0)
+	case "J":
+		return int64(0)
+	case "F":
+		return float32(0)
+	case "D":
+		return float64(0)
+	}
+	return nil
+}
+
+func constantValue(c class.Constant) (interface{}, error) {
+	switch c := c.(type) {
+	case *class.ConstInteger:
+		return c.Val, nil
+	case *class.ConstLong:
+		return c.Val, nil
+	case *class.ConstFloat:
+		return c.Val, nil
+	case *class.ConstDouble:
+		return c.Val, nil
+	case *class.ConstString:
+		return c.UTF8String.String(), nil
+	}
+	return nil, newError(ClassFormatError, nil, "invalid ConstantValue %v", c)
+}
+
+// 初始化类 (JVMS 5.5)，thread 为当前的 Java 线程，可以是任意可比较的值
+// 同一线程递归请求初始化时直接返回，其他线程等待初始化完成
+// 失败后类进入 ClassErroneous 状态，之后的使用都返回 NoClassDefFoundError
+func (c *Class) Initialize(thread interface{}, clinit ClinitFunc) error {
+	if err := c.Link(); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	for c.state == ClassBeingInitialized && c.initThread != thread {
+		c.cond.Wait()
+	}
+	switch c.state {
+	case ClassBeingInitialized, ClassInitialized:
+		c.lock.Unlock()
+		return nil
+	case ClassErroneous:
+		err := c.err
+		c.lock.Unlock()
+		return newError(NoClassDefFoundError, err, "Could not initialize class %s", strings.Replace(c.Name, "/", ".", -1))
+	}
+	c.state = ClassBeingInitialized
+	c.initThread = thread
+	c.lock.Unlock()
+
+	// 接口初始化时不初始化超接口
+	if !c.IsInterface() {
+		var supers []*Class
+		if c.SuperClass != nil {
+			supers = append(supers, c.SuperClass)
+		}
+		supers = append(supers, c.defaultMethodInterfaces()...)
+		for _, super := range supers {
+			if err := super.Initialize(thread, clinit); err != nil {
+				return c.finishInitialize(err)
+			}
+		}
+	}
+	if c.ClassFile.Method("<clinit>", "()V") != nil {
+		if err := clinit(c); err != nil {
+			if !c.isJavaLangError(err) {
+				err = newError(ExceptionInInitializerError, err, "")
+			}
+			return c.finishInitialize(err)
+		}
+	}
+	return c.finishInitialize(nil)
+}
+
+// java.lang.Error 及其子类原样抛出，其他异常包装为 ExceptionInInitializerError (JVMS 5.5 第11步)
+// 通过 c 的定义加载器加载异常类并沿超类判断，加载失败时按普通异常处理
+func (c *Class) isJavaLangError(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	thrown, loadErr := c.Loader.LoadClass(e.ClassName)
+	if loadErr != nil {
+		return false
+	}
+	// 用户类加载器不能定义 java.* 中的类，只需比较类名
+	for ; thrown != nil; thrown = thrown.SuperClass {
+		if thrown.Name == "java/lang/Error" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Class) finishInitialize(err error) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.initThread = nil
+	if err != nil {
+		c.state, c.err = ClassErroneous, err
+	} else {
+		c.state = ClassInitialized
+	}
+	c.cond.Broadcast()
+	return err
+}
+
+// 声明了非抽象非静态方法的超接口，按 JVMS 5.5 第7步的递归顺序，先超接口后接口本身
+func (c *Class) defaultMethodInterfaces() []*Class {
+	var result []*Class
+	seen := map[*Class]bool{}
+	var visit func(inter *Class)
+	visit = func(inter *Class) {
+		if seen[inter] {
+			return
+		}
+		seen[inter] = true
+		for _, super := range inter.Interfaces {
+			visit(super)
+		}
+		for _, method := range inter.ClassFile.Methods {
+			if method.AccessFlags & (class.MethodAccAbstract | class.MethodAccStatic) == 0 {
+				result = append(result, inter)
+				break
+			}
+		}
+	}
+	for _, inter := range c.Interfaces {
+		visit(inter)
+	}
+	return result
+}
+
+// 解析类引用 (JVMS 5.4.3.1)，通过 c 的定义加载器加载，并检查访问权限
+// name 可以是数组类的描述符，例如 [Ljava/lang/String;
+func (c *Class) ResolveClass(name string) (*Class, error) {
+	target, err := c.Loader.LoadClass(name)
+	if err != nil {
+		if IsError(err, ClassNotFoundException) {
+			return nil, newError(NoClassDefFoundError, err, "%s", name)
+		}
+		return nil, err
+	}
+	if !c.canAccess(target) {
+		return nil, newError(IllegalAccessError, nil, "class %s cannot access class %s", c.Name, target.Name)
+	}
+	return target, nil
+}
+
+// public 类或者在同一运行时包中 (JVMS 5.4.4)，数组类与元素类型相同，基本类型数组总是可以访问
+func (c *Class) canAccess(target *Class) bool {
+	if target.IsArray() {
+		return target.ComponentType == nil || c.canAccess(target.ComponentType)
+	}
+	return target.ClassFile.AccessFlags & class.ACCPUBLIC != 0 || target.Package == c.Package
+}
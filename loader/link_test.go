@@ -0,0 +1,223 @@
+package loader
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/yuya008/jvm4go/class"
+)
+
+func TestLinkPrepare(t *testing.T) {
+	_, _, app, appDir := testLoaders(t)
+	tc := newTestClass(t, "p/Config", "java/lang/Object", publicClass)
+	tc.field(class.FieldAccStatic | class.FieldAccFinal, "MAX", "I", tc.constant(tc.pool.AddInteger(42)))
+	tc.field(class.FieldAccStatic | class.FieldAccFinal, "NAME", "Ljava/lang/String;", tc.constant(tc.pool.AddString("jvm")))
+	tc.field(class.FieldAccStatic | class.FieldAccFinal, "RATE", "D", tc.constant(tc.pool.AddDouble(1.5)))
+	tc.field(class.FieldAccStatic, "count", "J", nil)
+	tc.field(class.FieldAccStatic, "list", "Ljava/util/List;", nil)
+	tc.field(0, "x", "I", nil)
+	writeClass(t, appDir, "p/Config", tc.bytes())
+
+	c, err := app.LoadClass("p/Config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.State() != ClassLoaded || c.StaticFields != nil {
+		t.Errorf("unexpected state %v", c.State())
+	}
+	if err := c.Link(); err != nil {
+		t.Fatal(err)
+	}
+	if c.State() != ClassLinked || c.SuperClass.State() != ClassLinked {
+		t.Errorf("unexpected state %v", c.State())
+	}
+	values := map[string]interface{}{}
+	for _, field := range c.StaticFields {
+		values[field.Name] = field.Value
+	}
+	expected := map[string]interface{}{"MAX": int32(42), "NAME": "jvm", "RATE": 1.5, "count": int64(0), "list": nil}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("static fields %v, expected %v", values, expected)
+	}
+	if f := c.StaticField("MAX", "I"); f == nil || f.Field != c.ClassFile.Fields[0] {
+		t.Error("StaticField lookup failed")
+	}
+
+	// 链接失败后保持同一个错误
+	bad := newTestClass(t, "p/Dup", "java/lang/Object", publicClass).field(0, "a", "I", nil).field(0, "a", "I", nil)
+	writeClass(t, appDir, "p/Dup", bad.bytes())
+	d, err := app.LoadClass("p/Dup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Link()
+	if !IsError(err, ClassFormatError) || d.State() != ClassErroneous {
+		t.Fatalf("expected ClassFormatError, got %v", err)
+	}
+	if again := d.Link(); again != err {
+		t.Errorf("second link returned %v", again)
+	}
+	if again := d.Initialize(1, nil); again != err {
+		t.Errorf("initialize returned %v", again)
+	}
+}
+
+func TestInitializeOrder(t *testing.T) {
+	_, _, app, appDir := testLoaders(t)
+	writeClass(t, appDir, "p/I", newTestClass(t, "p/I", "java/lang/Object", publicInterface).
+		method(class.MethodAccPublic, "run", "()V").method(class.MethodAccStatic, "<clinit>", "()V").bytes())
+	writeClass(t, appDir, "p/J", newTestClass(t, "p/J", "java/lang/Object", publicInterface, "p/I").
+		method(class.MethodAccPublic | class.MethodAccAbstract, "call", "()V").method(class.MethodAccStatic, "<clinit>", "()V").bytes())
+	writeClass(t, appDir, "p/Base", newTestClass(t, "p/Base", "java/lang/Object", publicClass).
+		method(class.MethodAccStatic, "<clinit>", "()V").bytes())
+	writeClass(t, appDir, "p/Sub", newTestClass(t, "p/Sub", "p/Base", publicClass, "p/J").
+		method(class.MethodAccStatic, "<clinit>", "()V").bytes())
+	sub, err := app.LoadClass("p/Sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	clinit := func(c *Class) error {
+		order = append(order, c.Name)
+		// <clinit> 中递归请求初始化自身直接返回
+		if err := c.Initialize("main", nil); err != nil || c.State() != ClassBeingInitialized {
+			t.Errorf("recursive initialize of %s: %v %v", c.Name, err, c.State())
+		}
+		return nil
+	}
+	if err := sub.Initialize("main", clinit); err != nil {
+		t.Fatal(err)
+	}
+	// p/J 没有默认方法不被初始化，p/I 作为 p/J 的超接口被初始化
+	if expected := []string{"p/Base", "p/I", "p/Sub"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("initialization order %v, expected %v", order, expected)
+	}
+	j, _ := app.LoadClass("p/J")
+	if sub.State() != ClassInitialized || j.State() != ClassLinked {
+		t.Errorf("unexpected states %v %v", sub.State(), j.State())
+	}
+	order = nil
+	if err := sub.Initialize("main", clinit); err != nil || order != nil {
+		t.Error("initialized twice")
+	}
+}
+
+func TestInitializeFailure(t *testing.T) {
+	_, _, app, appDir := testLoaders(t)
+	writeClass(t, appDir, "p/Bad", newTestClass(t, "p/Bad", "java/lang/Object", publicClass).
+		method(class.MethodAccStatic, "<clinit>", "()V").bytes())
+	writeClass(t, appDir, "p/Child", makeClass(t, "p/Child", "p/Bad", publicClass))
+	// 是否包装取决于异常类是否继承 java/lang/Error，与类名无关
+	writeClass(t, appDir, "p/FooError", makeClass(t, "p/FooError", "java/lang/Exception", publicClass))
+	writeClass(t, appDir, "p/Fatal", makeClass(t, "p/Fatal", "java/lang/OutOfMemoryError", publicClass))
+	thrown := map[string]string{
+		"p/Oom": "java/lang/OutOfMemoryError",
+		"p/ThrowsFatal": "p/Fatal",
+		"p/ThrowsFooError": "p/FooError",
+	}
+	for name := range thrown {
+		writeClass(t, appDir, name, newTestClass(t, name, "java/lang/Object", publicClass).
+			method(class.MethodAccStatic, "<clinit>", "()V").bytes())
+	}
+	boom := errors.New("boom")
+	clinit := func(c *Class) error {
+		if name, ok := thrown[c.Name]; ok {
+			return newError(name, nil, "")
+		}
+		return boom
+	}
+	bad, _ := app.LoadClass("p/Bad")
+	err := bad.Initialize(1, clinit)
+	if !IsError(err, ExceptionInInitializerError) || !errors.Is(err, boom) {
+		t.Fatalf("expected ExceptionInInitializerError, got %v", err)
+	}
+	if bad.State() != ClassErroneous || bad.Err() != err {
+		t.Errorf("unexpected state %v", bad.State())
+	}
+	again := bad.Initialize(1, clinit)
+	if !IsError(again, NoClassDefFoundError) || !errors.Is(again, boom) {
+		t.Errorf("expected NoClassDefFoundError, got %v", again)
+	}
+	child, _ := app.LoadClass("p/Child")
+	if err := child.Initialize(1, clinit); !IsError(err, NoClassDefFoundError) || child.State() != ClassErroneous {
+		t.Errorf("expected NoClassDefFoundError, got %v", err)
+	}
+	for name, expected := range map[string]string{
+		"p/Oom": "java/lang/OutOfMemoryError",
+		"p/ThrowsFatal": "p/Fatal",
+		"p/ThrowsFooError": ExceptionInInitializerError,
+	} {
+		c, _ := app.LoadClass(name)
+		if err := c.Initialize(1, clinit); !IsError(err, expected) {
+			t.Errorf("%s: expected %s, got %v", name, expected, err)
+		}
+	}
+}
+
+func TestInitializeConcurrent(t *testing.T) {
+	_, _, app, appDir := testLoaders(t)
+	writeClass(t, appDir, "p/Slow", newTestClass(t, "p/Slow", "java/lang/Object", publicClass).
+		method(class.MethodAccStatic, "<clinit>", "()V").bytes())
+	slow, _ := app.LoadClass("p/Slow")
+	started, release := make(chan struct{}), make(chan struct{})
+	var lock sync.Mutex
+	runs := 0
+	clinit := func(c *Class) error {
+		lock.Lock()
+		runs++
+		lock.Unlock()
+		close(started)
+		<-release
+		return nil
+	}
+	go slow.Initialize(1, clinit)
+	<-started
+	var wg sync.WaitGroup
+	for i := 2; i < 10; i++ {
+		wg.Add(1)
+		go func(thread int) {
+			defer wg.Done()
+			// 其他线程等待初始化完成后才返回
+			if err := slow.Initialize(thread, clinit); err != nil || slow.State() != ClassInitialized {
+				t.Errorf("thread %d: %v %v", thread, err, slow.State())
+			}
+		}(i)
+	}
+	close(release)
+	wg.Wait()
+	if runs != 1 {
+		t.Errorf("<clinit> ran %d times", runs)
+	}
+}
+
+func TestResolveClass(t *testing.T) {
+	_, _, app, appDir := testLoaders(t)
+	writeClass(t, appDir, "p/A", makeClass(t, "p/A", "java/lang/Object", publicClass))
+	writeClass(t, appDir, "p/Hidden", makeClass(t, "p/Hidden", "java/lang/Object", class.ACCSUPER))
+	writeClass(t, appDir, "q/B", makeClass(t, "q/B", "java/lang/Object", publicClass))
+	a, _ := app.LoadClass("p/A")
+	b, _ := app.LoadClass("q/B")
+	if hidden, err := a.ResolveClass("p/Hidden"); err != nil || hidden.Package != a.Package {
+		t.Errorf("same package: %v", err)
+	}
+	if _, err := b.ResolveClass("p/Hidden"); !IsError(err, IllegalAccessError) {
+		t.Errorf("expected IllegalAccessError, got %v", err)
+	}
+	if _, err := b.ResolveClass("p/Missing"); !IsError(err, NoClassDefFoundError) {
+		t.Errorf("expected NoClassDefFoundError, got %v", err)
+	}
+	// 数组类的访问权限由元素类型决定
+	if _, err := b.ResolveClass("[[Lp/Hidden;"); !IsError(err, IllegalAccessError) {
+		t.Errorf("expected IllegalAccessError, got %v", err)
+	}
+	for _, name := range []string{"[Lp/A;", "[[I", "[Ljava/lang/Object;"} {
+		if array, err := b.ResolveClass(name); err != nil || array.Name != name {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := b.ResolveClass("[Lp/Missing;"); !IsError(err, NoClassDefFoundError) {
+		t.Errorf("expected NoClassDefFoundError, got %v", err)
+	}
+}
@@ -1,7 +1,2 @@
+// 类加载: 类路径的查找、类加载器以及运行时类的链接
 package loader
-
-import "log"
-
-func Version() {
-	log.Println("jjjj")
-}
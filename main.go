@@ -1,5 +1,15 @@
 package main
 
-func main() {
+import (
+	"fmt"
+	"os"
+
+	"github.com/yuya008/jvm4go/cmd"
+)
 
+func main() {
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }